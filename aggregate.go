@@ -0,0 +1,129 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icebox
+
+// Select restricts the columns selected by the query to the given columns of
+// its table. Rows of a projection can be read into a smaller struct, or into
+// scalars for a single column, with Scan.
+func (q *Query) Select(columns ...string) *Query {
+	for _, column := range columns {
+		if err := q.checkColumn(column); err != nil {
+			q.fail(err)
+			return q
+		}
+	}
+	q.columns = append(q.columns, columns...)
+	return q
+}
+
+// Distinct removes duplicate rows from the results of the query.
+func (q *Query) Distinct() *Query {
+	q.distinct = true
+	return q
+}
+
+// Scan runs the query and reads its rows into dest, as RawQuery.Scan does.
+// Unlike All, dest may be of any type whose fields are mapped from the
+// selected columns, such as a struct holding a subset of the fields of the
+// table, or a scalar for a single selected column, for example
+//
+//	var emails []string
+//	err := db.From(new(User)).Select("email").Distinct().Scan(&emails)
+func (q *Query) Scan(dest interface{}) error {
+	query, args, err := q.SQL()
+	if err != nil {
+		return err
+	}
+	return scanQuery(q.q, q.db, query, args, false, dest)
+}
+
+// Count returns the number of rows of the query. The ordering of the query
+// is ignored, while its limit, offset and distinct selection are counted
+// with a subquery.
+func (q *Query) Count() (int64, error) {
+	if q.err != nil {
+		return 0, q.err
+	}
+	var query string
+	var args []interface{}
+	var err error
+	if q.distinct || q.limit > 0 || q.offset > 0 {
+		query, args, err = q.render(q.selectList(), true)
+		query = "SELECT COUNT(*) FROM (" + query + ") AS counted"
+	} else {
+		query, args, err = q.render("COUNT(*)", false)
+	}
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	err = q.q.QueryRow(query, args...).Scan(&count)
+	return count, err
+}
+
+// Sum reads the sum of the given column over the rows of the query into
+// dest. The sum of no rows is NULL, so dest should be able to hold one, such
+// as a *sql.NullFloat64 or a pointer to a pointer.
+func (q *Query) Sum(column string, dest interface{}) error {
+	return q.aggregate("SUM", column, dest)
+}
+
+// Avg reads the average of the given column over the rows of the query into
+// dest, as Sum does.
+func (q *Query) Avg(column string, dest interface{}) error {
+	return q.aggregate("AVG", column, dest)
+}
+
+// Min reads the smallest value of the given column over the rows of the
+// query into dest, as Sum does.
+func (q *Query) Min(column string, dest interface{}) error {
+	return q.aggregate("MIN", column, dest)
+}
+
+// Max reads the largest value of the given column over the rows of the query
+// into dest, as Sum does.
+func (q *Query) Max(column string, dest interface{}) error {
+	return q.aggregate("MAX", column, dest)
+}
+
+// Read the given aggregate function of the given column over the rows of the
+// query into dest. The ordering, limit and offset of the query are ignored.
+func (q *Query) aggregate(function, column string, dest interface{}) error {
+	if q.err != nil {
+		return q.err
+	}
+	if err := q.checkColumn(column); err != nil {
+		return err
+	}
+	query, args, err := q.render(function+"("+q.db.dialect.Quote(column)+")", false)
+	if err != nil {
+		return err
+	}
+	return q.q.QueryRow(query, args...).Scan(dest)
+}
+
+// Exists returns whether the query has any rows.
+func (q *Query) Exists() (bool, error) {
+	if q.err != nil {
+		return false, q.err
+	}
+	inner, args, err := q.render("1", true)
+	if err != nil {
+		return false, err
+	}
+	var exists bool
+	err = q.q.QueryRow("SELECT EXISTS ("+inner+")", args...).Scan(&exists)
+	return exists, err
+}
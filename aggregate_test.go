@@ -0,0 +1,116 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icebox
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+)
+
+// Test that aggregates render over the conditions of the query only.
+func TestAggregates(t *testing.T) {
+	db := openFakeAccounts(t, "postgres")
+	defer db.Close()
+	scalar := func(value driver.Value) fakeResultSet {
+		return fakeResultSet{columns: []string{"value"}, rows: [][]driver.Value{{value}}}
+	}
+	fakeQueue(scalar(int64(12)), scalar(int64(3)), scalar(float64(2.5)), scalar(nil), scalar(true))
+
+	query := db.From(new(fakeAccount)).Where(`"id" > ?`, 1).OrderBy(`"id"`)
+	count, err := query.Count()
+	if err != nil || count != 12 {
+		t.Errorf("count incorrect: count = %d, error = %v", count, err)
+	}
+	count, err = query.Limit(10).Count()
+	if err != nil || count != 3 {
+		t.Errorf("limited count incorrect: count = %d, error = %v", count, err)
+	}
+	var avg float64
+	if err := query.Avg("id", &avg); err != nil || avg != 2.5 {
+		t.Errorf("average incorrect: avg = %v, error = %v", avg, err)
+	}
+	var max sql.NullInt64
+	if err := query.Max("id", &max); err != nil || max.Valid {
+		t.Errorf("maximum incorrect: max = %v, error = %v", max, err)
+	}
+	exists, err := query.Exists()
+	if err != nil || !exists {
+		t.Errorf("exists incorrect: exists = %v, error = %v", exists, err)
+	}
+
+	expected := []string{
+		`SELECT COUNT(*) FROM "fake_accounts" WHERE ("id" > $1)`,
+		`SELECT COUNT(*) FROM (SELECT "id", "email", "name" FROM "fake_accounts" WHERE ("id" > $1) ` +
+			`ORDER BY "id" LIMIT 10) AS counted`,
+		`SELECT AVG("id") FROM "fake_accounts" WHERE ("id" > $1)`,
+		`SELECT MAX("id") FROM "fake_accounts" WHERE ("id" > $1)`,
+		`SELECT EXISTS (SELECT 1 FROM "fake_accounts" WHERE ("id" > $1) ORDER BY "id" LIMIT 10)`,
+	}
+	statements := fakeStatements()
+	if len(statements) != len(expected) {
+		t.Fatalf("aggregates not run: statements = %v", statements)
+	}
+	for i, statement := range statements {
+		if statement.query != expected[i] {
+			t.Errorf("aggregate incorrect: query = %s, expected = %s", statement.query, expected[i])
+		}
+	}
+	if err := query.Sum("missing", &avg); err == nil {
+		t.Errorf("error not raised for an unknown column")
+	}
+}
+
+type fakeMailbox struct {
+	Email string `icebox:"column"`
+}
+
+// Test that projections select a subset of columns into smaller structs or
+// scalars.
+func TestProjection(t *testing.T) {
+	db := openFakeAccounts(t, "sqlite3")
+	defer db.Close()
+	result := fakeResultSet{
+		columns: []string{"email"},
+		rows:    [][]driver.Value{{"a@x"}, {"b@x"}},
+	}
+	fakeQueue(result, result)
+
+	query := db.From(new(fakeAccount)).Select("email").Distinct()
+	var emails []string
+	if err := query.Scan(&emails); err != nil {
+		t.Fatalf("projection failed: error = %s", err.Error())
+	}
+	var contacts []fakeMailbox
+	if err := query.Scan(&contacts); err != nil {
+		t.Fatalf("projection failed: error = %s", err.Error())
+	}
+	if !reflect.DeepEqual(emails, []string{"a@x", "b@x"}) || len(contacts) != 2 || contacts[1].Email != "b@x" {
+		t.Errorf("projection incorrect: emails = %v, contacts = %v", emails, contacts)
+	}
+	expected := `SELECT DISTINCT "email" FROM "fake_accounts"`
+	if statements := fakeStatements(); statements[0].query != expected {
+		t.Errorf("projection query incorrect: query = %s, expected = %s", statements[0].query, expected)
+	}
+
+	if _, _, err := db.From(new(fakeAccount)).Select("missing").SQL(); err == nil {
+		t.Errorf("error not raised for an unknown column")
+	}
+	fakeQueue(fakeResultSet{columns: []string{"id", "email"}, rows: [][]driver.Value{{int64(1), "a@x"}}})
+	if err := db.From(new(fakeAccount)).Select("id", "email").Scan(&emails); err == nil {
+		t.Errorf("error not raised for scalars of several columns")
+	}
+}
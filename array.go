@@ -0,0 +1,219 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icebox
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+)
+
+// ArrayValue is both a driver.Valuer and a sql.Scanner for array columns.
+type ArrayValue interface {
+	driver.Valuer
+	sql.Scanner
+}
+
+// Array wraps a slice of a scalar type for use as an argument bound to a
+// PostgreSQL array, such as in
+//
+//	db.Query(`SELECT * FROM posts WHERE tags && $1`, icebox.Array(tags))
+//
+// or wraps a pointer to such a slice for use as a destination of Rows.Scan.
+// Arrays are scanned from PostgreSQL array literals, or from the JSON arrays
+// arrays are stored as in other dialects.
+func Array(slice interface{}) ArrayValue {
+	return &arrayValue{value: reflect.ValueOf(slice)}
+}
+
+// The ArrayValue of a slice, or of a pointer to a slice.
+type arrayValue struct {
+	value reflect.Value
+}
+
+// Encode the slice as a PostgreSQL array literal. A nil slice is NULL.
+func (a *arrayValue) Value() (driver.Value, error) {
+	slice := reflect.Indirect(a.value)
+	if slice.Kind() != reflect.Slice {
+		return nil, errors.New("array value is not a slice: " + slice.Type().String())
+	}
+	if slice.IsNil() {
+		return nil, nil
+	}
+	return encodeArrayLiteral(slice), nil
+}
+
+// Decode a PostgreSQL array literal or JSON array into the slice. A NULL
+// sets the slice to nil.
+func (a *arrayValue) Scan(src interface{}) error {
+	if a.value.Kind() != reflect.Ptr || a.value.Elem().Kind() != reflect.Slice {
+		return errors.New("array destination is not a pointer to a slice: " + a.value.Type().String())
+	}
+	slice := a.value.Elem()
+	var data []byte
+	switch src := src.(type) {
+	case nil:
+		slice.Set(reflect.Zero(slice.Type()))
+		return nil
+	case []byte:
+		data = src
+	case string:
+		data = []byte(src)
+	default:
+		return errors.New("unsupported array column value of type " + reflect.TypeOf(src).String())
+	}
+
+	if bytes.HasPrefix(data, []byte("[")) {
+		decoded := reflect.New(slice.Type())
+		if err := json.Unmarshal(data, decoded.Interface()); err != nil {
+			return err
+		}
+		slice.Set(decoded.Elem())
+		return nil
+	}
+	elements, err := parseArrayLiteral(string(data))
+	if err != nil {
+		return err
+	}
+	decoded := reflect.MakeSlice(slice.Type(), len(elements), len(elements))
+	for i, element := range elements {
+		if element == nil {
+			continue
+		}
+		if err := setScalar(decoded.Index(i), *element); err != nil {
+			return err
+		}
+	}
+	slice.Set(decoded)
+	return nil
+}
+
+// Encode the given slice as a PostgreSQL array literal. Strings are quoted,
+// and other scalars are written in their text form.
+func encodeArrayLiteral(slice reflect.Value) string {
+	var buffer bytes.Buffer
+	buffer.WriteString("{")
+	for i := 0; i < slice.Len(); i++ {
+		if i > 0 {
+			buffer.WriteString(",")
+		}
+		element := slice.Index(i)
+		switch element.Kind() {
+		case reflect.String:
+			buffer.WriteString(`"`)
+			for _, r := range element.String() {
+				if r == '"' || r == '\\' {
+					buffer.WriteRune('\\')
+				}
+				buffer.WriteRune(r)
+			}
+			buffer.WriteString(`"`)
+		case reflect.Bool:
+			buffer.WriteString(strconv.FormatBool(element.Bool()))
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			buffer.WriteString(strconv.FormatInt(element.Int(), 10))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			buffer.WriteString(strconv.FormatUint(element.Uint(), 10))
+		case reflect.Float32, reflect.Float64:
+			buffer.WriteString(strconv.FormatFloat(element.Float(), 'g', -1, 64))
+		}
+	}
+	buffer.WriteString("}")
+	return buffer.String()
+}
+
+// Split a one dimensional PostgreSQL array literal into its elements. NULL
+// elements are nil.
+func parseArrayLiteral(text string) ([]*string, error) {
+	if len(text) < 2 || text[0] != '{' || text[len(text)-1] != '}' {
+		return nil, errors.New("invalid array literal: " + text)
+	}
+	text = text[1 : len(text)-1]
+	elements := make([]*string, 0)
+	if text == "" {
+		return elements, nil
+	}
+	for pos := 0; pos <= len(text); pos++ {
+		var buffer bytes.Buffer
+		quoted := pos < len(text) && text[pos] == '"'
+		if quoted {
+			pos++
+			for ; pos < len(text) && text[pos] != '"'; pos++ {
+				if text[pos] == '\\' && pos+1 < len(text) {
+					pos++
+				}
+				buffer.WriteByte(text[pos])
+			}
+			if pos >= len(text) {
+				return nil, errors.New("unterminated quoted array element")
+			}
+			pos++
+		} else {
+			for ; pos < len(text) && text[pos] != ','; pos++ {
+				buffer.WriteByte(text[pos])
+			}
+		}
+		if pos < len(text) && text[pos] != ',' {
+			return nil, errors.New("invalid array literal: {" + text + "}")
+		}
+
+		element := buffer.String()
+		if !quoted && element == "NULL" {
+			elements = append(elements, nil)
+		} else {
+			elements = append(elements, &element)
+		}
+	}
+	return elements, nil
+}
+
+// Set the given scalar value from its text form.
+func setScalar(value reflect.Value, text string) error {
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(text)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(text, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(text, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(text, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetFloat(f)
+	default:
+		return errors.New("unsupported array element type " + value.Type().String())
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icebox
+
+import (
+	"database/sql/driver"
+	"github.com/jadengis/icebox/schema"
+	"reflect"
+	"testing"
+)
+
+// Test that slices are encoded as array literals and decoded back.
+func TestArrayValue(t *testing.T) {
+	testCases := []struct {
+		slice   interface{}
+		literal string
+	}{
+		{[]string{"go", `say "hi"`, `back\slash`, "a,b"}, `{"go","say \"hi\"","back\\slash","a,b"}`},
+		{[]int64{1, -2, 3}, `{1,-2,3}`},
+		{[]bool{true, false}, `{true,false}`},
+		{[]string{}, `{}`},
+	}
+
+	for _, tc := range testCases {
+		value, err := Array(tc.slice).Value()
+		if err != nil {
+			t.Fatalf("array could not be encoded: error = %s", err.Error())
+		}
+		if value != tc.literal {
+			t.Errorf("array literal incorrect: literal = %v, expected = %s", value, tc.literal)
+		}
+		decoded := reflect.New(reflect.TypeOf(tc.slice))
+		if err := Array(decoded.Interface()).Scan([]byte(tc.literal)); err != nil {
+			t.Fatalf("array could not be decoded: error = %s", err.Error())
+		}
+		if !reflect.DeepEqual(decoded.Elem().Interface(), tc.slice) {
+			t.Errorf("decoded array incorrect: array = %v, expected = %v",
+				decoded.Elem().Interface(), tc.slice)
+		}
+	}
+}
+
+// Test that arrays are also decoded from JSON and NULLs.
+func TestArrayScan(t *testing.T) {
+	var tags []string
+	if err := Array(&tags).Scan(`["a","b"]`); err != nil || !reflect.DeepEqual(tags, []string{"a", "b"}) {
+		t.Errorf("json array decoded incorrectly: array = %v, error = %v", tags, err)
+	}
+	var counts []int
+	if err := Array(&counts).Scan("{1,NULL,3}"); err != nil || !reflect.DeepEqual(counts, []int{1, 0, 3}) {
+		t.Errorf("array with NULL decoded incorrectly: array = %v, error = %v", counts, err)
+	}
+	if err := Array(&tags).Scan(nil); err != nil || tags != nil {
+		t.Errorf("NULL array decoded incorrectly: array = %v, error = %v", tags, err)
+	}
+	if err := Array(&tags).Scan(`{"unterminated}`); err == nil {
+		t.Errorf("error not raised for an invalid literal")
+	}
+}
+
+type fakePost struct {
+	Id   int64    `icebox:"column,primaryKey"`
+	Tags []string `icebox:"column"`
+}
+
+// Test that array columns are bound natively in PostgreSQL and as JSON
+// elsewhere.
+func TestInsertArray(t *testing.T) {
+	s, err := schema.NewSchema("test_schema", new(fakePost))
+	if err != nil {
+		t.Fatalf("schema could not be generated: error = %s", err.Error())
+	}
+	testCases := []struct {
+		driver string
+		value  driver.Value
+	}{
+		{"postgres", `{"go","sql"}`},
+		{"sqlite3", `["go","sql"]`},
+	}
+
+	for _, tc := range testCases {
+		db := openFake(t, tc.driver, s)
+		fakeQueue(fakeResultSet{columns: []string{"id"}, rows: [][]driver.Value{{int64(1)}}})
+		if err := db.Insert(&fakePost{Tags: []string{"go", "sql"}}); err != nil {
+			t.Fatalf("insert failed: error = %s", err.Error())
+		}
+		statements := fakeStatements()
+		if len(statements) != 1 || !reflect.DeepEqual(statements[0].args, []driver.Value{tc.value}) {
+			t.Errorf("%s array argument incorrect: statements = %v, expected = %v",
+				tc.driver, statements, tc.value)
+		}
+		db.Close()
+	}
+}
@@ -0,0 +1,430 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cli implements the icebox command-line tool.
+//
+// The tool needs the application schema and the database driver, both of
+// which live in Go code, so it is shipped as a library. An application builds
+// its own binary with a main package along the lines of
+//
+//	import (
+//		_ "github.com/mattn/go-sqlite3"
+//		"github.com/jadengis/icebox/cli"
+//		"github.com/jadengis/icebox/schema"
+//	)
+//
+//	func main() {
+//		s, err := schema.NewSchema("app", new(User), new(Post))
+//		if err != nil {
+//			log.Fatal(err)
+//		}
+//		cli.Main(s)
+//	}
+//
+// and the resulting binary is what deploy pipelines invoke. The supported
+// commands are
+//
+//	schema dump [-format ddl|json|yaml]   print the schema
+//	migrate diff                          print the statements the database is missing
+//	migrate up                            apply all pending migrations
+//	migrate down                          revert the latest applied migration
+//	migrate status                        list migrations and when they were applied
+//	validate                              check the config, connection and schema
+//
+// A binary built without a schema, such as the icebox command itself, reads
+// the schema snapshot named by the schema field of the config file instead.
+//
+// The migrate commands run the SQL files in the configured migrations
+// directory together with any Go migrations the binary registered through
+// migrate.Register.
+//
+// Every command accepts a -config flag naming the JSON config file, which
+// defaults to icebox.json in the working directory.
+package cli
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"github.com/jadengis/icebox"
+	"github.com/jadengis/icebox/dialect"
+	"github.com/jadengis/icebox/migrate"
+	"github.com/jadengis/icebox/schema"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Exit codes returned by Run.
+const (
+	exitOK      int = 0
+	exitFailure int = 1
+	exitUsage   int = 2
+)
+
+// The usage text printed for a bad invocation.
+const usage string = `usage: icebox [-config file] <command> [arguments]
+
+commands:
+  schema dump [-format ddl|json|yaml]
+  migrate diff
+  migrate up
+  migrate down
+  migrate status
+  validate
+`
+
+// Main runs the icebox command-line tool with the arguments of the current
+// process and exits with its status.
+func Main(s schema.Schema) {
+	os.Exit(Run(os.Args[1:], s, os.Stdout, os.Stderr))
+}
+
+// Run runs the icebox command-line tool with the given arguments and returns
+// the exit status. The schema may be nil, in which case the commands which
+// need a schema fail.
+func Run(args []string, s schema.Schema, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("icebox", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { fmt.Fprint(stderr, usage) }
+	configPath := flags.String("config", defaultConfigPath, "path to the config file")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	name, args := commandName(flags.Args())
+	run, found := commands[name]
+	if !found {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+	env := &environment{
+		schema:     s,
+		configPath: *configPath,
+		stdout:     stdout,
+	}
+	defer env.close()
+	if err := run(env, args); err != nil {
+		fmt.Fprintln(stderr, "icebox:", err)
+		if _, ok := err.(*usageError); ok {
+			return exitUsage
+		}
+		return exitFailure
+	}
+	return exitOK
+}
+
+// A command implementation, which is given the remaining arguments after the
+// command name.
+type command func(env *environment, args []string) error
+
+// Mapping between command names and their implementations.
+var commands = map[string]command{
+	"schema dump":    schemaDump,
+	"migrate diff":   migrateDiff,
+	"migrate up":     migrateUp,
+	"migrate down":   migrateDown,
+	"migrate status": migrateStatus,
+	"validate":       validate,
+}
+
+// Split the command name from the front of the given arguments. Command names
+// are either a single word, or a group followed by a subcommand.
+func commandName(args []string) (string, []string) {
+	if len(args) == 0 {
+		return "", nil
+	}
+	if _, found := commands[args[0]]; found || len(args) == 1 {
+		return args[0], args[1:]
+	}
+	return strings.Join(args[:2], " "), args[2:]
+}
+
+// The state shared by commands, with the config and database loaded lazily
+// as they are needed.
+type environment struct {
+	schema     schema.Schema
+	configPath string
+	stdout     io.Writer
+	config     *Config
+	db         *icebox.DB
+}
+
+// Load the config file, if it has not been loaded yet.
+func (e *environment) loadConfig() (*Config, error) {
+	if e.config == nil {
+		config, err := LoadConfig(e.configPath)
+		if err != nil {
+			return nil, err
+		}
+		e.config = config
+	}
+	return e.config, nil
+}
+
+// Return the dialect for the configured driver.
+func (e *environment) dialect() (dialect.Dialect, error) {
+	config, err := e.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	return dialect.For(config.Driver)
+}
+
+// Open the configured database, if it has not been opened yet.
+func (e *environment) open() (*icebox.DB, error) {
+	if e.db == nil {
+		config, err := e.loadConfig()
+		if err != nil {
+			return nil, err
+		}
+		if !isLinked(config.Driver) {
+			return nil, &driverError{driver: config.Driver}
+		}
+		db, err := icebox.Open(config.Driver, config.DSN, e.schema)
+		if err != nil {
+			return nil, err
+		}
+		e.db = db
+	}
+	return e.db, nil
+}
+
+// Returns whether the database/sql driver with the given name is linked
+// into the binary.
+func isLinked(driver string) bool {
+	for _, linked := range sql.Drivers() {
+		if linked == driver {
+			return true
+		}
+	}
+	return false
+}
+
+// Build a migrator from the configured database, the migrations directory and
+// the Go migrations registered with the migrate package.
+func (e *environment) migrator() (migrate.Migrator, error) {
+	d, err := e.dialect()
+	if err != nil {
+		return nil, err
+	}
+	db, err := e.open()
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := e.migrations()
+	if err != nil {
+		return nil, err
+	}
+	return migrate.NewMigrator(db, d, migrations)
+}
+
+// Load the migrations in the migrations directory together with the Go
+// migrations registered with the migrate package. A missing directory holds
+// no migrations.
+func (e *environment) migrations() ([]*migrate.Migration, error) {
+	migrations, err := migrate.LoadDir(e.config.Migrations)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return append(migrations, migrate.Registered()...), nil
+}
+
+// Return the schema, loading the configured snapshot if the binary was built
+// without one. This returns an error if there is neither.
+func (e *environment) requireSchema() (schema.Schema, error) {
+	if e.schema != nil {
+		return e.schema, nil
+	}
+	config, err := e.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if config.Schema == "" {
+		return nil, &usageError{msg: "this icebox binary was built without a schema, " +
+			"and the config has no schema file"}
+	}
+	contents, err := ioutil.ReadFile(config.Schema)
+	if err != nil {
+		return nil, err
+	}
+	switch filepath.Ext(config.Schema) {
+	case ".yaml", ".yml":
+		e.schema, err = schema.UnmarshalYAML(contents)
+	default:
+		e.schema, err = schema.UnmarshalJSON(contents)
+	}
+	return e.schema, err
+}
+
+// Release the database connection, if one was opened.
+func (e *environment) close() {
+	if e.db != nil {
+		e.db.Close()
+	}
+}
+
+// Print the schema either as DDL for the configured dialect, or as a JSON or
+// YAML snapshot.
+func schemaDump(env *environment, args []string) error {
+	flags := flag.NewFlagSet("schema dump", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	format := flags.String("format", "ddl", "output format, ddl, json or yaml")
+	if err := flags.Parse(args); err != nil {
+		return &usageError{msg: err.Error()}
+	}
+	s, err := env.requireSchema()
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "json":
+		document, err := schema.MarshalJSON(s)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(env.stdout, string(document))
+		return nil
+	case "yaml":
+		document, err := schema.MarshalYAML(s)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(env.stdout, string(document))
+		return nil
+	case "ddl":
+		d, err := env.dialect()
+		if err != nil {
+			return err
+		}
+		for _, table := range s.Tables() {
+			printStatements(env.stdout, dialect.CreateTable(d, table))
+		}
+		return nil
+	default:
+		return &usageError{msg: "unknown format " + *format}
+	}
+}
+
+// Print the statements needed to bring the database in line with the schema.
+func migrateDiff(env *environment, args []string) error {
+	statements, err := diff(env)
+	if err != nil {
+		return err
+	}
+	printStatements(env.stdout, statements)
+	return nil
+}
+
+// Apply all pending migrations.
+func migrateUp(env *environment, args []string) error {
+	migrator, err := env.migrator()
+	if err != nil {
+		return err
+	}
+	count, err := migrator.Up()
+	fmt.Fprintf(env.stdout, "applied %d migration(s)\n", count)
+	return err
+}
+
+// Revert the latest applied migration.
+func migrateDown(env *environment, args []string) error {
+	migrator, err := env.migrator()
+	if err != nil {
+		return err
+	}
+	migration, err := migrator.Down()
+	if err != nil {
+		return err
+	}
+	if migration == nil {
+		fmt.Fprintln(env.stdout, "no migration to revert")
+		return nil
+	}
+	fmt.Fprintf(env.stdout, "reverted %d %s\n", migration.Version, migration.Name)
+	return nil
+}
+
+// List every migration along with when it was applied.
+func migrateStatus(env *environment, args []string) error {
+	migrator, err := env.migrator()
+	if err != nil {
+		return err
+	}
+	statuses, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.AppliedAt != nil {
+			appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		if status.Modified {
+			appliedAt += " (modified)"
+		}
+		fmt.Fprintf(env.stdout, "%d\t%s\t%s\n",
+			status.Migration.Version, status.Migration.Name, appliedAt)
+	}
+	return nil
+}
+
+// Check that the config is valid, the database is reachable, the migrations
+// can be loaded if there are any, and the database matches the schema.
+func validate(env *environment, args []string) error {
+	if _, err := env.migrator(); err != nil {
+		return err
+	}
+	if env.schema == nil && env.config.Schema == "" {
+		fmt.Fprintln(env.stdout, "ok")
+		return nil
+	}
+	statements, err := diff(env)
+	if err != nil {
+		return err
+	}
+	if len(statements) > 0 {
+		printStatements(env.stdout, statements)
+		return &driftError{count: len(statements)}
+	}
+	fmt.Fprintln(env.stdout, "ok")
+	return nil
+}
+
+// Compute the statements missing from the configured database.
+func diff(env *environment) ([]string, error) {
+	s, err := env.requireSchema()
+	if err != nil {
+		return nil, err
+	}
+	d, err := env.dialect()
+	if err != nil {
+		return nil, err
+	}
+	db, err := env.open()
+	if err != nil {
+		return nil, err
+	}
+	return migrate.Diff(db, d, s)
+}
+
+// Print the given statements, each terminated by a semicolon.
+func printStatements(out io.Writer, statements []string) {
+	for _, statement := range statements {
+		fmt.Fprintln(out, statement+";")
+	}
+}
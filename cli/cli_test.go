@@ -0,0 +1,202 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"database/sql"
+	"github.com/jadengis/icebox/internal/fakedb"
+	"github.com/jadengis/icebox/schema"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type fakeUser struct {
+	Id    int    `icebox:"column,primaryKey"`
+	Email string `icebox:"column,unique"`
+}
+
+// Write a config file into a fresh temporary directory.
+func writeConfig(t *testing.T, contents string) string {
+	dir, err := ioutil.TempDir("", "icebox-cli")
+	if err != nil {
+		t.Fatalf("temporary directory could not be created: error = %s", err.Error())
+	}
+	path := filepath.Join(dir, "icebox.json")
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("config could not be written: error = %s", err.Error())
+	}
+	return path
+}
+
+// Test that command names are split from their arguments.
+func TestCommandName(t *testing.T) {
+	testCases := []struct {
+		args []string
+		name string
+		rest int
+	}{
+		{[]string{"validate"}, "validate", 0},
+		{[]string{"schema", "dump", "-format", "json"}, "schema dump", 2},
+		{[]string{"migrate", "up"}, "migrate up", 0},
+		{nil, "", 0},
+	}
+	for _, tc := range testCases {
+		name, rest := commandName(tc.args)
+		if name != tc.name || len(rest) != tc.rest {
+			t.Errorf("command name incorrect: name = %s, expected = %s, rest = %v",
+				name, tc.name, rest)
+		}
+	}
+}
+
+// Test that the schema can be dumped as DDL for the configured driver.
+func TestSchemaDump(t *testing.T) {
+	path := writeConfig(t, `{"driver": "sqlite3", "dsn": "file::memory:"}`)
+	defer os.RemoveAll(filepath.Dir(path))
+	s, err := schema.NewSchema("test_schema", new(fakeUser))
+	if err != nil {
+		t.Fatalf("schema could not be generated: error = %s", err.Error())
+	}
+
+	var stdout, stderr bytes.Buffer
+	status := Run([]string{"-config", path, "schema", "dump"}, s, &stdout, &stderr)
+	if status != exitOK {
+		t.Fatalf("schema dump failed: status = %d, stderr = %s", status, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), `CREATE TABLE "fake_users"`) {
+		t.Errorf("schema dump is missing the table: output = %s", stdout.String())
+	}
+}
+
+// Test that bad invocations are reported as usage errors.
+func TestRunUsage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if status := Run([]string{"asdf"}, nil, &stdout, &stderr); status != exitUsage {
+		t.Errorf("unknown command status incorrect: status = %d", status)
+	}
+
+	path := writeConfig(t, `{"driver": "sqlite3", "dsn": "file::memory:"}`)
+	defer os.RemoveAll(filepath.Dir(path))
+	status := Run([]string{"-config", path, "schema", "dump"}, nil, &stdout, &stderr)
+	if status != exitUsage {
+		t.Errorf("missing schema status incorrect: status = %d", status)
+	}
+}
+
+// Test that config files missing required fields are rejected.
+func TestLoadConfig(t *testing.T) {
+	path := writeConfig(t, `{"driver": "sqlite3"}`)
+	defer os.RemoveAll(filepath.Dir(path))
+	if _, err := LoadConfig(path); err == nil {
+		t.Errorf("error not raised for missing dsn")
+	} else if !strings.Contains(err.Error(), "dsn") {
+		t.Errorf("raised error doesn't mention the dsn: error = %s", err.Error())
+	}
+}
+
+// Serve the sqlite3 driver name with the fake driver, so that the commands
+// opening the database can run without one.
+func init() {
+	sql.Register("sqlite3", fakedb.Driver{})
+}
+
+// Test that the migrate commands apply, list and revert the migrations of
+// the configured directory.
+func TestMigrateCommands(t *testing.T) {
+	dir, err := ioutil.TempDir("", "icebox-cli")
+	if err != nil {
+		t.Fatalf("temporary directory could not be created: error = %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"0001_create_users.up.sql":   "CREATE TABLE users (id INTEGER)",
+		"0001_create_users.down.sql": "DROP TABLE users",
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatalf("migration could not be written: error = %s", err.Error())
+		}
+	}
+	path := writeConfig(t, `{"driver": "sqlite3", "dsn": "migrate_commands", "migrations": "`+dir+`"}`)
+	defer os.RemoveAll(filepath.Dir(path))
+	fake := fakedb.New("migrate_commands")
+
+	testCases := []struct {
+		command  []string
+		expected string
+	}{
+		{[]string{"migrate", "status"}, "1\tcreate_users\tpending\n"},
+		{[]string{"migrate", "up"}, "applied 1 migration(s)\n"},
+		{[]string{"migrate", "up"}, "applied 0 migration(s)\n"},
+		{[]string{"migrate", "down"}, "reverted 1 create_users\n"},
+		{[]string{"migrate", "down"}, "no migration to revert\n"},
+	}
+	for _, tc := range testCases {
+		var stdout, stderr bytes.Buffer
+		status := Run(append([]string{"-config", path}, tc.command...), nil, &stdout, &stderr)
+		if status != exitOK {
+			t.Fatalf("%s failed: status = %d, stderr = %s",
+				strings.Join(tc.command, " "), status, stderr.String())
+		}
+		if stdout.String() != tc.expected {
+			t.Errorf("%s output incorrect: output = %q, expected = %q",
+				strings.Join(tc.command, " "), stdout.String(), tc.expected)
+		}
+	}
+	if len(fake.History) != 0 {
+		t.Errorf("history not emptied by migrate down: history = %v", fake.History)
+	}
+}
+
+// Test that validate succeeds without a migrations directory.
+func TestValidateWithoutMigrations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "icebox-cli")
+	if err != nil {
+		t.Fatalf("temporary directory could not be created: error = %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+	path := writeConfig(t, `{"driver": "sqlite3", "dsn": "validate", "migrations": "`+
+		filepath.Join(dir, "missing")+`"}`)
+	defer os.RemoveAll(filepath.Dir(path))
+	fakedb.New("validate")
+
+	var stdout, stderr bytes.Buffer
+	status := Run([]string{"-config", path, "validate"}, nil, &stdout, &stderr)
+	if status != exitOK || stdout.String() != "ok\n" {
+		t.Errorf("validate failed: status = %d, output = %s, stderr = %s",
+			status, stdout.String(), stderr.String())
+	}
+	if status := Run([]string{"-config", path, "migrate", "up"}, nil, &stdout, &stderr); status != exitOK {
+		t.Errorf("migrate up failed without a migrations directory: status = %d, stderr = %s",
+			status, stderr.String())
+	}
+}
+
+// Test that commands opening a database with a driver which is not linked
+// into the binary explain how to build one which is.
+func TestUnlinkedDriver(t *testing.T) {
+	path := writeConfig(t, `{"driver": "postgres", "dsn": "unlinked"}`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	var stdout, stderr bytes.Buffer
+	status := Run([]string{"-config", path, "migrate", "status"}, nil, &stdout, &stderr)
+	if status != exitFailure || !strings.Contains(stderr.String(), "not linked into this binary") {
+		t.Errorf("unlinked driver not reported: status = %d, stderr = %s", status, stderr.String())
+	}
+}
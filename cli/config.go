@@ -0,0 +1,70 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"encoding/json"
+	"io/ioutil"
+)
+
+// The config file read when none is given on the command line.
+const defaultConfigPath string = "icebox.json"
+
+// Config is the contents of the icebox config file.
+//
+// Driver is the database/sql driver name passed to icebox.Open.
+//
+// DSN is the data source name passed to icebox.Open.
+//
+// Migrations is the directory holding the SQL migration files.
+//
+// Schema is an optional schema snapshot, written by schema dump in the json
+// or yaml format, which is used when the binary was built without a schema.
+type Config struct {
+	Driver     string `json:"driver"`
+	DSN        string `json:"dsn"`
+	Migrations string `json:"migrations"`
+	Schema     string `json:"schema"`
+}
+
+// LoadConfig reads and validates the JSON config file at the given path.
+func LoadConfig(path string) (*Config, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := new(Config)
+	if err = json.Unmarshal(contents, config); err != nil {
+		return nil, &configError{path: path, msg: err.Error()}
+	}
+	if err = config.validate(path); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Check that the config holds everything needed to connect to the database.
+func (c *Config) validate(path string) error {
+	if c.Driver == "" {
+		return &configError{path: path, msg: "driver is required"}
+	}
+	if c.DSN == "" {
+		return &configError{path: path, msg: "dsn is required"}
+	}
+	if c.Migrations == "" {
+		c.Migrations = "migrations"
+	}
+	return nil
+}
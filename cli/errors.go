@@ -0,0 +1,61 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"fmt"
+)
+
+// Error type for an invalid config file.
+type configError struct {
+	path string
+	msg  string
+}
+
+// Produce an error message for a configError.
+func (e *configError) Error() string {
+	return fmt.Sprintf("invalid config %s : %s", e.path, e.msg)
+}
+
+// Error type for a bad command-line invocation.
+type usageError struct {
+	msg string
+}
+
+// Produce an error message for a usageError.
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+// Error type for a database which does not match the schema.
+type driftError struct {
+	count int
+}
+
+// Produce an error message for a driftError.
+func (e *driftError) Error() string {
+	return fmt.Sprintf("database is missing %d statement(s) from the schema", e.count)
+}
+
+// Error type for a driver which is not linked into the binary.
+type driverError struct {
+	driver string
+}
+
+// Produce an error message for a driverError.
+func (e *driverError) Error() string {
+	return fmt.Sprintf("driver %s is not linked into this binary : "+
+		"build a binary for the project importing the driver, see package cli", e.driver)
+}
@@ -0,0 +1,28 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command icebox is the icebox command-line tool built without an application
+// schema or database drivers. It reads the schema from the snapshot named in
+// its config file, so that schema dump works offline, but the commands which
+// open a database report that a binary for the project must be built with
+// its driver; see package cli for building one.
+package main
+
+import (
+	"github.com/jadengis/icebox/cli"
+)
+
+func main() {
+	cli.Main(nil)
+}
@@ -16,25 +16,102 @@ package icebox
 
 import (
 	"database/sql"
+	"github.com/jadengis/icebox/dialect"
+	"github.com/jadengis/icebox/schema"
+	"reflect"
+	"sync"
 )
 
-// DB is a wrapper structure for the embedded sql.DB.
+// DB is a wrapper structure for the embedded sql.DB, which knows the dialect
+// spoken by the database and the schema of the objects stored in it.
 type DB struct {
 	*sql.DB
+	dialect  dialect.Dialect
+	schema   schema.Schema
+	stmts    *stmtCache
+	scopes   *scopeRegistry
+	tenancy  tenancy
+	replicas *replicaSet
+	primary  bool
 }
 
-// Tx is a wrapper structure for the embedded sql.Tx.
+// Tx is a wrapper structure for the embedded sql.Tx. A Tx begun with
+// DB.Begin shares the dialect and schema of its DB.
+//
+// Stmts are the cached statements of the DB prepared on the connection of
+// the transaction, by SQL, which database/sql closes once the transaction is
+// committed or rolled back.
 type Tx struct {
 	*sql.Tx
+	db    *DB
+	mutex sync.Mutex
+	stmts map[string]*sql.Stmt
 }
 
-func Open(driver, dataSourceName string) (*DB, error) {
-	var db, err = sql.Open(driver, dataSourceName)
+// Open opens and pings the database with the given driver and data source
+// name. The dialect is chosen from the driver name, and the schema describes
+// the objects stored in the database. The schema may be nil if the DB is
+// only used for plain SQL, such as by migrations.
+func Open(driver, dataSourceName string, s schema.Schema) (*DB, error) {
+	d, err := dialect.For(driver)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open(driver, dataSourceName)
 	if err != nil {
 		return nil, err
 	}
 	if err = db.Ping(); err != nil {
 		return nil, err
 	}
-	return &DB{db}, nil
+	return NewDB(db, d, s), nil
+}
+
+// NewDB wraps an already open sql.DB speaking the given dialect.
+func NewDB(db *sql.DB, d dialect.Dialect, s schema.Schema) *DB {
+	return &DB{
+		DB:       db,
+		dialect:  d,
+		schema:   s,
+		stmts:    newStmtCache(DefaultStmtCacheSize),
+		scopes:   newScopeRegistry(),
+		replicas: new(replicaSet),
+	}
+}
+
+// Dialect returns the dialect spoken by the database.
+func (db *DB) Dialect() dialect.Dialect {
+	return db.dialect
+}
+
+// Schema returns the schema of the objects stored in the database.
+func (db *DB) Schema() schema.Schema {
+	return db.schema
+}
+
+// Begin starts a transaction sharing the dialect and schema of the DB.
+func (db *DB) Begin() (*Tx, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, db: db}, nil
+}
+
+// The subset of the methods of sql.DB and sql.Tx used to run statements, so
+// that operations can share an implementation between the two.
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// Get the table of the schema for the type of the given object.
+func (db *DB) tableFor(object interface{}) (schema.Table, error) {
+	if db.schema == nil {
+		return nil, &destinationError{
+			badType: reflect.TypeOf(object),
+			msg:     "the DB has no schema"}
+	}
+	return db.schema.TableFor(object)
 }
@@ -0,0 +1,47 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dialect
+
+// Arrayer is implemented by dialects with native array columns. Arrays are
+// bound as array literals in these dialects, and as JSON documents in others.
+//
+// ArrayContains returns a condition holding when the given array column
+// contains every element of the array bound to the given placeholder.
+//
+// ArrayOverlaps returns a condition holding when the given array column
+// shares an element with the array bound to the given placeholder.
+//
+// ArrayAny returns a condition holding when the given array column contains
+// the scalar bound to the given placeholder.
+type Arrayer interface {
+	ArrayContains(column, placeholder string) string
+	ArrayOverlaps(column, placeholder string) string
+	ArrayAny(column, placeholder string) string
+}
+
+// PostgreSQL compares arrays with the @> and && operators.
+func (d *postgresDialect) ArrayContains(column, placeholder string) string {
+	return column + " @> " + placeholder
+}
+
+// PostgreSQL compares arrays with the @> and && operators.
+func (d *postgresDialect) ArrayOverlaps(column, placeholder string) string {
+	return column + " && " + placeholder
+}
+
+// PostgreSQL matches array elements with ANY.
+func (d *postgresDialect) ArrayAny(column, placeholder string) string {
+	return placeholder + " = ANY(" + column + ")"
+}
@@ -0,0 +1,63 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dialect
+
+import (
+	"strings"
+)
+
+// InlineCommenter is implemented by dialects which comment a column within
+// its definition.
+//
+// CommentClause returns the clause appended to a column definition to attach
+// the given comment.
+type InlineCommenter interface {
+	CommentClause(string) string
+}
+
+// StatementCommenter is implemented by dialects which comment a column with
+// a statement of its own.
+//
+// CommentStatement returns the statement attaching the given comment to the
+// given column of the given table.
+type StatementCommenter interface {
+	CommentStatement(table, column, comment string) string
+}
+
+// MySQL comments are part of the column definition. MySQL treats backslashes
+// in string literals as escapes, so these are escaped as well as quotes.
+func (d *mysqlDialect) CommentClause(comment string) string {
+	return "COMMENT " + quoteLiteral(strings.Replace(comment, `\`, `\\`, -1))
+}
+
+// PostgreSQL comments are set with COMMENT ON COLUMN.
+func (d *postgresDialect) CommentStatement(table, column, comment string) string {
+	return "COMMENT ON COLUMN " + d.Quote(table) + "." + d.Quote(column) +
+		" IS " + quoteLiteral(comment)
+}
+
+// Quote the given string as a SQL string literal.
+func quoteLiteral(value string) string {
+	return "'" + strings.Replace(value, "'", "''", -1) + "'"
+}
+
+// Quote the given string as a SQL string literal of the given dialect. MySQL
+// treats backslashes in string literals as escapes, so these are escaped.
+func dialectLiteral(d Dialect, value string) string {
+	if _, ok := d.(*mysqlDialect); ok {
+		value = strings.Replace(value, `\`, `\\`, -1)
+	}
+	return quoteLiteral(value)
+}
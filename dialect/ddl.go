@@ -0,0 +1,155 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dialect
+
+import (
+	"bytes"
+	"github.com/jadengis/icebox/schema"
+	"strings"
+)
+
+// The order in which column constraints are rendered in a column definition.
+// Index is absent as indices are created with their own statement.
+var columnConstraintOrder = []schema.ConstraintType{
+	schema.PrimaryKey,
+	schema.NotNull,
+	schema.Unique,
+	schema.Default,
+	schema.Check,
+	schema.ForeignKey,
+}
+
+// CreateTable returns the statements needed to create the given table in the
+// given dialect. The first statement creates the table itself, and it is
+// followed by a statement for every indexed column, and for every commented
+// column in dialects which comment columns with a statement of their own.
+func CreateTable(d Dialect, table schema.Table) []string {
+	columns := table.Columns()
+	definitions := make([]string, 0, len(columns))
+	for _, column := range columns {
+		definitions = append(definitions, columnDefinition(d, column))
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteString("CREATE TABLE ")
+	buffer.WriteString(d.Quote(table.Name()))
+	buffer.WriteString(" (\n\t")
+	buffer.WriteString(strings.Join(definitions, ",\n\t"))
+	buffer.WriteString("\n)")
+
+	statements := []string{buffer.String()}
+	for _, column := range columns {
+		statements = append(statements, columnStatements(d, table, column)...)
+	}
+	return statements
+}
+
+// AddColumn returns the statements needed to add the given column to an
+// existing table in the given dialect.
+func AddColumn(d Dialect, table schema.Table, column schema.Column) []string {
+	statements := []string{
+		"ALTER TABLE " + d.Quote(table.Name()) +
+			" ADD COLUMN " + columnDefinition(d, column),
+	}
+	return append(statements, columnStatements(d, table, column)...)
+}
+
+// Render the statements which follow the definition of a column, creating
+// its index and setting its comment.
+func columnStatements(d Dialect, table schema.Table, column schema.Column) []string {
+	var statements []string
+	if _, found := column.ConstraintFor(schema.Index); found {
+		statements = append(statements, createIndex(d, table, column))
+	}
+	if commenter, ok := d.(StatementCommenter); ok {
+		if comment, found := column.Metadata()[schema.MetadataComment]; found {
+			statements = append(statements,
+				commenter.CommentStatement(table.Name(), column.Name(), comment))
+		}
+	}
+	return statements
+}
+
+// Render the definition of a column as it appears in a CREATE TABLE statement.
+func columnDefinition(d Dialect, column schema.Column) string {
+	var buffer bytes.Buffer
+	buffer.WriteString(d.Quote(column.Name()))
+	buffer.WriteString(" ")
+	buffer.WriteString(d.TypeName(column.Type()))
+	for _, constraintType := range columnConstraintOrder {
+		if constraint, found := column.ConstraintFor(constraintType); found {
+			buffer.WriteString(" ")
+			buffer.WriteString(constraintClause(d, constraint))
+		}
+	}
+	if check := enumCheckClause(d, column.Name(), column.Type()); check != "" {
+		buffer.WriteString(" ")
+		buffer.WriteString(check)
+	}
+	if commenter, ok := d.(InlineCommenter); ok {
+		if comment, found := column.Metadata()[schema.MetadataComment]; found {
+			buffer.WriteString(" ")
+			buffer.WriteString(commenter.CommentClause(comment))
+		}
+	}
+	return buffer.String()
+}
+
+// Render the clause for a single column constraint.
+func constraintClause(d Dialect, constraint schema.Constraint) string {
+	switch constraint.Type() {
+	case schema.PrimaryKey:
+		return "PRIMARY KEY"
+	case schema.NotNull:
+		return "NOT NULL"
+	case schema.Unique:
+		return "UNIQUE"
+	case schema.Default:
+		return "DEFAULT " + defaultValue(d, constraint.Details())
+	case schema.Check:
+		return "CHECK (" + constraint.Details() + ")"
+	case schema.ForeignKey:
+		return "REFERENCES " + constraint.Details()
+	default:
+		return ""
+	}
+}
+
+// Render the given default value, quoting it again for the dialect if it is
+// a single string literal, as quoted values of tags are.
+func defaultValue(d Dialect, value string) string {
+	if len(value) < 2 || value[0] != '\'' || value[len(value)-1] != '\'' {
+		return value
+	}
+	contents := value[1 : len(value)-1]
+	for i := 0; i < len(contents); i++ {
+		if contents[i] != '\'' {
+			continue
+		}
+		if i+1 == len(contents) || contents[i+1] != '\'' {
+			// The value holds several literals, such as 'a' || 'b'.
+			return value
+		}
+		i++
+	}
+	return dialectLiteral(d, strings.Replace(contents, "''", "'", -1))
+}
+
+// Render the statement creating an index on the given column.
+func createIndex(d Dialect, table schema.Table, column schema.Column) string {
+	name := table.Name() + "_" + column.Name() + "_idx"
+	return "CREATE INDEX " + d.Quote(name) + " ON " + d.Quote(table.Name()) +
+		" (" + d.Quote(column.Name()) + ")"
+}
@@ -0,0 +1,301 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dialect
+
+import (
+	"github.com/jadengis/icebox/schema"
+	"github.com/jadengis/icebox/types"
+	"strings"
+	"testing"
+)
+
+type fakeUser struct {
+	Id    int    `icebox:"column,primaryKey"`
+	Email string `icebox:"column,notNull,unique,index"`
+}
+
+// Generate a table for the fake user for use in the tests.
+func fakeUserTable(t *testing.T) schema.Table {
+	s, err := schema.NewSchema("test_schema", new(fakeUser))
+	if err != nil {
+		t.Fatalf("schema could not be generated: error = %s", err.Error())
+	}
+	table, err := s.TableFor(new(fakeUser))
+	if err != nil {
+		t.Fatalf("table could not be found: error = %s", err.Error())
+	}
+	return table
+}
+
+// Test that the create table statements contain every column definition.
+func TestCreateTable(t *testing.T) {
+	d, err := For("postgres")
+	if err != nil {
+		t.Fatalf("postgres dialect not found: error = %s", err.Error())
+	}
+	statements := CreateTable(d, fakeUserTable(t))
+	if len(statements) != 2 {
+		t.Fatalf("expected a table and index statement: statements = %v", statements)
+	}
+
+	expected := []string{
+		`CREATE TABLE "fake_users"`,
+		`"id" INTEGER PRIMARY KEY`,
+		`"email" VARCHAR(255) NOT NULL UNIQUE`,
+	}
+	for _, fragment := range expected {
+		if !strings.Contains(statements[0], fragment) {
+			t.Errorf("create table is missing %s: statement = %s", fragment, statements[0])
+		}
+	}
+	index := `CREATE INDEX "fake_users_email_idx" ON "fake_users" ("email")`
+	if statements[1] != index {
+		t.Errorf("create index is incorrect: statement = %s, expected = %s",
+			statements[1], index)
+	}
+}
+
+type fakeProfile struct {
+	Id  int    `icebox:"column,primaryKey"`
+	Bio string `icebox:"column"`
+}
+
+// Test that adding a column of a non-pointer field does not make it NOT
+// NULL, which would fail on a table holding rows.
+func TestAddColumn(t *testing.T) {
+	s, err := schema.NewSchema("test_schema", new(fakeProfile))
+	if err != nil {
+		t.Fatalf("schema could not be generated: error = %s", err.Error())
+	}
+	table, _ := s.TableFor(new(fakeProfile))
+	column, _ := table.ColumnFor("bio")
+	d, _ := For("postgres")
+	statements := AddColumn(d, table, column)
+	expected := `ALTER TABLE "fake_profiles" ADD COLUMN "bio" VARCHAR(255)`
+	if len(statements) != 1 || statements[0] != expected {
+		t.Errorf("add column incorrect: statements = %v, expected = %s", statements, expected)
+	}
+}
+
+// Test that unknown drivers have no dialect.
+func TestForUnknownDriver(t *testing.T) {
+	if _, err := For("asdf"); err == nil {
+		t.Errorf("error not raised for unknown driver")
+	} else if !strings.Contains(err.Error(), "asdf") {
+		t.Errorf("raised error doesn't mention the driver: error = %s", err.Error())
+	}
+}
+
+type fakeCommentedUser struct {
+	Id    int    `icebox:"column,primaryKey"`
+	Email string `icebox:"column,comment:'The user''s login'"`
+}
+
+// Test that column comments are rendered inline or as their own statement
+// depending on the dialect.
+func TestColumnComments(t *testing.T) {
+	s, err := schema.NewSchema("test_schema", new(fakeCommentedUser))
+	if err != nil {
+		t.Fatalf("schema could not be generated: error = %s", err.Error())
+	}
+	table, err := s.TableFor(new(fakeCommentedUser))
+	if err != nil {
+		t.Fatalf("table could not be found: error = %s", err.Error())
+	}
+
+	mysql, _ := For("mysql")
+	statements := CreateTable(mysql, table)
+	if len(statements) != 1 {
+		t.Fatalf("expected a single statement: statements = %v", statements)
+	}
+	if !strings.Contains(statements[0], "COMMENT 'The user''s login'") {
+		t.Errorf("mysql comment is missing: statement = %s", statements[0])
+	}
+
+	postgres, _ := For("postgres")
+	statements = CreateTable(postgres, table)
+	comment := `COMMENT ON COLUMN "fake_commented_users"."email" IS 'The user''s login'`
+	if len(statements) != 2 || statements[1] != comment {
+		t.Errorf("postgres comment is incorrect: statements = %v, expected = %s",
+			statements, comment)
+	}
+
+	sqlite, _ := For("sqlite3")
+	if statements = CreateTable(sqlite, table); len(statements) != 1 ||
+		strings.Contains(statements[0], "COMMENT") {
+		t.Errorf("sqlite comment is rendered: statements = %v", statements)
+	}
+}
+
+type fakeQuotedDefaults struct {
+	Id    int    `icebox:"column,primaryKey"`
+	Title string `icebox:"column,default:'it\\'s'"`
+	Path  string `icebox:"column,default:'C:\\\\dir'"`
+}
+
+// Test that string defaults written with backslash escapes are quoted again
+// for each dialect.
+func TestQuotedDefaults(t *testing.T) {
+	s, err := schema.NewSchema("test_schema", new(fakeQuotedDefaults))
+	if err != nil {
+		t.Fatalf("schema could not be generated: error = %s", err.Error())
+	}
+	table, err := s.TableFor(new(fakeQuotedDefaults))
+	if err != nil {
+		t.Fatalf("table could not be found: error = %s", err.Error())
+	}
+
+	testCases := []struct {
+		driver   string
+		expected []string
+	}{
+		{"postgres", []string{`DEFAULT 'it''s'`, `DEFAULT 'C:\dir'`}},
+		{"sqlite3", []string{`DEFAULT 'it''s'`, `DEFAULT 'C:\dir'`}},
+		{"mysql", []string{`DEFAULT 'it''s'`, `DEFAULT 'C:\\dir'`}},
+	}
+	for _, tc := range testCases {
+		d, _ := For(tc.driver)
+		statement := CreateTable(d, table)[0]
+		for _, fragment := range tc.expected {
+			if !strings.Contains(statement, fragment) {
+				t.Errorf("default incorrect: driver = %s, statement = %s, expected = %s",
+					tc.driver, statement, fragment)
+			}
+		}
+	}
+}
+
+// Test that string enums are native in MySQL and checked elsewhere.
+func TestEnumDefinition(t *testing.T) {
+	s, err := schema.Builder().
+		Table("tickets").
+		Column("status", types.Enum, schema.Values("open", "closed")).
+		Column("priority", types.Int, schema.Values("1", "2")).
+		Build("test_schema")
+	if err != nil {
+		t.Fatalf("schema could not be built: error = %s", err.Error())
+	}
+	tickets, _ := s.TableNamed("tickets")
+
+	testCases := []struct {
+		driver    string
+		fragments []string
+	}{
+		{"mysql", []string{
+			"`status` ENUM('open','closed')",
+			"`priority` INT CHECK (`priority` IN (1, 2))"}},
+		{"postgres", []string{
+			`"status" VARCHAR(6) CHECK ("status" IN ('open', 'closed'))`,
+			`"priority" INTEGER CHECK ("priority" IN (1, 2))`}},
+	}
+	for _, tc := range testCases {
+		d, _ := For(tc.driver)
+		statement := CreateTable(d, tickets)[0]
+		for _, fragment := range tc.fragments {
+			if !strings.Contains(statement, fragment) {
+				t.Errorf("%s definition is missing %s: statement = %s",
+					tc.driver, fragment, statement)
+			}
+		}
+	}
+}
+
+// Test that arrays are native in PostgreSQL, and have array operators.
+func TestArrayDefinition(t *testing.T) {
+	s, err := schema.Builder().
+		Table("posts").
+		Column("tags", types.Array).
+		Column("scores", types.Array, schema.Of(types.BigInt)).
+		Build("test_schema")
+	if err != nil {
+		t.Fatalf("schema could not be built: error = %s", err.Error())
+	}
+	posts, _ := s.TableNamed("posts")
+
+	testCases := []struct {
+		driver    string
+		fragments []string
+	}{
+		{"postgres", []string{`"tags" TEXT[]`, `"scores" BIGINT[]`}},
+		{"mysql", []string{"`tags` JSON", "`scores` JSON"}},
+		{"sqlite3", []string{`"tags" TEXT`, `"scores" TEXT`}},
+	}
+	for _, tc := range testCases {
+		d, _ := For(tc.driver)
+		statement := CreateTable(d, posts)[0]
+		for _, fragment := range tc.fragments {
+			if !strings.Contains(statement, fragment) {
+				t.Errorf("%s definition is missing %s: statement = %s",
+					tc.driver, fragment, statement)
+			}
+		}
+	}
+
+	d, _ := For("postgres")
+	arrayer := d.(Arrayer)
+	if condition := arrayer.ArrayAny(`"tags"`, "$1"); condition != `$1 = ANY("tags")` {
+		t.Errorf("any condition incorrect: condition = %s", condition)
+	}
+	if _, ok := interface{}(&mysqlDialect{}).(Arrayer); ok {
+		t.Errorf("mysql dialect has array operators")
+	}
+}
+
+// Test that every dialect renders its own upsert clause.
+func TestUpsertClause(t *testing.T) {
+	testCases := []struct {
+		driver   string
+		update   []string
+		expected string
+	}{
+		{"postgres", []string{"name"}, `ON CONFLICT ("email") DO UPDATE SET "name" = EXCLUDED."name"`},
+		{"postgres", nil, `ON CONFLICT ("email") DO NOTHING`},
+		{"sqlite3", []string{"name"}, `ON CONFLICT ("email") DO UPDATE SET "name" = EXCLUDED."name"`},
+		{"mysql", []string{"name"}, "ON DUPLICATE KEY UPDATE `name` = VALUES(`name`)"},
+		{"mysql", nil, "ON DUPLICATE KEY UPDATE `email` = `email`"},
+	}
+	for _, tc := range testCases {
+		d, _ := For(tc.driver)
+		clause := d.(Upserter).UpsertClause([]string{"email"}, tc.update)
+		if clause != tc.expected {
+			t.Errorf("%s upsert clause incorrect: clause = %s, expected = %s",
+				tc.driver, clause, tc.expected)
+		}
+	}
+
+	guarded := `ON CONFLICT ("email") DO UPDATE SET "name" = EXCLUDED."name" ` +
+		`WHERE "users"."org_id" = EXCLUDED."org_id"`
+	for _, driver := range []string{"postgres", "sqlite3"} {
+		d, _ := For(driver)
+		clause := d.(GuardedUpserter).GuardedUpsertClause("users", []string{"email"}, []string{"name"}, "org_id")
+		if clause != guarded {
+			t.Errorf("%s guarded upsert clause incorrect: clause = %s, expected = %s",
+				driver, clause, guarded)
+		}
+	}
+	if _, ok := interface{}(&mysqlDialect{}).(GuardedUpserter); ok {
+		t.Errorf("mysql dialect has guarded upserts")
+	}
+	sqliteReturner, _ := For("sqlite3")
+	if clause := sqliteReturner.(UpsertReturner).UpsertReturningClause("id"); clause != `RETURNING "id"` {
+		t.Errorf("sqlite upsert returning clause incorrect: clause = %s", clause)
+	}
+
+	sqlite, _ := For("sqlite3")
+	if limit := MaxParameters(sqlite); limit != DefaultMaxParameters {
+		t.Errorf("sqlite parameter limit incorrect: limit = %d, expected = %d",
+			limit, DefaultMaxParameters)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dialect
+
+import (
+	"github.com/jadengis/icebox/types"
+)
+
+// Dialect describes the SQL flavour spoken by a particular database driver.
+// The schema package describes tables in terms of abstract icebox types, and
+// a Dialect is responsible for mapping these onto concrete SQL.
+//
+// Name returns the name of the dialect.
+//
+// Quote returns the given identifier quoted for use in a SQL statement.
+//
+// Placeholder returns the bind parameter placeholder for the n-th (1-based)
+// argument of a statement.
+//
+// TypeName returns the concrete column type for the given SQLType.
+//
+// ColumnsQuery returns a query selecting the names of the columns of an
+// existing table. The query takes the table name as its only argument.
+type Dialect interface {
+	Name() string
+	Quote(string) string
+	Placeholder(int) string
+	TypeName(types.SQLType) string
+	ColumnsQuery() string
+}
+
+// Mapping between driver names and the dialect they speak.
+var dialects = map[string]Dialect{
+	"sqlite3":  &sqliteDialect{},
+	"mysql":    &mysqlDialect{},
+	"postgres": &postgresDialect{},
+	"pgx":      &postgresDialect{},
+}
+
+// For returns the Dialect spoken by the given database/sql driver name.
+// This returns an error if the driver is not known to icebox.
+func For(driver string) (Dialect, error) {
+	dialect, found := dialects[driver]
+	if !found {
+		return nil, &unknownDialectError{
+			driver: driver,
+			msg:    "no dialect registered for driver"}
+	}
+	return dialect, nil
+}
+
+// Returns the type name with the size of the given type appended, if the type
+// has one.
+func withSize(name string, sqlType types.SQLType) string {
+	if size := sqlType.Size(); size != "" {
+		return name + "(" + size + ")"
+	}
+	return name
+}
@@ -0,0 +1,70 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dialect
+
+import (
+	"github.com/jadengis/icebox/types"
+	"strconv"
+	"strings"
+)
+
+// Enumerator is implemented by dialects with a native ENUM type. Other
+// dialects store string enums as a VarChar restricted by a CHECK constraint.
+//
+// EnumType returns the column type permitting only the given values.
+type Enumerator interface {
+	EnumType([]string) string
+}
+
+// MySQL has a native ENUM type.
+func (d *mysqlDialect) EnumType(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, value := range values {
+		quoted = append(quoted, quoteLiteral(strings.Replace(value, `\`, `\\`, -1)))
+	}
+	return "ENUM(" + strings.Join(quoted, ",") + ")"
+}
+
+// Returns the type name of a string enum in dialects without a native ENUM
+// type, which is a VARCHAR long enough for the longest value.
+func enumVarChar(sqlType types.SQLType) string {
+	size := 1
+	for _, value := range sqlType.Values() {
+		if len(value) > size {
+			size = len(value)
+		}
+	}
+	return "VARCHAR(" + strconv.Itoa(size) + ")"
+}
+
+// Render the CHECK clause restricting the given column to the values of its
+// type, or the empty string if the dialect restricts the values natively.
+func enumCheckClause(d Dialect, column string, sqlType types.SQLType) string {
+	values := sqlType.Values()
+	if len(values) == 0 {
+		return ""
+	}
+	if _, ok := d.(Enumerator); ok && sqlType.Type() == types.Enum {
+		return ""
+	}
+	literals := make([]string, 0, len(values))
+	for _, value := range values {
+		if sqlType.Type() == types.Enum {
+			value = quoteLiteral(value)
+		}
+		literals = append(literals, value)
+	}
+	return "CHECK (" + d.Quote(column) + " IN (" + strings.Join(literals, ", ") + "))"
+}
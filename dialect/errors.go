@@ -0,0 +1,30 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dialect
+
+import (
+	"fmt"
+)
+
+// Error type for a driver with no corresponding dialect.
+type unknownDialectError struct {
+	driver string
+	msg    string
+}
+
+// Produce an error message for an unknownDialectError.
+func (e *unknownDialectError) Error() string {
+	return fmt.Sprintf("unknown dialect: driver = %s, msg = %s", e.driver, e.msg)
+}
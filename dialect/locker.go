@@ -0,0 +1,53 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dialect
+
+// Locker is implemented by dialects which support session level advisory
+// locks. The lock is held by the connection which ran the lock statement
+// until it runs the unlock statement or is closed.
+//
+// LockQuery returns a query acquiring the lock named by its only argument,
+// blocking until the lock is available. The query returns a single value,
+// which is 1 if the lock was acquired.
+//
+// UnlockQuery returns a statement releasing the lock named by its only
+// argument.
+type Locker interface {
+	LockQuery() string
+	UnlockQuery() string
+}
+
+// MySQL locks are acquired with GET_LOCK, waiting indefinitely. GET_LOCK
+// returns 0 or NULL if the lock could not be acquired.
+func (d *mysqlDialect) LockQuery() string {
+	return "SELECT GET_LOCK(?, -1)"
+}
+
+// MySQL locks are released with RELEASE_LOCK.
+func (d *mysqlDialect) UnlockQuery() string {
+	return "SELECT RELEASE_LOCK(?)"
+}
+
+// PostgreSQL advisory locks are keyed by integer, so the lock name is hashed.
+// pg_advisory_lock returns void, and fails if the lock could not be
+// acquired, so the query selects 1 from its result instead.
+func (d *postgresDialect) LockQuery() string {
+	return "SELECT 1 FROM pg_advisory_lock(hashtext($1))"
+}
+
+// PostgreSQL advisory locks are released with the same hashed key.
+func (d *postgresDialect) UnlockQuery() string {
+	return "SELECT pg_advisory_unlock(hashtext($1))"
+}
@@ -0,0 +1,85 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dialect
+
+import (
+	"github.com/jadengis/icebox/types"
+)
+
+// The Dialect implementation for MySQL.
+type mysqlDialect struct{}
+
+// Returns the name of the MySQL dialect.
+func (d *mysqlDialect) Name() string {
+	return "mysql"
+}
+
+// MySQL identifiers are quoted with backticks.
+func (d *mysqlDialect) Quote(name string) string {
+	return "`" + name + "`"
+}
+
+// MySQL uses anonymous placeholders.
+func (d *mysqlDialect) Placeholder(n int) string {
+	return "?"
+}
+
+// The icebox types are modelled after MySQL, so this is mostly a one to one
+// mapping. MySQL has no array type, so arrays are stored as JSON.
+func (d *mysqlDialect) TypeName(sqlType types.SQLType) string {
+	if sqlType.Type() == types.Enum {
+		return d.EnumType(sqlType.Values())
+	}
+	return withSize(mysqlTypeNames[sqlType.Type()], sqlType)
+}
+
+// Column names are read from the information schema of the current database.
+func (d *mysqlDialect) ColumnsQuery() string {
+	return "SELECT column_name FROM information_schema.columns " +
+		"WHERE table_schema = DATABASE() AND table_name = ?"
+}
+
+// Mapping between icebox types and MySQL type names.
+var mysqlTypeNames = map[types.IceboxType]string{
+	types.Char:       "CHAR",
+	types.VarChar:    "VARCHAR",
+	types.Text:       "TEXT",
+	types.MediumText: "MEDIUMTEXT",
+	types.LongText:   "LONGTEXT",
+	types.Blob:       "BLOB",
+	types.MediumBlob: "MEDIUMBLOB",
+	types.LongBlob:   "LONGBLOB",
+	types.Bit:        "BIT",
+	types.TinyInt:    "TINYINT",
+	types.TinyUint:   "TINYINT UNSIGNED",
+	types.SmallInt:   "SMALLINT",
+	types.SmallUint:  "SMALLINT UNSIGNED",
+	types.MediumInt:  "MEDIUMINT",
+	types.MediumUint: "MEDIUMINT UNSIGNED",
+	types.Int:        "INT",
+	types.Uint:       "INT UNSIGNED",
+	types.BigInt:     "BIGINT",
+	types.BigUint:    "BIGINT UNSIGNED",
+	types.Float:      "FLOAT",
+	types.Double:     "DOUBLE",
+	types.Decimal:    "DECIMAL",
+	types.Date:       "DATE",
+	types.DateTime:   "DATETIME",
+	types.TimeStamp:  "TIMESTAMP",
+	types.Time:       "TIME",
+	types.Year:       "YEAR",
+	types.JSON:       "JSON",
+	types.Array:      "JSON",
+}
@@ -0,0 +1,85 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dialect
+
+import (
+	"github.com/jadengis/icebox/types"
+	"strconv"
+)
+
+// The Dialect implementation for PostgreSQL.
+type postgresDialect struct{}
+
+// Returns the name of the PostgreSQL dialect.
+func (d *postgresDialect) Name() string {
+	return "postgres"
+}
+
+// PostgreSQL identifiers are quoted with double quotes.
+func (d *postgresDialect) Quote(name string) string {
+	return `"` + name + `"`
+}
+
+// PostgreSQL uses numbered placeholders.
+func (d *postgresDialect) Placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// PostgreSQL has no unsigned or medium integer types, so these are widened to
+// the next integer type which can hold all their values.
+func (d *postgresDialect) TypeName(sqlType types.SQLType) string {
+	switch sqlType.Type() {
+	case types.Char:
+		return withSize("CHAR", sqlType)
+	case types.VarChar:
+		return withSize("VARCHAR", sqlType)
+	case types.Text, types.MediumText, types.LongText:
+		return "TEXT"
+	case types.Blob, types.MediumBlob, types.LongBlob:
+		return "BYTEA"
+	case types.Bit:
+		return "BOOLEAN"
+	case types.TinyInt, types.TinyUint, types.SmallInt, types.Year:
+		return "SMALLINT"
+	case types.SmallUint, types.MediumInt, types.MediumUint, types.Int:
+		return "INTEGER"
+	case types.Float:
+		return "REAL"
+	case types.Double:
+		return "DOUBLE PRECISION"
+	case types.Decimal:
+		return withSize("NUMERIC", sqlType)
+	case types.Date:
+		return "DATE"
+	case types.DateTime, types.TimeStamp:
+		return "TIMESTAMP"
+	case types.Time:
+		return "TIME"
+	case types.JSON:
+		return "JSONB"
+	case types.Enum:
+		return enumVarChar(sqlType)
+	case types.Array:
+		return d.TypeName(sqlType.Elem()) + "[]"
+	default:
+		return "BIGINT"
+	}
+}
+
+// Column names are read from the information schema of the current schema.
+func (d *postgresDialect) ColumnsQuery() string {
+	return "SELECT column_name FROM information_schema.columns " +
+		"WHERE table_schema = current_schema() AND table_name = $1"
+}
@@ -0,0 +1,31 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dialect
+
+// Returner is implemented by dialects which read generated values back from
+// an INSERT statement with a RETURNING clause, rather than through
+// sql.Result.LastInsertId.
+//
+// ReturningClause returns the clause appended to an INSERT statement to
+// return the given column of the inserted row.
+type Returner interface {
+	ReturningClause(string) string
+}
+
+// PostgreSQL drivers don't support LastInsertId, so generated keys are
+// returned by the INSERT statement itself.
+func (d *postgresDialect) ReturningClause(column string) string {
+	return "RETURNING " + d.Quote(column)
+}
@@ -0,0 +1,72 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dialect
+
+import (
+	"github.com/jadengis/icebox/types"
+)
+
+// The Dialect implementation for SQLite.
+type sqliteDialect struct{}
+
+// Returns the name of the SQLite dialect.
+func (d *sqliteDialect) Name() string {
+	return "sqlite3"
+}
+
+// SQLite identifiers are quoted with double quotes.
+func (d *sqliteDialect) Quote(name string) string {
+	return `"` + name + `"`
+}
+
+// SQLite uses anonymous placeholders.
+func (d *sqliteDialect) Placeholder(n int) string {
+	return "?"
+}
+
+// SQLite only has a handful of storage classes, so map each icebox type onto
+// the type name with the appropriate affinity. JSON documents and arrays are
+// stored as text.
+func (d *sqliteDialect) TypeName(sqlType types.SQLType) string {
+	switch sqlType.Type() {
+	case types.Char, types.VarChar:
+		return withSize("VARCHAR", sqlType)
+	case types.Enum:
+		return enumVarChar(sqlType)
+	case types.Text, types.MediumText, types.LongText, types.JSON, types.Array:
+		return "TEXT"
+	case types.Blob, types.MediumBlob, types.LongBlob:
+		return "BLOB"
+	case types.Bit:
+		return "BOOLEAN"
+	case types.Float, types.Double:
+		return "REAL"
+	case types.Decimal:
+		return "NUMERIC"
+	case types.Date:
+		return "DATE"
+	case types.DateTime, types.TimeStamp:
+		return "DATETIME"
+	case types.Time:
+		return "TIME"
+	default:
+		return "INTEGER"
+	}
+}
+
+// Column names are read from the table_info pragma.
+func (d *sqliteDialect) ColumnsQuery() string {
+	return "SELECT name FROM pragma_table_info(?)"
+}
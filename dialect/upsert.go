@@ -0,0 +1,149 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dialect
+
+import (
+	"strings"
+)
+
+// Upserter is implemented by dialects which can update the conflicting row
+// when an INSERT violates a unique constraint.
+//
+// UpsertClause returns the clause appended to an INSERT statement to update
+// the given columns of the existing row when the insert conflicts on the
+// given columns, or to leave the row untouched if no columns are updated.
+type Upserter interface {
+	UpsertClause(conflict []string, update []string) string
+}
+
+// GuardedUpserter is implemented by Upserters which can restrict the update
+// of a conflicting row to rows whose guard column equals that of the
+// proposed row, so that an upsert of a tenant cannot update the row of
+// another.
+//
+// GuardedUpsertClause returns the clause of UpsertClause, updating the
+// conflicting row of the given table only if the guard columns are equal.
+// The row is left untouched otherwise.
+type GuardedUpserter interface {
+	GuardedUpsertClause(table string, conflict []string, update []string, guard string) string
+}
+
+// UpsertReturner is implemented by Upserters which read generated keys back
+// from an INSERT statement through sql.Result.LastInsertId, which does not
+// hold the key of the row an upsert updated or left untouched, but which can
+// return the key of an upserted row with a RETURNING clause.
+//
+// UpsertReturningClause returns the clause appended to an upsert to return
+// the given column of the upserted row.
+type UpsertReturner interface {
+	UpsertReturningClause(string) string
+}
+
+// ParameterLimiter is implemented by dialects which bound the number of bind
+// parameters of a single statement. Dialects which don't are assumed to
+// accept DefaultMaxParameters.
+//
+// MaxParameters returns the largest number of parameters of a statement.
+type ParameterLimiter interface {
+	MaxParameters() int
+}
+
+// DefaultMaxParameters is the parameter limit assumed for dialects which are
+// not ParameterLimiters. This is the limit of older SQLite versions, the
+// lowest in common use.
+const DefaultMaxParameters = 999
+
+// MaxParameters returns the parameter limit of the given dialect.
+func MaxParameters(d Dialect) int {
+	if limiter, ok := d.(ParameterLimiter); ok {
+		return limiter.MaxParameters()
+	}
+	return DefaultMaxParameters
+}
+
+// MySQL updates on any duplicate key, so the conflict columns are only used
+// to leave the row untouched when no columns are updated.
+func (d *mysqlDialect) UpsertClause(conflict []string, update []string) string {
+	assignments := make([]string, 0, len(update))
+	for _, column := range update {
+		assignments = append(assignments, d.Quote(column)+" = VALUES("+d.Quote(column)+")")
+	}
+	if len(assignments) == 0 {
+		assignments = append(assignments, d.Quote(conflict[0])+" = "+d.Quote(conflict[0]))
+	}
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(assignments, ", ")
+}
+
+// MySQL prepared statements take up to 65535 parameters.
+func (d *mysqlDialect) MaxParameters() int {
+	return 65535
+}
+
+// PostgreSQL upserts with ON CONFLICT, referring to the proposed row as
+// EXCLUDED.
+func (d *postgresDialect) UpsertClause(conflict []string, update []string) string {
+	return onConflictClause(d, conflict, update)
+}
+
+// PostgreSQL guards the update with a WHERE clause.
+func (d *postgresDialect) GuardedUpsertClause(table string, conflict []string, update []string, guard string) string {
+	return guardedOnConflictClause(d, table, conflict, update, guard)
+}
+
+// The PostgreSQL wire protocol counts parameters with 16 bits.
+func (d *postgresDialect) MaxParameters() int {
+	return 65535
+}
+
+// SQLite borrowed ON CONFLICT from PostgreSQL.
+func (d *sqliteDialect) UpsertClause(conflict []string, update []string) string {
+	return onConflictClause(d, conflict, update)
+}
+
+// SQLite 3.35 and later return the upserted row with RETURNING.
+func (d *sqliteDialect) UpsertReturningClause(column string) string {
+	return "RETURNING " + d.Quote(column)
+}
+
+// SQLite guards the update with a WHERE clause, as PostgreSQL does.
+func (d *sqliteDialect) GuardedUpsertClause(table string, conflict []string, update []string, guard string) string {
+	return guardedOnConflictClause(d, table, conflict, update, guard)
+}
+
+// Render an ON CONFLICT clause in the PostgreSQL style.
+func onConflictClause(d Dialect, conflict []string, update []string) string {
+	targets := make([]string, 0, len(conflict))
+	for _, column := range conflict {
+		targets = append(targets, d.Quote(column))
+	}
+	clause := "ON CONFLICT (" + strings.Join(targets, ", ") + ") "
+	if len(update) == 0 {
+		return clause + "DO NOTHING"
+	}
+	assignments := make([]string, 0, len(update))
+	for _, column := range update {
+		assignments = append(assignments, d.Quote(column)+" = EXCLUDED."+d.Quote(column))
+	}
+	return clause + "DO UPDATE SET " + strings.Join(assignments, ", ")
+}
+
+// Render an ON CONFLICT clause whose update is guarded by the given column.
+func guardedOnConflictClause(d Dialect, table string, conflict []string, update []string, guard string) string {
+	clause := onConflictClause(d, conflict, update)
+	if len(update) == 0 {
+		return clause
+	}
+	return clause + " WHERE " + d.Quote(table) + "." + d.Quote(guard) + " = EXCLUDED." + d.Quote(guard)
+}
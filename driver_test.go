@@ -0,0 +1,94 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icebox
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"github.com/jadengis/icebox/dialect"
+	"github.com/jadengis/icebox/internal/fakedb"
+	"github.com/jadengis/icebox/schema"
+	"testing"
+)
+
+// The fake database the package is tested against. Queries return the
+// result sets queued with fakeQueue in order, every statement run is
+// recorded, prepares are counted, and every Exec reports fakeInsertId as the
+// last insert id.
+var fakeDatabase = fakedb.New("")
+
+// The last insert id reported by the fake database.
+const fakeInsertId = 42
+
+// A result set returned by the fake database.
+type fakeResultSet struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+// A statement run against the fake database, with its arguments and the
+// data source name of the connection it was run on.
+type fakeStatement struct {
+	query  string
+	args   []driver.Value
+	source string
+}
+
+// Reset the fake database and queue the given result sets.
+func fakeQueue(results ...fakeResultSet) {
+	fakeDatabase.Lock()
+	defer fakeDatabase.Unlock()
+	fakeDatabase.Results = nil
+	for _, result := range results {
+		fakeDatabase.Results = append(fakeDatabase.Results,
+			fakedb.ResultSet{Columns: result.columns, Rows: result.rows})
+	}
+	fakeDatabase.Statements = nil
+	fakeDatabase.Prepared = 0
+	fakeDatabase.InsertId = fakeInsertId
+	fakeDatabase.RowsAffected = 1
+}
+
+// Get the statements run against the fake database since the last reset.
+func fakeStatements() []fakeStatement {
+	fakeDatabase.Lock()
+	defer fakeDatabase.Unlock()
+	var statements []fakeStatement
+	for _, statement := range fakeDatabase.Statements {
+		statements = append(statements, fakeStatement{statement.Query, statement.Args, statement.Source})
+	}
+	return statements
+}
+
+// Get the number of statements prepared on the fake database since the last
+// reset.
+func fakePrepared() int {
+	fakeDatabase.Lock()
+	defer fakeDatabase.Unlock()
+	return fakeDatabase.Prepared
+}
+
+// Open a DB on the fake database speaking the dialect of the given driver.
+func openFake(t testing.TB, driver string, s schema.Schema) *DB {
+	db, err := sql.Open(fakedb.DriverName, "")
+	if err != nil {
+		t.Fatalf("fake database could not be opened: error = %s", err.Error())
+	}
+	d, err := dialect.For(driver)
+	if err != nil {
+		t.Fatalf("dialect not found: error = %s", err.Error())
+	}
+	return NewDB(db, d, s)
+}
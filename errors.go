@@ -0,0 +1,65 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icebox
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Error type for a destination which doesn't match the table it is used with.
+type destinationError struct {
+	badType reflect.Type
+	msg     string
+}
+
+// Produce an error message for a destinationError.
+func (e *destinationError) Error() string {
+	return fmt.Sprintf("bad destination %s : %s", e.badType, e.msg)
+}
+
+// Error type wrapping a failure to read or write a column of a row.
+type columnError struct {
+	cause  error
+	column string
+	msg    string
+}
+
+// Produce an error message for a columnError.
+func (e *columnError) Error() string {
+	return fmt.Sprintf("%s : column = %s : %s", e.msg, e.column, e.cause.Error())
+}
+
+// Error type for a value written to an enum column which is not one of the
+// values of the enum.
+type enumError struct {
+	value  string
+	values []string
+}
+
+// Produce an error message for an enumError.
+func (e *enumError) Error() string {
+	return fmt.Sprintf("value %q is not one of the enum values %v", e.value, e.values)
+}
+
+// Error type for a query which cannot be built.
+type queryError struct {
+	msg string
+}
+
+// Produce an error message for a queryError.
+func (e *queryError) Error() string {
+	return fmt.Sprintf("bad query : %s", e.msg)
+}
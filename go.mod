@@ -0,0 +1,3 @@
+module github.com/jadengis/icebox
+
+go 1.16
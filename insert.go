@@ -0,0 +1,530 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icebox
+
+import (
+	"bytes"
+	"database/sql"
+	"github.com/jadengis/icebox/dialect"
+	"github.com/jadengis/icebox/schema"
+	"reflect"
+)
+
+// Insert inserts the given object, which must be a pointer to a struct of a
+// type in the schema of the DB, as a new row of its table.
+//
+// A primary key left at its zero value is omitted from the statement so that
+// the database generates it, and the generated key is written back to the
+// object.
+func (db *DB) Insert(object interface{}) error {
+	return insert(db.queryer(), db, object, false)
+}
+
+// Insert inserts the given object within the transaction, as DB.Insert does.
+func (tx *Tx) Insert(object interface{}) error {
+	return insert(tx.queryer(), tx.db, object, false)
+}
+
+// InsertMany inserts the given objects, which must be a slice of structs or
+// of pointers to structs of a type in the schema of the DB, with multi-row
+// INSERT statements. The rows are split into as many statements as needed to
+// respect the parameter limit of the dialect, which are run in a transaction.
+//
+// A primary key left at its zero value is omitted, as for Insert, with the
+// objects whose key is set inserted by statements of their own. The generated
+// keys are only written back to the objects in dialects which return them
+// from the INSERT statement, such as PostgreSQL.
+func (db *DB) InsertMany(objects interface{}) error {
+	return db.inTransaction(func(tx *Tx) error {
+		return tx.InsertMany(objects)
+	})
+}
+
+// InsertMany inserts the given objects within the transaction, as
+// DB.InsertMany does.
+func (tx *Tx) InsertMany(objects interface{}) error {
+	return insertMany(tx.queryer(), tx.db, objects, false)
+}
+
+// Upsert inserts the given object as Insert does, or updates the existing
+// row if the insert conflicts with it. The conflict target is the primary key
+// when it is set, and otherwise the first column with a unique constraint.
+// Every other column of the existing row is updated, but for the tenant
+// column. On a table with a tenant column, the existing row is only updated
+// if it belongs to the tenant of the object, and is left untouched otherwise.
+//
+// A generated key is written back to the object if the row was inserted, or
+// if it was updated in a dialect returning the key of the upserted row, such
+// as PostgreSQL and SQLite. When no column is left to update, a conflicting
+// row is left untouched, and the key of the object is left as it is.
+//
+// This returns an error if the dialect cannot upsert, or cannot guard the
+// update of a table with a tenant column, as MySQL cannot, or if the table
+// has no suitable conflict target.
+func (db *DB) Upsert(object interface{}) error {
+	return insert(db.queryer(), db, object, true)
+}
+
+// Upsert upserts the given object within the transaction, as DB.Upsert does.
+func (tx *Tx) Upsert(object interface{}) error {
+	return insert(tx.queryer(), tx.db, object, true)
+}
+
+// UpsertMany upserts the given objects, as InsertMany inserts them. When no
+// column is left to update, or the table has a tenant column, generated keys
+// are not written back, as the rows left untouched cannot be told apart from
+// those inserted.
+func (db *DB) UpsertMany(objects interface{}) error {
+	return db.inTransaction(func(tx *Tx) error {
+		return tx.UpsertMany(objects)
+	})
+}
+
+// UpsertMany upserts the given objects within the transaction, as
+// DB.UpsertMany does.
+func (tx *Tx) UpsertMany(objects interface{}) error {
+	return insertMany(tx.queryer(), tx.db, objects, true)
+}
+
+// Run the given function in a transaction, which is committed if the
+// function succeeds and rolled back otherwise.
+func (db *DB) inTransaction(fn func(*Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Insert or upsert the given object through the given queryer.
+func insert(q queryer, db *DB, object interface{}, upsert bool) error {
+	table, err := db.tableFor(object)
+	if err != nil {
+		return err
+	}
+	value, err := destinationValue(table, object)
+	if err != nil {
+		return err
+	}
+	rows := []reflect.Value{value}
+	if err := db.applyTenant(table, rows); err != nil {
+		return err
+	}
+	plan, err := newInsertPlan(db, table, rows, upsert)
+	if err != nil {
+		return err
+	}
+	query := plan.single
+	args, err := plan.args(rows)
+	if err != nil {
+		return err
+	}
+
+	if plan.generated == nil {
+		_, err = q.Exec(query, args...)
+		return err
+	}
+	key := fieldByIndex(value, plan.generated.index)
+	if plan.returning != "" {
+		err := q.QueryRow(query+" "+plan.returning, args...).Scan(key.Addr().Interface())
+		if err == sql.ErrNoRows && plan.skips {
+			// The row conflicted and was left untouched, so nothing was
+			// returned.
+			return nil
+		}
+		return err
+	}
+	result, err := q.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	if upsert {
+		// The last insert id only holds the key of the row if it was
+		// inserted, which MySQL reports as a single affected row, rather
+		// than two for an update or none for a row left untouched.
+		if affected, err := result.RowsAffected(); err != nil || affected != 1 {
+			return err
+		}
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	setInt(key, id)
+	return nil
+}
+
+// Insert or upsert the given slice of objects through the given queryer, in
+// chunks respecting the parameter limit of the dialect.
+func insertMany(q queryer, db *DB, objects interface{}, upsert bool) error {
+	slice := reflect.ValueOf(objects)
+	if slice.Kind() != reflect.Slice {
+		return &destinationError{
+			badType: reflect.TypeOf(objects),
+			msg:     "objects must be a slice"}
+	}
+	if slice.Len() == 0 {
+		return nil
+	}
+	elemType := slice.Type().Elem()
+	table, err := db.tableFor(reflect.New(getConcreteType(elemType)).Interface())
+	if err != nil {
+		return err
+	}
+	rows := make([]reflect.Value, 0, slice.Len())
+	for i := 0; i < slice.Len(); i++ {
+		row := reflect.Indirect(slice.Index(i))
+		if !row.IsValid() {
+			return &destinationError{badType: elemType, msg: "objects cannot hold nil pointers"}
+		}
+		rows = append(rows, row)
+	}
+	if err := db.applyTenant(table, rows); err != nil {
+		return err
+	}
+	for _, group := range splitByKey(table, rows) {
+		if err := insertChunks(q, db, table, group, upsert); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Split the given rows of the given table into those whose primary key is
+// set and those whose key is generated, as a single statement cannot both
+// write and omit the key. Empty groups are left out.
+func splitByKey(table schema.Table, rows []reflect.Value) [][]reflect.Value {
+	primaryKey := planFor(table).primaryKey
+	if primaryKey == nil {
+		return [][]reflect.Value{rows}
+	}
+	var set, generated []reflect.Value
+	for _, row := range rows {
+		if isGeneratedInAll(primaryKey, []reflect.Value{row}) {
+			generated = append(generated, row)
+		} else {
+			set = append(set, row)
+		}
+	}
+	var groups [][]reflect.Value
+	for _, group := range [][]reflect.Value{set, generated} {
+		if len(group) > 0 {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// Insert or upsert the given rows through the given queryer, in chunks
+// respecting the parameter limit of the dialect.
+func insertChunks(q queryer, db *DB, table schema.Table, rows []reflect.Value, upsert bool) error {
+	plan, err := newInsertPlan(db, table, rows, upsert)
+	if err != nil {
+		return err
+	}
+
+	chunkSize := len(rows)
+	if len(plan.fields) > 0 {
+		chunkSize = dialect.MaxParameters(db.dialect) / len(plan.fields)
+	}
+	if chunkSize < 1 {
+		// A single row exceeding the limit is left for the database to
+		// reject.
+		chunkSize = 1
+	}
+	for start := 0; start < len(rows); start += chunkSize {
+		end := start + chunkSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		if err := plan.run(q, rows[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The key of an insert plan in the plan of its table.
+type insertKey struct {
+	dialect   string
+	schema    string
+	generated bool
+	upsert    bool
+}
+
+// The shape of an INSERT statement shared by a set of rows. Insert plans are
+// cached in the plan of their table, so they must not be modified once
+// built.
+//
+// TableName is the quoted name of the table, qualified by the schema of the
+// tenant if it has one.
+//
+// Fields are the fields of the columns written by the statement.
+//
+// Generated is the field of the primary key omitted so that the database
+// generates it, if any.
+//
+// Returning is the clause returning the generated key, if the dialect
+// supports one.
+//
+// Conflict is the upsert clause, if the statement is an upsert.
+//
+// Skips is set when the upsert leaves conflicting rows untouched, so that
+// they are not returned by the returning clause.
+//
+// Single is the statement inserting a single row.
+type insertPlan struct {
+	dialect   dialect.Dialect
+	table     schema.Table
+	tableName string
+	fields    []*fieldPlan
+	generated *fieldPlan
+	returning string
+	conflict  string
+	skips     bool
+	single    string
+}
+
+// Get the plan of the insert of the given rows into the given table. The
+// primary key is generated if it is at its zero value in every row.
+func newInsertPlan(db *DB, table schema.Table, rows []reflect.Value, upsert bool) (*insertPlan, error) {
+	d := db.dialect
+	tablePlan := planFor(table)
+	primaryKey := tablePlan.primaryKey
+	key := insertKey{
+		dialect:   d.Name(),
+		schema:    db.tenancy.schema,
+		generated: primaryKey != nil && isGeneratedInAll(primaryKey, rows),
+		upsert:    upsert,
+	}
+	tablePlan.mutex.Lock()
+	plan, found := tablePlan.inserts[key]
+	tablePlan.mutex.Unlock()
+	if found {
+		return plan, nil
+	}
+
+	plan = &insertPlan{dialect: d, table: table, tableName: db.tableName(table)}
+	if key.generated {
+		plan.generated = primaryKey
+	}
+	for _, field := range tablePlan.fields {
+		if field != plan.generated {
+			plan.fields = append(plan.fields, field)
+		}
+	}
+	if returner, ok := d.(dialect.Returner); ok && plan.generated != nil {
+		plan.returning = returner.ReturningClause(plan.generated.column.Name())
+	}
+	if returner, ok := d.(dialect.UpsertReturner); ok && upsert && plan.generated != nil {
+		plan.returning = returner.UpsertReturningClause(plan.generated.column.Name())
+	}
+	if upsert {
+		conflict, err := plan.upsertClause()
+		if err != nil {
+			return nil, err
+		}
+		plan.conflict = conflict
+	}
+	plan.single = plan.statement(1)
+
+	tablePlan.mutex.Lock()
+	tablePlan.inserts[key] = plan
+	tablePlan.mutex.Unlock()
+	return plan, nil
+}
+
+// Build the upsert clause of the plan, conflicting on the primary key if it
+// is written, and on the first unique column otherwise. The update is
+// guarded by the tenant column, if the table has one. This marks the plan as
+// skipping conflicting rows if no column is left to update or the update is
+// guarded.
+func (p *insertPlan) upsertClause() (string, error) {
+	upserter, ok := p.dialect.(dialect.Upserter)
+	if !ok {
+		return "", &destinationError{
+			badType: p.table.Type(),
+			msg:     "dialect " + p.dialect.Name() + " cannot upsert"}
+	}
+	var target *fieldPlan
+	for _, field := range p.fields {
+		if field.primaryKey {
+			target = field
+			break
+		}
+	}
+	for _, field := range p.fields {
+		if field.unique && target == nil {
+			target = field
+		}
+	}
+	if target == nil {
+		return "", &destinationError{
+			badType: p.table.Type(),
+			msg:     "table " + p.table.Name() + " has no unique column to upsert on"}
+	}
+
+	var update []string
+	var tenant *fieldPlan
+	for _, field := range p.fields {
+		switch {
+		case field.tenant:
+			tenant = field
+		case field != target && !field.primaryKey:
+			update = append(update, field.column.Name())
+		}
+	}
+	conflict := []string{target.column.Name()}
+	if tenant == nil {
+		p.skips = len(update) == 0
+		return upserter.UpsertClause(conflict, update), nil
+	}
+	guarded, ok := p.dialect.(dialect.GuardedUpserter)
+	if !ok {
+		return "", &destinationError{
+			badType: p.table.Type(),
+			msg:     "dialect " + p.dialect.Name() + " cannot upsert rows of a tenant"}
+	}
+	p.skips = true
+	return guarded.GuardedUpsertClause(p.table.Name(), conflict, update, tenant.column.Name()), nil
+}
+
+// Run the statement of the plan for the given rows, writing back generated
+// keys if the dialect returns them and no row is skipped.
+func (p *insertPlan) run(q queryer, rows []reflect.Value) error {
+	query := p.single
+	if len(rows) > 1 {
+		query = p.statement(len(rows))
+	}
+	args, err := p.args(rows)
+	if err != nil {
+		return err
+	}
+	if p.returning == "" || p.skips {
+		_, err = q.Exec(query, args...)
+		return err
+	}
+
+	results, err := q.Query(query+" "+p.returning, args...)
+	if err != nil {
+		return err
+	}
+	defer results.Close()
+	for i := 0; results.Next() && i < len(rows); i++ {
+		key := fieldByIndex(rows[i], p.generated.index)
+		if err := results.Scan(key.Addr().Interface()); err != nil {
+			return err
+		}
+	}
+	return results.Err()
+}
+
+// Render the statement of the plan for the given number of rows.
+func (p *insertPlan) statement(rowCount int) string {
+	d := p.dialect
+	var buffer bytes.Buffer
+	buffer.WriteString("INSERT INTO ")
+	buffer.WriteString(p.tableName)
+	buffer.WriteString(" (")
+	for i, field := range p.fields {
+		if i > 0 {
+			buffer.WriteString(", ")
+		}
+		buffer.WriteString(d.Quote(field.column.Name()))
+	}
+	buffer.WriteString(") VALUES ")
+	n := 0
+	for row := 0; row < rowCount; row++ {
+		if row > 0 {
+			buffer.WriteString(", ")
+		}
+		buffer.WriteString("(")
+		for i := range p.fields {
+			if i > 0 {
+				buffer.WriteString(", ")
+			}
+			n++
+			buffer.WriteString(d.Placeholder(n))
+		}
+		buffer.WriteString(")")
+	}
+	if p.conflict != "" {
+		buffer.WriteString(" ")
+		buffer.WriteString(p.conflict)
+	}
+	return buffer.String()
+}
+
+// Get the arguments of the statement of the plan for the given rows.
+func (p *insertPlan) args(rows []reflect.Value) ([]interface{}, error) {
+	args := make([]interface{}, 0, len(rows)*len(p.fields))
+	for _, row := range rows {
+		for _, f := range p.fields {
+			field, ok := fieldValue(row, f.index)
+			arg, err := columnValue(p.dialect, f.column, field, ok)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+		}
+	}
+	return args, nil
+}
+
+// Returns whether the given primary key is left for the database to
+// generate in every one of the given rows.
+func isGeneratedInAll(primaryKey *fieldPlan, rows []reflect.Value) bool {
+	for _, row := range rows {
+		field, ok := fieldValue(row, primaryKey.index)
+		if !ok || !isGenerated(field) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns whether the given primary key field is left for the database to
+// generate, which integer keys at their zero value are.
+func isGenerated(field reflect.Value) bool {
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return field.IsZero()
+	default:
+		return false
+	}
+}
+
+// Set the given integer field to the given generated key.
+func setInt(field reflect.Value, id int64) {
+	switch field.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		field.SetUint(uint64(id))
+	default:
+		field.SetInt(id)
+	}
+}
+
+// Get the type a value of the given type points to, if it is a pointer.
+func getConcreteType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+	return t
+}
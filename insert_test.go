@@ -0,0 +1,430 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icebox
+
+import (
+	"database/sql/driver"
+	"github.com/jadengis/icebox/schema"
+	"github.com/jadengis/icebox/types"
+	"reflect"
+	"testing"
+)
+
+type fakeProfile struct {
+	Id       int64             `icebox:"column,primaryKey"`
+	Settings map[string]string `icebox:"column,json"`
+	Tags     []string          `icebox:"column,json"`
+}
+
+// Generate a schema holding the fake profile for use in the tests.
+func fakeProfileSchema(t *testing.T) schema.Schema {
+	s, err := schema.NewSchema("test_schema", new(fakeProfile))
+	if err != nil {
+		t.Fatalf("schema could not be generated: error = %s", err.Error())
+	}
+	return s
+}
+
+// Test that fields tagged json get the JSON type whatever their kind.
+func TestJSONColumns(t *testing.T) {
+	table, _ := fakeProfileSchema(t).TableFor(new(fakeProfile))
+	for _, name := range []string{"settings", "tags"} {
+		column, err := table.ColumnFor(name)
+		if err != nil {
+			t.Fatalf("json column is missing: error = %s", err.Error())
+		}
+		if column.Type().Type() != types.JSON {
+			t.Errorf("column type incorrect: column = %s, type = %s", name, column.Type().Type())
+		}
+	}
+}
+
+// Test that inserts encode JSON columns and write back generated keys.
+func TestInsert(t *testing.T) {
+	db := openFake(t, "sqlite3", fakeProfileSchema(t))
+	defer db.Close()
+	fakeQueue()
+
+	profile := &fakeProfile{Settings: map[string]string{"theme": "dark"}}
+	if err := db.Insert(profile); err != nil {
+		t.Fatalf("insert failed: error = %s", err.Error())
+	}
+	if profile.Id != fakeInsertId {
+		t.Errorf("generated key not written back: id = %d", profile.Id)
+	}
+	statements := fakeStatements()
+	expected := fakeStatement{
+		query: `INSERT INTO "fake_profiles" ("settings", "tags") VALUES (?, ?)`,
+		args:  []driver.Value{`{"theme":"dark"}`, nil},
+	}
+	if len(statements) != 1 || !reflect.DeepEqual(statements[0], expected) {
+		t.Errorf("insert statement incorrect: statements = %v, expected = %v",
+			statements, expected)
+	}
+}
+
+// Test that dialects with a RETURNING clause read the generated key back
+// from the insert itself.
+func TestInsertReturning(t *testing.T) {
+	db := openFake(t, "postgres", fakeProfileSchema(t))
+	defer db.Close()
+	fakeQueue(fakeResultSet{columns: []string{"id"}, rows: [][]driver.Value{{int64(9)}}})
+
+	profile := new(fakeProfile)
+	if err := db.Insert(profile); err != nil {
+		t.Fatalf("insert failed: error = %s", err.Error())
+	}
+	if profile.Id != 9 {
+		t.Errorf("returned key not written back: id = %d", profile.Id)
+	}
+	query := `INSERT INTO "fake_profiles" ("settings", "tags") VALUES ($1, $2) RETURNING "id"`
+	if statements := fakeStatements(); len(statements) != 1 || statements[0].query != query {
+		t.Errorf("insert statement incorrect: statements = %v, expected = %s", statements, query)
+	}
+}
+
+// Test that JSON columns are decoded when scanning, and NULLs reset them.
+func TestScanJSON(t *testing.T) {
+	s := fakeProfileSchema(t)
+	db := openFake(t, "sqlite3", s)
+	defer db.Close()
+	fakeQueue(fakeResultSet{
+		columns: []string{"id", "settings", "tags"},
+		rows:    [][]driver.Value{{int64(1), []byte(`{"theme":"light"}`), nil}},
+	})
+
+	rows, err := db.Query("SELECT * FROM fake_profiles")
+	if err != nil {
+		t.Fatalf("query failed: error = %s", err.Error())
+	}
+	defer rows.Close()
+	rows.Next()
+	table, _ := s.TableFor(new(fakeProfile))
+	profile := fakeProfile{Tags: []string{"stale"}}
+	if err := Scan(rows, table, &profile); err != nil {
+		t.Fatalf("row could not be scanned: error = %s", err.Error())
+	}
+	expected := fakeProfile{Id: 1, Settings: map[string]string{"theme": "light"}}
+	if !reflect.DeepEqual(profile, expected) {
+		t.Errorf("scanned row incorrect: row = %+v, expected = %+v", profile, expected)
+	}
+}
+
+type fakeColor string
+
+func (fakeColor) EnumValues() []string {
+	return []string{"red", "green"}
+}
+
+type fakeSwatch struct {
+	Color fakeColor `icebox:"column"`
+}
+
+// Test that values outside an enum are rejected before reaching the database.
+func TestInsertEnum(t *testing.T) {
+	s, err := schema.NewSchema("test_schema", new(fakeSwatch))
+	if err != nil {
+		t.Fatalf("schema could not be generated: error = %s", err.Error())
+	}
+	db := openFake(t, "sqlite3", s)
+	defer db.Close()
+	fakeQueue()
+
+	if err := db.Insert(&fakeSwatch{Color: "green"}); err != nil {
+		t.Errorf("insert of an enum value failed: error = %s", err.Error())
+	}
+	if err := db.Insert(&fakeSwatch{Color: "blue"}); err == nil {
+		t.Errorf("error not raised for a value outside the enum")
+	}
+	if statements := fakeStatements(); len(statements) != 1 {
+		t.Errorf("invalid value reached the database: statements = %v", statements)
+	}
+}
+
+type fakeAccount struct {
+	Id    int64  `icebox:"column,primaryKey"`
+	Email string `icebox:"column,unique"`
+	Name  string `icebox:"column"`
+}
+
+// Open a fake DB of the given driver holding the fake account.
+func openFakeAccounts(t testing.TB, driver string) *DB {
+	s, err := schema.NewSchema("test_schema", new(fakeAccount))
+	if err != nil {
+		t.Fatalf("schema could not be generated: error = %s", err.Error())
+	}
+	return openFake(t, driver, s)
+}
+
+// Test that slices are inserted with a single multi-row statement.
+func TestInsertMany(t *testing.T) {
+	db := openFakeAccounts(t, "sqlite3")
+	defer db.Close()
+	fakeQueue()
+
+	accounts := []fakeAccount{{Email: "a@x", Name: "a"}, {Email: "b@x", Name: "b"}}
+	if err := db.InsertMany(accounts); err != nil {
+		t.Fatalf("insert failed: error = %s", err.Error())
+	}
+	statements := fakeStatements()
+	expected := fakeStatement{
+		query: `INSERT INTO "fake_accounts" ("email", "name") VALUES (?, ?), (?, ?)`,
+		args:  []driver.Value{"a@x", "a", "b@x", "b"},
+	}
+	if len(statements) != 1 || !reflect.DeepEqual(statements[0], expected) {
+		t.Errorf("insert statement incorrect: statements = %v, expected = %v",
+			statements, expected)
+	}
+	if err := db.InsertMany(fakeAccount{}); err == nil {
+		t.Errorf("error not raised for an object which is not a slice")
+	}
+}
+
+// Test that large inserts are split to respect the parameter limit.
+func TestInsertManyChunks(t *testing.T) {
+	db := openFakeAccounts(t, "sqlite3")
+	defer db.Close()
+	fakeQueue()
+
+	accounts := make([]*fakeAccount, 1000)
+	for i := range accounts {
+		accounts[i] = &fakeAccount{Id: int64(i + 1)}
+	}
+	if err := db.InsertMany(accounts); err != nil {
+		t.Fatalf("insert failed: error = %s", err.Error())
+	}
+	statements := fakeStatements()
+	expected := []int{333, 333, 333, 1}
+	if len(statements) != len(expected) {
+		t.Fatalf("insert not chunked: statements = %d, expected = %d",
+			len(statements), len(expected))
+	}
+	for i, statement := range statements {
+		if rows := len(statement.args) / 3; rows != expected[i] {
+			t.Errorf("chunk size incorrect: rows = %d, expected = %d", rows, expected[i])
+		}
+	}
+}
+
+// Test that objects whose key is set are inserted apart from those whose key
+// is generated, rather than writing a zero key.
+func TestInsertManyMixedKeys(t *testing.T) {
+	db := openFakeAccounts(t, "sqlite3")
+	defer db.Close()
+	fakeQueue()
+
+	accounts := []fakeAccount{{Id: 5, Email: "a@x"}, {Email: "b@x"}, {Id: 6, Email: "c@x"}}
+	if err := db.InsertMany(accounts); err != nil {
+		t.Fatalf("insert failed: error = %s", err.Error())
+	}
+	expected := []fakeStatement{
+		{
+			query: `INSERT INTO "fake_accounts" ("id", "email", "name") VALUES (?, ?, ?), (?, ?, ?)`,
+			args:  []driver.Value{int64(5), "a@x", "", int64(6), "c@x", ""},
+		},
+		{
+			query: `INSERT INTO "fake_accounts" ("email", "name") VALUES (?, ?)`,
+			args:  []driver.Value{"b@x", ""},
+		},
+	}
+	if statements := fakeStatements(); !reflect.DeepEqual(statements, expected) {
+		t.Errorf("insert statements incorrect: statements = %v, expected = %v",
+			statements, expected)
+	}
+}
+
+// Test that generated keys of a multi-row insert are read back in order.
+func TestInsertManyReturning(t *testing.T) {
+	db := openFakeAccounts(t, "postgres")
+	defer db.Close()
+	fakeQueue(fakeResultSet{columns: []string{"id"}, rows: [][]driver.Value{{int64(7)}, {int64(8)}}})
+
+	accounts := []*fakeAccount{{Email: "a@x"}, {Email: "b@x"}}
+	if err := db.InsertMany(accounts); err != nil {
+		t.Fatalf("insert failed: error = %s", err.Error())
+	}
+	if accounts[0].Id != 7 || accounts[1].Id != 8 {
+		t.Errorf("returned keys not written back: ids = %d, %d", accounts[0].Id, accounts[1].Id)
+	}
+}
+
+// Test that upserts conflict on the primary key when it is set, and on a
+// unique column otherwise.
+func TestUpsert(t *testing.T) {
+	testCases := []struct {
+		driver  string
+		account *fakeAccount
+		query   string
+	}{
+		{"sqlite3", &fakeAccount{Id: 3, Email: "a@x", Name: "a"},
+			`INSERT INTO "fake_accounts" ("id", "email", "name") VALUES (?, ?, ?) ` +
+				`ON CONFLICT ("id") DO UPDATE SET "email" = EXCLUDED."email", "name" = EXCLUDED."name"`},
+		{"sqlite3", &fakeAccount{Email: "a@x", Name: "a"},
+			`INSERT INTO "fake_accounts" ("email", "name") VALUES (?, ?) ` +
+				`ON CONFLICT ("email") DO UPDATE SET "name" = EXCLUDED."name" RETURNING "id"`},
+		{"mysql", &fakeAccount{Email: "a@x", Name: "a"},
+			"INSERT INTO `fake_accounts` (`email`, `name`) VALUES (?, ?) " +
+				"ON DUPLICATE KEY UPDATE `name` = VALUES(`name`)"},
+	}
+	for _, tc := range testCases {
+		db := openFakeAccounts(t, tc.driver)
+		fakeQueue(fakeResultSet{columns: []string{"id"}, rows: [][]driver.Value{{int64(3)}}})
+		if err := db.Upsert(tc.account); err != nil {
+			t.Fatalf("upsert failed: error = %s", err.Error())
+		}
+		statements := fakeStatements()
+		if len(statements) != 1 || statements[0].query != tc.query {
+			t.Errorf("upsert statement incorrect: statements = %v, expected = %s",
+				statements, tc.query)
+		}
+		db.Close()
+	}
+
+	db := openFake(t, "sqlite3", fakeProfileSchema(t))
+	defer db.Close()
+	if err := db.Upsert(new(fakeProfile)); err == nil {
+		t.Errorf("error not raised for a table without a conflict target")
+	}
+}
+
+// Test that the generated key is only written back by an upsert which knows
+// the key of the upserted row, and that a conflicting row does not leave the
+// object with the last insert id of another row.
+func TestUpsertGeneratedKey(t *testing.T) {
+	sqlite := openFakeAccounts(t, "sqlite3")
+	defer sqlite.Close()
+	fakeQueue(fakeResultSet{columns: []string{"id"}, rows: [][]driver.Value{{int64(3)}}})
+	account := &fakeAccount{Email: "a@x", Name: "a"}
+	if err := sqlite.Upsert(account); err != nil {
+		t.Fatalf("upsert failed: error = %s", err.Error())
+	}
+	if account.Id != 3 {
+		t.Errorf("key of the updated row not written back: id = %d, expected = 3", account.Id)
+	}
+
+	s, err := schema.NewSchema("test_schema", new(fakeLabel))
+	if err != nil {
+		t.Fatalf("schema could not be generated: error = %s", err.Error())
+	}
+	labels := openFake(t, "sqlite3", s)
+	defer labels.Close()
+	fakeQueue()
+	label := &fakeLabel{Name: "a"}
+	if err := labels.Upsert(label); err != nil {
+		t.Fatalf("upsert failed: error = %s", err.Error())
+	}
+	if label.Id != 0 {
+		t.Errorf("key written back for an untouched row: id = %d", label.Id)
+	}
+
+	testCases := []struct {
+		affected int64
+		expected int64
+	}{
+		{1, fakeInsertId},
+		{2, 0},
+		{0, 0},
+	}
+	mysql := openFakeAccounts(t, "mysql")
+	defer mysql.Close()
+	for _, tc := range testCases {
+		fakeQueue()
+		fakeDatabase.RowsAffected = tc.affected
+		account := &fakeAccount{Email: "a@x", Name: "a"}
+		if err := mysql.Upsert(account); err != nil {
+			t.Fatalf("upsert failed: error = %s", err.Error())
+		}
+		if account.Id != tc.expected {
+			t.Errorf("key written back incorrect: rows affected = %d, id = %d, expected = %d",
+				tc.affected, account.Id, tc.expected)
+		}
+	}
+}
+
+// A table whose upserts have no column left to update.
+type fakeLabel struct {
+	Id   int64  `icebox:"column,primaryKey"`
+	Name string `icebox:"column,unique"`
+}
+
+// Test that an upsert leaving a conflicting row untouched succeeds, although
+// no generated key is returned.
+func TestUpsertNothing(t *testing.T) {
+	s, err := schema.NewSchema("test_schema", new(fakeLabel))
+	if err != nil {
+		t.Fatalf("schema could not be generated: error = %s", err.Error())
+	}
+	db := openFake(t, "postgres", s)
+	defer db.Close()
+	fakeQueue()
+
+	label := &fakeLabel{Name: "a"}
+	if err := db.Upsert(label); err != nil {
+		t.Fatalf("upsert failed: error = %s", err.Error())
+	}
+	query := `INSERT INTO "fake_labels" ("name") VALUES ($1) ON CONFLICT ("name") DO NOTHING RETURNING "id"`
+	if statements := fakeStatements(); len(statements) != 1 || statements[0].query != query {
+		t.Errorf("upsert statement incorrect: statements = %v, expected = %s", statements, query)
+	}
+	if label.Id != 0 {
+		t.Errorf("key written back for an untouched row: id = %d", label.Id)
+	}
+
+	fakeQueue()
+	labels := []*fakeLabel{{Name: "a"}, {Name: "b"}}
+	if err := db.UpsertMany(labels); err != nil {
+		t.Fatalf("upsert failed: error = %s", err.Error())
+	}
+	query = `INSERT INTO "fake_labels" ("name") VALUES ($1), ($2) ON CONFLICT ("name") DO NOTHING`
+	if statements := fakeStatements(); len(statements) != 1 || statements[0].query != query {
+		t.Errorf("upsert statement incorrect: statements = %v, expected = %s", statements, query)
+	}
+}
+
+// A table embedding Model for its primary key and timestamps.
+type fakeEntity struct {
+	Model `icebox:"inline:''"`
+	Name  string `icebox:"column"`
+}
+
+// Test that the primary key of Model is written back by inserts and read
+// by scans.
+func TestInsertModel(t *testing.T) {
+	s, err := schema.NewSchema("test_schema", new(fakeEntity))
+	if err != nil {
+		t.Fatalf("schema could not be generated: error = %s", err.Error())
+	}
+	db := openFake(t, "sqlite3", s)
+	defer db.Close()
+	fakeQueue(fakeResultSet{
+		columns: []string{"id", "name"},
+		rows:    [][]driver.Value{{int64(7), "a"}},
+	})
+
+	entity := &fakeEntity{Name: "a"}
+	if err := db.Insert(entity); err != nil {
+		t.Fatalf("insert failed: error = %s", err.Error())
+	}
+	if entity.Id() != fakeInsertId {
+		t.Errorf("generated key not set: id = %d, expected = %d", entity.Id(), fakeInsertId)
+	}
+	var entities []fakeEntity
+	if err := db.From(new(fakeEntity)).All(&entities); err != nil {
+		t.Fatalf("query failed: error = %s", err.Error())
+	}
+	if len(entities) != 1 || entities[0].Id() != 7 {
+		t.Errorf("primary key not scanned: entities = %v", entities)
+	}
+}
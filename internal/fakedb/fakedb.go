@@ -0,0 +1,324 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fakedb implements a fake database/sql driver for testing icebox
+// without a database. It understands just enough SQL to keep the migration
+// history and lock tables in memory, records every statement it is given,
+// and answers other queries with the result sets queued on the database.
+package fakedb
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DriverName is the name the fake driver is registered under.
+const DriverName string = "icebox_fakedb"
+
+func init() {
+	sql.Register(DriverName, Driver{})
+}
+
+// The databases opened by the fake driver, by data source name.
+var databases struct {
+	sync.Mutex
+	named map[string]*Database
+}
+
+// Statement is a statement run against a database, with its arguments and
+// the name the connection it was run on was opened by.
+type Statement struct {
+	Query  string
+	Args   []driver.Value
+	Source string
+}
+
+// ResultSet is a result set returned by a query.
+type ResultSet struct {
+	Columns []string
+	Rows    [][]driver.Value
+}
+
+// HistoryRow is a row of the migration history table.
+type HistoryRow struct {
+	Version   int64
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// Database is the state of a fake database.
+//
+// Statements are the statements run against the database, in order.
+//
+// Results are the result sets returned in order by the queries which are not
+// on the migration tables. A query finding none returns no rows.
+//
+// Prepared counts the statements prepared on the database.
+//
+// InsertId is the last insert id reported by every Exec.
+//
+// RowsAffected is the number of rows reported affected by every Exec.
+//
+// History is the contents of the migration history table.
+//
+// Locked is set while the row of the migration lock table exists.
+//
+// LockResult is the value returned by advisory lock queries.
+//
+// Legacy is set while the history table lacks its checksum column, as
+// history tables created before migrations were checksummed do.
+//
+// Fail, if set, is called with every statement, and a non-nil error it
+// returns fails the statement.
+type Database struct {
+	sync.Mutex
+	Statements   []Statement
+	Results      []ResultSet
+	Prepared     int
+	InsertId     int64
+	RowsAffected int64
+	History      []HistoryRow
+	Locked       bool
+	LockResult   driver.Value
+	Legacy       bool
+	Fail         func(query string) error
+}
+
+// New creates an empty database, opened by the fake driver with the given
+// data source name. Creating a database again with the same name replaces
+// it.
+func New(name string) *Database {
+	databases.Lock()
+	defer databases.Unlock()
+	if databases.named == nil {
+		databases.named = make(map[string]*Database)
+	}
+	db := &Database{LockResult: int64(1), RowsAffected: 1}
+	databases.named[name] = db
+	return db
+}
+
+// Share makes the database opened by the fake driver with the given data
+// source name too, so that statements run on connections opened by either
+// name are recorded together, each with the name it was opened by.
+func (d *Database) Share(name string) {
+	databases.Lock()
+	defer databases.Unlock()
+	databases.named[name] = d
+}
+
+// Run the given statement against the database on a connection opened by
+// the given name, returning the columns and rows of its result. Queued
+// result sets are only returned to queries.
+func (d *Database) run(source, query string, args []driver.Value, isQuery bool) ([]string, [][]driver.Value, error) {
+	d.Lock()
+	defer d.Unlock()
+	d.Statements = append(d.Statements, Statement{Query: query, Args: args, Source: source})
+	if d.Fail != nil {
+		if err := d.Fail(query); err != nil {
+			return nil, nil, err
+		}
+	}
+	switch {
+	case strings.Contains(query, "GET_LOCK"), strings.Contains(query, "pg_advisory_lock("):
+		return []string{"locked"}, [][]driver.Value{{d.LockResult}}, nil
+	case strings.Contains(query, "RELEASE_LOCK"), strings.Contains(query, "pg_advisory_unlock("):
+		return []string{"released"}, [][]driver.Value{{int64(1)}}, nil
+	case strings.Contains(query, "schema_migrations_lock"):
+		return nil, nil, d.lockTable(query)
+	case strings.Contains(query, "schema_migrations"):
+		return d.historyTable(query, args)
+	}
+	if isQuery && len(d.Results) > 0 {
+		var result ResultSet
+		result, d.Results = d.Results[0], d.Results[1:]
+		return result.Columns, result.Rows, nil
+	}
+	return nil, nil, nil
+}
+
+// Run the given statement against the migration lock table.
+func (d *Database) lockTable(query string) error {
+	switch {
+	case strings.HasPrefix(query, "INSERT"):
+		if d.Locked {
+			return errors.New("UNIQUE constraint failed: schema_migrations_lock.id")
+		}
+		d.Locked = true
+	case strings.HasPrefix(query, "DELETE"):
+		d.Locked = false
+	}
+	return nil
+}
+
+// Run the given statement against the migration history table.
+func (d *Database) historyTable(query string, args []driver.Value) ([]string, [][]driver.Value, error) {
+	columns := []string{"version", "applied_at", "checksum"}
+	switch {
+	case strings.HasPrefix(query, "SELECT") && strings.Contains(query, "WHERE 1 = 0"):
+		if d.Legacy {
+			return []string{"version", "name", "applied_at"}, nil, nil
+		}
+		return []string{"version", "name", "checksum", "applied_at"}, nil, nil
+	case strings.HasPrefix(query, "SELECT") && d.Legacy && strings.Contains(query, "checksum"):
+		return nil, nil, errors.New("no such column: checksum")
+	case strings.HasPrefix(query, "SELECT"):
+		var rows [][]driver.Value
+		for _, row := range d.History {
+			rows = append(rows, []driver.Value{row.Version, row.AppliedAt, row.Checksum})
+		}
+		return columns, rows, nil
+	case strings.HasPrefix(query, "INSERT"):
+		d.History = append(d.History, HistoryRow{
+			Version:   args[0].(int64),
+			Name:      args[1].(string),
+			Checksum:  args[2].(string),
+			AppliedAt: args[3].(time.Time),
+		})
+	case strings.HasPrefix(query, "ALTER TABLE"):
+		d.Legacy = false
+	case strings.HasPrefix(query, "UPDATE"):
+		for i := range d.History {
+			if d.History[i].Version == args[1].(int64) {
+				d.History[i].Checksum = args[0].(string)
+			}
+		}
+	case strings.HasPrefix(query, "DELETE"):
+		for i, row := range d.History {
+			if row.Version == args[0].(int64) {
+				d.History = append(d.History[:i], d.History[i+1:]...)
+				break
+			}
+		}
+	}
+	return nil, nil, nil
+}
+
+// Driver is the fake database/sql driver, which opens the databases created
+// with New by name.
+type Driver struct{}
+
+// Open opens a connection to the database with the given name.
+func (Driver) Open(name string) (driver.Conn, error) {
+	databases.Lock()
+	defer databases.Unlock()
+	db, found := databases.named[name]
+	if !found {
+		return nil, errors.New("no fake database named " + name)
+	}
+	return conn{db: db, name: name}, nil
+}
+
+type conn struct {
+	db   *Database
+	name string
+}
+
+func (c conn) Prepare(query string) (driver.Stmt, error) {
+	c.db.Lock()
+	c.db.Prepared++
+	c.db.Unlock()
+	return stmt{conn: c, query: query}, nil
+}
+
+func (c conn) Close() error {
+	return nil
+}
+
+func (c conn) Begin() (driver.Tx, error) {
+	return tx{}, nil
+}
+
+type tx struct{}
+
+func (tx) Commit() error {
+	return nil
+}
+
+func (tx) Rollback() error {
+	return nil
+}
+
+type stmt struct {
+	conn  conn
+	query string
+}
+
+func (s stmt) Close() error {
+	return nil
+}
+
+func (s stmt) NumInput() int {
+	return -1
+}
+
+func (s stmt) Exec(args []driver.Value) (driver.Result, error) {
+	db := s.conn.db
+	if _, _, err := db.run(s.conn.name, s.query, args, false); err != nil {
+		return nil, err
+	}
+	db.Lock()
+	defer db.Unlock()
+	return result{insertId: db.InsertId, rowsAffected: db.RowsAffected}, nil
+}
+
+func (s stmt) Query(args []driver.Value) (driver.Rows, error) {
+	columns, values, err := s.conn.db.run(s.conn.name, s.query, args, true)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{columns: columns, values: values}, nil
+}
+
+type rows struct {
+	columns []string
+	values  [][]driver.Value
+	pos     int
+}
+
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.pos])
+	r.pos++
+	return nil
+}
+
+type result struct {
+	insertId     int64
+	rowsAffected int64
+}
+
+func (r result) LastInsertId() (int64, error) {
+	return r.insertId, nil
+}
+
+func (r result) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
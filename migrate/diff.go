@@ -0,0 +1,68 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrate
+
+import (
+	"github.com/jadengis/icebox"
+	"github.com/jadengis/icebox/dialect"
+	"github.com/jadengis/icebox/schema"
+)
+
+// Diff compares the given schema against the tables which exist in the
+// database, and returns the statements needed to bring the database in line
+// with the schema.
+//
+// Diff only ever adds to the database: missing tables are created and missing
+// columns are added. Tables and columns which are absent from the schema are
+// left alone, as dropping them cannot be done safely automatically.
+func Diff(db *icebox.DB, d dialect.Dialect, s schema.Schema) ([]string, error) {
+	var statements []string
+	for _, table := range s.Tables() {
+		existing, err := existingColumns(db, d, table.Name())
+		if err != nil {
+			return nil, err
+		}
+		if len(existing) == 0 {
+			statements = append(statements, dialect.CreateTable(d, table)...)
+			continue
+		}
+		for _, column := range table.Columns() {
+			if _, found := existing[column.Name()]; !found {
+				statements = append(statements, dialect.AddColumn(d, table, column)...)
+			}
+		}
+	}
+	return statements, nil
+}
+
+// Query the set of column names of the given table. The set is empty if the
+// table does not exist.
+func existingColumns(db *icebox.DB, d dialect.Dialect, table string) (map[string]bool, error) {
+	rows, err := db.Query(d.ColumnsQuery(), table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
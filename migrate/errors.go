@@ -0,0 +1,77 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrate
+
+import (
+	"fmt"
+)
+
+// Error type for a problem with a specific migration.
+type migrationError struct {
+	version int64
+	msg     string
+}
+
+// Produce an error message for a migrationError.
+func (e *migrationError) Error() string {
+	return fmt.Sprintf("migration %d : %s", e.version, e.msg)
+}
+
+// Error type for a migration file whose name cannot be parsed.
+type fileNameError struct {
+	name string
+	msg  string
+}
+
+// Produce an error message for a fileNameError.
+func (e *fileNameError) Error() string {
+	return fmt.Sprintf("bad migration file name %s : %s", e.name, e.msg)
+}
+
+// Error type wrapping a failure while running a migration.
+type runError struct {
+	cause   error
+	version int64
+	msg     string
+}
+
+// Produce an error message for a runError.
+func (e *runError) Error() string {
+	return fmt.Sprintf("migration %d : %s : %s", e.version, e.msg, e.cause.Error())
+}
+
+// Error type for applied migrations which have since been edited.
+type checksumError struct {
+	versions []int64
+}
+
+// Produce an error message for a checksumError.
+func (e *checksumError) Error() string {
+	return fmt.Sprintf("applied migrations have been modified : versions = %v", e.versions)
+}
+
+// Error type for a migration lock held by another process.
+type lockedError struct {
+	cause error
+	msg   string
+}
+
+// Produce an error message for a lockedError.
+func (e *lockedError) Error() string {
+	if e.cause == nil {
+		return e.msg
+	}
+	return fmt.Sprintf("%s : %s", e.msg, e.cause.Error())
+}
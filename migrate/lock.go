@@ -0,0 +1,89 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"github.com/jadengis/icebox/dialect"
+	"github.com/jadengis/icebox/types"
+	"time"
+)
+
+const (
+	// The name of the advisory lock held while migrating.
+	lockName string = "icebox_schema_migrations"
+	// The name of the table used as a lock by dialects without advisory locks.
+	lockTable string = "schema_migrations_lock"
+)
+
+// Acquire the migration lock, and return a function releasing it.
+//
+// Dialects with advisory locks hold the lock on a dedicated connection, so it
+// is released by the database if the process dies. Other dialects insert a
+// row into the lock table, which must be deleted by hand if a migrating
+// process dies before releasing it.
+func (m *migratorImpl) lock() (func() error, error) {
+	if locker, ok := m.dialect.(dialect.Locker); ok {
+		return m.advisoryLock(locker)
+	}
+	return m.tableLock()
+}
+
+// Acquire the migration lock as an advisory lock.
+func (m *migratorImpl) advisoryLock(locker dialect.Locker) (func() error, error) {
+	ctx := context.Background()
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var acquired sql.NullInt64
+	if err = conn.QueryRowContext(ctx, locker.LockQuery(), lockName).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, &lockedError{cause: err, msg: "could not acquire migration lock"}
+	}
+	if !acquired.Valid || acquired.Int64 != 1 {
+		conn.Close()
+		return nil, &lockedError{msg: "migration lock was not acquired"}
+	}
+	return func() error {
+		_, err := conn.ExecContext(ctx, locker.UnlockQuery(), lockName)
+		conn.Close()
+		return err
+	}, nil
+}
+
+// Acquire the migration lock by inserting the single row of the lock table.
+func (m *migratorImpl) tableLock() (func() error, error) {
+	d := m.dialect
+	create := "CREATE TABLE IF NOT EXISTS " + d.Quote(lockTable) + " (" +
+		d.Quote("id") + " " + d.TypeName(types.NewSQLType(types.Int)) + " PRIMARY KEY, " +
+		d.Quote("locked_at") + " " + d.TypeName(types.NewSQLType(types.TimeStamp)) + " NOT NULL)"
+	if _, err := m.db.Exec(create); err != nil {
+		return nil, err
+	}
+
+	insert := "INSERT INTO " + d.Quote(lockTable) + " (" + d.Quote("id") + ", " +
+		d.Quote("locked_at") + ") VALUES (1, " + d.Placeholder(1) + ")"
+	if _, err := m.db.Exec(insert, time.Now().UTC()); err != nil {
+		return nil, &lockedError{
+			cause: err,
+			msg:   "migration lock is held, delete the row from " + lockTable + " if no migration is running"}
+	}
+	return func() error {
+		_, err := m.db.Exec("DELETE FROM " + d.Quote(lockTable))
+		return err
+	}, nil
+}
@@ -0,0 +1,193 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/jadengis/icebox"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Suffixes of the files holding the up and down halves of a migration.
+	upSuffix   string = ".up.sql"
+	downSuffix string = ".down.sql"
+	// Separator between the version and name in a migration file name.
+	versionSeparator string = "_"
+)
+
+// Migration is a single versioned change to a database schema.
+//
+// Version orders the migrations. Migrations are applied in increasing order
+// of version, and reverted in decreasing order.
+//
+// Name is a human readable description of the migration.
+//
+// Up is the SQL which applies the migration.
+//
+// Down is the SQL which reverts the migration.
+//
+// UpFunc is Go code which applies the migration. It runs after the Up SQL,
+// in the same transaction.
+//
+// DownFunc is Go code which reverts the migration. It runs after the Down SQL,
+// in the same transaction.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	UpFunc   func(*icebox.Tx) error
+	DownFunc func(*icebox.Tx) error
+}
+
+// Checksum returns a digest of the migration which is recorded when the
+// migration is applied, so that later edits to it can be detected.
+//
+// The digest covers the name and SQL of the migration. The code of Go
+// migrations cannot be inspected, so edits to UpFunc and DownFunc are not
+// detected.
+func (m *Migration) Checksum() string {
+	hash := sha256.New()
+	hash.Write([]byte(m.Name))
+	hash.Write([]byte{0})
+	hash.Write([]byte(m.Up))
+	hash.Write([]byte{0})
+	hash.Write([]byte(m.Down))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Returns whether the migration has anything to run when applied.
+func (m *Migration) canApply() bool {
+	return m.Up != "" || m.UpFunc != nil
+}
+
+// Returns whether the migration has anything to run when reverted.
+func (m *Migration) canRevert() bool {
+	return m.Down != "" || m.DownFunc != nil
+}
+
+// LoadDir reads the SQL migrations in the given directory. Migrations are
+// stored as pairs of files named <version>_<name>.up.sql and
+// <version>_<name>.down.sql, and the down file may be omitted for migrations
+// which cannot be reverted.
+//
+// The returned migrations are sorted by version. This returns an error if a
+// file name cannot be parsed or if two migrations share a version.
+func LoadDir(dir string) ([]*Migration, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, file := range files {
+		name := file.Name()
+		var up bool
+		switch {
+		case strings.HasSuffix(name, upSuffix):
+			up = true
+			name = strings.TrimSuffix(name, upSuffix)
+		case strings.HasSuffix(name, downSuffix):
+			name = strings.TrimSuffix(name, downSuffix)
+		default:
+			// This isn't a migration file, so skip it.
+			continue
+		}
+
+		version, description, err := parseFileName(name)
+		if err != nil {
+			return nil, err
+		}
+		contents, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, found := byVersion[version]
+		if !found {
+			migration = &Migration{Version: version, Name: description}
+			byVersion[version] = migration
+		} else if migration.Name != description {
+			return nil, &migrationError{
+				version: version,
+				msg:     "version is shared by " + migration.Name + " and " + description}
+		}
+		if up {
+			migration.Up = string(contents)
+		} else {
+			migration.Down = string(contents)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if !migration.canApply() {
+			return nil, &migrationError{
+				version: migration.Version,
+				msg:     "migration has no up file"}
+		}
+		migrations = append(migrations, migration)
+	}
+	sortMigrations(migrations)
+	return migrations, nil
+}
+
+// Split a migration file name, stripped of its suffix, into the version and
+// name of the migration.
+func parseFileName(name string) (int64, string, error) {
+	description := ""
+	if i := strings.Index(name, versionSeparator); i >= 0 {
+		description = name[i+1:]
+		name = name[:i]
+	}
+	version, err := strconv.ParseInt(name, 10, 64)
+	if err != nil {
+		return 0, "", &fileNameError{
+			name: name,
+			msg:  "file name does not start with a version"}
+	}
+	return version, description, nil
+}
+
+// Sort the given migrations in increasing order of version.
+func sortMigrations(migrations []*Migration) {
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+}
+
+// Merge the given sets of migrations into a single slice sorted by version.
+// This returns an error if two migrations share a version.
+func merge(sets ...[]*Migration) ([]*Migration, error) {
+	var merged []*Migration
+	for _, set := range sets {
+		merged = append(merged, set...)
+	}
+	sortMigrations(merged)
+	for i := 1; i < len(merged); i++ {
+		if merged[i].Version == merged[i-1].Version {
+			return nil, &migrationError{
+				version: merged[i].Version,
+				msg:     "version is shared by " + merged[i-1].Name + " and " + merged[i].Name}
+		}
+	}
+	return merged, nil
+}
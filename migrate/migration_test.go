@@ -0,0 +1,118 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Write the given files into a fresh temporary directory.
+func writeMigrations(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "icebox-migrations")
+	if err != nil {
+		t.Fatalf("temporary directory could not be created: error = %s", err.Error())
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatalf("migration could not be written: error = %s", err.Error())
+		}
+	}
+	return dir
+}
+
+// Test that migrations are paired up and sorted by version.
+func TestLoadDir(t *testing.T) {
+	dir := writeMigrations(t, map[string]string{
+		"0002_add_email.up.sql":      "ALTER TABLE users ADD COLUMN email TEXT",
+		"0001_create_users.up.sql":   "CREATE TABLE users (id INTEGER)",
+		"0001_create_users.down.sql": "DROP TABLE users",
+		"README.md":                  "not a migration",
+	})
+	defer os.RemoveAll(dir)
+
+	migrations, err := LoadDir(dir)
+	if err != nil {
+		t.Fatalf("migrations could not be loaded: error = %s", err.Error())
+	}
+	if len(migrations) != 2 {
+		t.Fatalf("expected 2 migrations: count = %d", len(migrations))
+	}
+	if migrations[0].Version != 1 || migrations[0].Name != "create_users" {
+		t.Errorf("first migration is incorrect: version = %d, name = %s",
+			migrations[0].Version, migrations[0].Name)
+	}
+	if migrations[0].Down != "DROP TABLE users" {
+		t.Errorf("first migration down is incorrect: down = %s", migrations[0].Down)
+	}
+	if migrations[1].Version != 2 || migrations[1].Down != "" {
+		t.Errorf("second migration is incorrect: version = %d, down = %s",
+			migrations[1].Version, migrations[1].Down)
+	}
+}
+
+// Test that bad migration directories are rejected.
+func TestLoadDirErrors(t *testing.T) {
+	testCases := map[string]map[string]string{
+		"bad version":    {"first.up.sql": "SELECT 1"},
+		"missing up":     {"0001_first.down.sql": "SELECT 1"},
+		"shared version": {"0001_first.up.sql": "SELECT 1", "0001_second.up.sql": "SELECT 1"},
+	}
+	for name, files := range testCases {
+		t.Run(name, func(t *testing.T) {
+			dir := writeMigrations(t, files)
+			defer os.RemoveAll(dir)
+			if _, err := LoadDir(dir); err == nil {
+				t.Errorf("error not raised for %s", name)
+			}
+		})
+	}
+}
+
+// Test that the checksum changes when the migration is edited.
+func TestChecksum(t *testing.T) {
+	migration := &Migration{Version: 1, Name: "create_users", Up: "CREATE TABLE users (id INTEGER)"}
+	checksum := migration.Checksum()
+	if len(checksum) != 64 {
+		t.Errorf("checksum is not a sha256 digest: checksum = %s", checksum)
+	}
+	edited := *migration
+	edited.Up = "CREATE TABLE users (id BIGINT)"
+	if edited.Checksum() == checksum {
+		t.Errorf("checksum did not change after editing the migration")
+	}
+}
+
+// Test that merging rejects migrations which share a version.
+func TestMerge(t *testing.T) {
+	sql := []*Migration{{Version: 3, Name: "third"}, {Version: 1, Name: "first"}}
+	code := []*Migration{{Version: 2, Name: "second"}}
+	merged, err := merge(sql, code)
+	if err != nil {
+		t.Fatalf("migrations could not be merged: error = %s", err.Error())
+	}
+	for i, migration := range merged {
+		if migration.Version != int64(i+1) {
+			t.Errorf("merged migrations are out of order: version = %d at %d",
+				migration.Version, i)
+		}
+	}
+
+	if _, err = merge(sql, []*Migration{{Version: 1, Name: "duplicate"}}); err == nil {
+		t.Errorf("error not raised for shared version")
+	}
+}
@@ -0,0 +1,346 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrate
+
+import (
+	"database/sql"
+	"github.com/jadengis/icebox"
+	"github.com/jadengis/icebox/dialect"
+	"github.com/jadengis/icebox/types"
+	"strings"
+	"time"
+)
+
+const (
+	// The name of the table recording applied migrations.
+	historyTable string = "schema_migrations"
+)
+
+// Migrator applies and reverts a set of migrations against a database, and
+// records which migrations have been applied in a history table.
+//
+// Up and Down hold a lock for their duration, so that two processes never
+// migrate the same database concurrently. Both refuse to run if an applied
+// migration has been edited since it was applied.
+//
+// Up applies all pending migrations in order of version, and returns the
+// number of migrations that were applied.
+//
+// Down reverts the most recently applied migration. It returns the reverted
+// migration, or nil if there was nothing to revert.
+//
+// Status returns the state of every known migration, in order of version.
+type Migrator interface {
+	Up() (int, error)
+	Down() (*Migration, error)
+	Status() ([]Status, error)
+}
+
+// Status describes whether a migration has been applied to the database.
+//
+// AppliedAt is nil for migrations which are still pending.
+//
+// Modified is true if the migration was edited after it was applied.
+type Status struct {
+	Migration *Migration
+	AppliedAt *time.Time
+	Modified  bool
+}
+
+// The default implementation of the Migrator interface.
+//
+// DB is the database to migrate.
+//
+// Dialect is the SQL dialect spoken by the database.
+//
+// Migrations is the slice of known migrations sorted by version.
+type migratorImpl struct {
+	db         *icebox.DB
+	dialect    dialect.Dialect
+	migrations []*Migration
+}
+
+// A row of the history table.
+type appliedMigration struct {
+	appliedAt time.Time
+	checksum  string
+}
+
+// NewMigrator constructs a Migrator running the given migrations against the
+// given database. The migrations may come from both LoadDir and Registered.
+// This returns an error if two migrations share a version.
+func NewMigrator(db *icebox.DB, d dialect.Dialect, migrations []*Migration) (Migrator, error) {
+	merged, err := merge(migrations)
+	if err != nil {
+		return nil, err
+	}
+	return &migratorImpl{
+		db:         db,
+		dialect:    d,
+		migrations: merged,
+	}, nil
+}
+
+// Apply every migration which is missing from the history table. Each
+// migration runs in its own transaction along with its history record.
+func (m *migratorImpl) Up() (count int, err error) {
+	unlock, err := m.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if unlockErr := unlock(); err == nil {
+			err = unlockErr
+		}
+	}()
+
+	applied, err := m.verifiedApplied()
+	if err != nil {
+		return 0, err
+	}
+	for _, migration := range m.migrations {
+		if _, found := applied[migration.Version]; found {
+			continue
+		}
+		err = m.run(migration, migration.Up, migration.UpFunc, m.insertHistory(),
+			migration.Version, migration.Name, migration.Checksum(), time.Now().UTC())
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Revert the applied migration with the highest version.
+func (m *migratorImpl) Down() (reverted *Migration, err error) {
+	unlock, err := m.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if unlockErr := unlock(); err == nil {
+			err = unlockErr
+		}
+	}()
+
+	applied, err := m.verifiedApplied()
+	if err != nil {
+		return nil, err
+	}
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if _, found := applied[migration.Version]; !found {
+			continue
+		}
+		if !migration.canRevert() {
+			return nil, &migrationError{
+				version: migration.Version,
+				msg:     "migration cannot be reverted"}
+		}
+		err = m.run(migration, migration.Down, migration.DownFunc, m.deleteHistory(),
+			migration.Version)
+		if err != nil {
+			return nil, err
+		}
+		return migration, nil
+	}
+	return nil, nil
+}
+
+// Pair every known migration with the time it was applied.
+func (m *migratorImpl) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Migration: migration}
+		if record, found := applied[migration.Version]; found {
+			appliedAt := record.appliedAt
+			status.AppliedAt = &appliedAt
+			status.Modified = isModified(migration, record)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Run the given migration SQL and Go code followed by the given history
+// statement in a single transaction.
+func (m *migratorImpl) run(migration *Migration, query string, fn func(*icebox.Tx) error,
+	history string, args ...interface{}) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	if query != "" {
+		if _, err = tx.Exec(query); err != nil {
+			tx.Rollback()
+			return &runError{
+				cause:   err,
+				version: migration.Version,
+				msg:     "could not execute migration"}
+		}
+	}
+	if fn != nil {
+		if err = fn(tx); err != nil {
+			tx.Rollback()
+			return &runError{
+				cause:   err,
+				version: migration.Version,
+				msg:     "could not execute migration"}
+		}
+	}
+	if _, err = tx.Exec(history, args...); err != nil {
+		tx.Rollback()
+		return &runError{
+			cause:   err,
+			version: migration.Version,
+			msg:     "could not record migration"}
+	}
+	return tx.Commit()
+}
+
+// Read the history table, and return an error if any applied migration has
+// been edited since it was applied. Migrations applied before checksums were
+// recorded are adopted with their current checksum.
+func (m *migratorImpl) verifiedApplied() (map[int64]appliedMigration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	var modified []int64
+	for _, migration := range m.migrations {
+		record, found := applied[migration.Version]
+		if !found {
+			continue
+		}
+		if record.checksum == "" {
+			if _, err := m.db.Exec(m.updateChecksum(), migration.Checksum(), migration.Version); err != nil {
+				return nil, err
+			}
+			record.checksum = migration.Checksum()
+			applied[migration.Version] = record
+		}
+		if isModified(migration, record) {
+			modified = append(modified, migration.Version)
+		}
+	}
+	if len(modified) > 0 {
+		return nil, &checksumError{versions: modified}
+	}
+	return applied, nil
+}
+
+// Returns whether the migration no longer matches the recorded checksum.
+// Migrations applied before checksums were recorded have none to match.
+func isModified(migration *Migration, record appliedMigration) bool {
+	return record.checksum != "" && record.checksum != migration.Checksum()
+}
+
+// Read the history table, creating it if it does not exist yet, and return a
+// map from applied versions to their history record.
+func (m *migratorImpl) applied() (map[int64]appliedMigration, error) {
+	if _, err := m.db.Exec(m.createHistory()); err != nil {
+		return nil, err
+	}
+	if err := m.upgradeHistory(); err != nil {
+		return nil, err
+	}
+	d := m.dialect
+	rows, err := m.db.Query("SELECT " + d.Quote("version") + ", " + d.Quote("applied_at") +
+		", " + d.Quote("checksum") + " FROM " + d.Quote(historyTable))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanHistory(rows)
+}
+
+// Scan the rows of the history table into a map of version to history record.
+func scanHistory(rows *sql.Rows) (map[int64]appliedMigration, error) {
+	applied := make(map[int64]appliedMigration)
+	for rows.Next() {
+		var version int64
+		var record appliedMigration
+		if err := rows.Scan(&version, &record.appliedAt, &record.checksum); err != nil {
+			return nil, err
+		}
+		applied[version] = record
+	}
+	return applied, rows.Err()
+}
+
+// Statement creating the history table.
+func (m *migratorImpl) createHistory() string {
+	d := m.dialect
+	return "CREATE TABLE IF NOT EXISTS " + d.Quote(historyTable) + " (" +
+		d.Quote("version") + " " + d.TypeName(types.NewSQLType(types.BigInt)) + " PRIMARY KEY, " +
+		d.Quote("name") + " " + d.TypeName(types.NewSQLTypeWithSize(types.VarChar, "255")) + " NOT NULL, " +
+		d.Quote("checksum") + " " + d.TypeName(types.NewSQLTypeWithSize(types.Char, "64")) + " NOT NULL, " +
+		d.Quote("applied_at") + " " + d.TypeName(types.NewSQLType(types.TimeStamp)) + " NOT NULL)"
+}
+
+// Add the checksum column to a history table created before migrations were
+// checksummed. The columns are listed by a query selecting no rows, since
+// the dialects disagree on how to list the columns of a table, and an error
+// of the query is returned as is.
+func (m *migratorImpl) upgradeHistory() error {
+	d := m.dialect
+	rows, err := m.db.Query("SELECT * FROM " + d.Quote(historyTable) + " WHERE 1 = 0")
+	if err != nil {
+		return err
+	}
+	columns, err := rows.Columns()
+	rows.Close()
+	if err != nil {
+		return err
+	}
+	for _, column := range columns {
+		if strings.EqualFold(column, "checksum") {
+			return nil
+		}
+	}
+	_, err = m.db.Exec("ALTER TABLE " + d.Quote(historyTable) + " ADD COLUMN " +
+		d.Quote("checksum") + " " + d.TypeName(types.NewSQLTypeWithSize(types.Char, "64")) +
+		" NOT NULL DEFAULT ''")
+	return err
+}
+
+// Statement recording an applied migration.
+func (m *migratorImpl) insertHistory() string {
+	d := m.dialect
+	return "INSERT INTO " + d.Quote(historyTable) + " (" +
+		d.Quote("version") + ", " + d.Quote("name") + ", " + d.Quote("checksum") + ", " +
+		d.Quote("applied_at") + ") VALUES (" + d.Placeholder(1) + ", " + d.Placeholder(2) +
+		", " + d.Placeholder(3) + ", " + d.Placeholder(4) + ")"
+}
+
+// Statement recording the checksum of a migration applied before checksums
+// were recorded.
+func (m *migratorImpl) updateChecksum() string {
+	d := m.dialect
+	return "UPDATE " + d.Quote(historyTable) + " SET " + d.Quote("checksum") + " = " +
+		d.Placeholder(1) + " WHERE " + d.Quote("version") + " = " + d.Placeholder(2)
+}
+
+// Statement forgetting a reverted migration.
+func (m *migratorImpl) deleteHistory() string {
+	d := m.dialect
+	return "DELETE FROM " + d.Quote(historyTable) +
+		" WHERE " + d.Quote("version") + " = " + d.Placeholder(1)
+}
@@ -0,0 +1,251 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/jadengis/icebox"
+	"github.com/jadengis/icebox/dialect"
+	"github.com/jadengis/icebox/internal/fakedb"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Open a migrator running the given migrations against a fresh fake
+// database with the given name, speaking the dialect of the given driver.
+func openFakeMigrator(t *testing.T, name, driver string, migrations ...*Migration) (Migrator, *fakedb.Database) {
+	fake := fakedb.New(name)
+	db, err := sql.Open(fakedb.DriverName, name)
+	if err != nil {
+		t.Fatalf("fake database could not be opened: error = %s", err.Error())
+	}
+	d, err := dialect.For(driver)
+	if err != nil {
+		t.Fatalf("dialect not found: error = %s", err.Error())
+	}
+	migrator, err := NewMigrator(icebox.NewDB(db, d, nil), d, migrations)
+	if err != nil {
+		t.Fatalf("migrator could not be constructed: error = %s", err.Error())
+	}
+	return migrator, fake
+}
+
+// Get the versions recorded in the history table of the given database.
+func historyVersions(fake *fakedb.Database) []int64 {
+	fake.Lock()
+	defer fake.Unlock()
+	var versions []int64
+	for _, row := range fake.History {
+		versions = append(versions, row.Version)
+	}
+	return versions
+}
+
+// Returns whether the given statement was run against the given database.
+func ranStatement(fake *fakedb.Database, statement string) bool {
+	fake.Lock()
+	defer fake.Unlock()
+	for _, ran := range fake.Statements {
+		if ran.Query == statement {
+			return true
+		}
+	}
+	return false
+}
+
+// Test that Up applies the pending migrations in order, Status reports them
+// as applied, and Down reverts the latest one.
+func TestMigratorUpDown(t *testing.T) {
+	goRan := false
+	migrator, fake := openFakeMigrator(t, "up_down", "sqlite3",
+		&Migration{Version: 2, Name: "backfill", UpFunc: func(tx *icebox.Tx) error {
+			goRan = true
+			return nil
+		}},
+		&Migration{Version: 1, Name: "create_users",
+			Up: "CREATE TABLE users (id INTEGER)", Down: "DROP TABLE users"},
+	)
+
+	count, err := migrator.Up()
+	if err != nil {
+		t.Fatalf("migrations could not be applied: error = %s", err.Error())
+	}
+	if count != 2 || !goRan || !ranStatement(fake, "CREATE TABLE users (id INTEGER)") {
+		t.Errorf("migrations not applied: count = %d, go migration ran = %v", count, goRan)
+	}
+	if versions := historyVersions(fake); len(versions) != 2 || versions[0] != 1 || versions[1] != 2 {
+		t.Errorf("history incorrect: versions = %v", versions)
+	}
+	if fake.Locked {
+		t.Errorf("migration lock not released")
+	}
+	if count, err := migrator.Up(); err != nil || count != 0 {
+		t.Errorf("applied migrations applied again: count = %d, error = %v", count, err)
+	}
+
+	statuses, err := migrator.Status()
+	if err != nil {
+		t.Fatalf("status could not be read: error = %s", err.Error())
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil || status.Modified {
+			t.Errorf("status incorrect: version = %d, applied at = %v, modified = %v",
+				status.Migration.Version, status.AppliedAt, status.Modified)
+		}
+	}
+
+	if _, err := migrator.Down(); err == nil {
+		t.Errorf("error not raised for a migration which cannot be reverted")
+	}
+	fake.Lock()
+	fake.History = fake.History[:1]
+	fake.Unlock()
+	reverted, err := migrator.Down()
+	if err != nil {
+		t.Fatalf("migration could not be reverted: error = %s", err.Error())
+	}
+	if reverted == nil || reverted.Version != 1 || !ranStatement(fake, "DROP TABLE users") {
+		t.Errorf("migration not reverted: reverted = %v", reverted)
+	}
+	if versions := historyVersions(fake); len(versions) != 0 {
+		t.Errorf("history incorrect: versions = %v", versions)
+	}
+	if reverted, err := migrator.Down(); reverted != nil || err != nil {
+		t.Errorf("migration reverted from an empty history: reverted = %v, error = %v", reverted, err)
+	}
+}
+
+// Test that Up and Down refuse to run while the migration lock is held by
+// another process, and when an advisory lock is not acquired.
+func TestMigratorLocked(t *testing.T) {
+	migration := &Migration{Version: 1, Name: "create_users", Up: "CREATE TABLE users (id INTEGER)"}
+	migrator, fake := openFakeMigrator(t, "locked_table", "sqlite3", migration)
+	fake.Locked = true
+	if _, err := migrator.Up(); err == nil {
+		t.Errorf("error not raised for a held lock table")
+	}
+	if _, err := migrator.Down(); err == nil {
+		t.Errorf("error not raised for a held lock table")
+	}
+	if ranStatement(fake, migration.Up) || !fake.Locked {
+		t.Errorf("migration ran without the lock")
+	}
+
+	testCases := []struct {
+		driver string
+		result interface{}
+	}{
+		{"mysql", int64(0)},
+		{"mysql", nil},
+		{"postgres", nil},
+	}
+	for _, tc := range testCases {
+		migrator, fake := openFakeMigrator(t, "locked_advisory", tc.driver, migration)
+		fake.LockResult = tc.result
+		if _, err := migrator.Up(); err == nil {
+			t.Errorf("error not raised for an advisory lock not acquired: driver = %s, result = %v",
+				tc.driver, tc.result)
+		}
+		if ranStatement(fake, migration.Up) {
+			t.Errorf("migration ran without the lock: driver = %s", tc.driver)
+		}
+	}
+
+	migrator, fake = openFakeMigrator(t, "locked_advisory", "postgres", migration)
+	if count, err := migrator.Up(); err != nil || count != 1 {
+		t.Errorf("migration not applied with the advisory lock: count = %d, error = %v", count, err)
+	}
+	if !ranStatement(fake, "SELECT pg_advisory_unlock(hashtext($1))") {
+		t.Errorf("advisory lock not released")
+	}
+}
+
+// Test that Up and Down refuse to run once an applied migration has been
+// edited, and that Status reports it as modified.
+func TestMigratorChecksumMismatch(t *testing.T) {
+	migration := &Migration{Version: 1, Name: "create_users",
+		Up: "CREATE TABLE users (id INTEGER)", Down: "DROP TABLE users"}
+	migrator, fake := openFakeMigrator(t, "checksum", "sqlite3", migration)
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("migration could not be applied: error = %s", err.Error())
+	}
+	migration.Up = "CREATE TABLE users (id BIGINT)"
+
+	if _, err := migrator.Up(); err == nil {
+		t.Errorf("error not raised for an edited migration")
+	} else if _, ok := err.(*checksumError); !ok {
+		t.Errorf("wrong error raised for an edited migration: error = %s", err.Error())
+	}
+	if _, err := migrator.Down(); err == nil {
+		t.Errorf("error not raised for an edited migration")
+	}
+	if ranStatement(fake, migration.Down) || fake.Locked {
+		t.Errorf("edited migration reverted, or lock not released")
+	}
+	statuses, err := migrator.Status()
+	if err != nil || len(statuses) != 1 || !statuses[0].Modified {
+		t.Errorf("edited migration not reported: statuses = %v, error = %v", statuses, err)
+	}
+}
+
+// Test that a history table created before migrations were checksummed is
+// given a checksum column, and that its migrations are adopted.
+func TestMigratorHistoryUpgrade(t *testing.T) {
+	first := &Migration{Version: 1, Name: "create_users", Up: "CREATE TABLE users (id INTEGER)"}
+	second := &Migration{Version: 2, Name: "create_posts", Up: "CREATE TABLE posts (id INTEGER)"}
+	migrator, fake := openFakeMigrator(t, "upgrade", "sqlite3", first, second)
+	fake.Legacy = true
+	fake.History = []fakedb.HistoryRow{{Version: 1, Name: "create_users", AppliedAt: time.Now()}}
+
+	count, err := migrator.Up()
+	if err != nil {
+		t.Fatalf("migrations could not be applied: error = %s", err.Error())
+	}
+	if count != 1 || ranStatement(fake, first.Up) || !ranStatement(fake, second.Up) {
+		t.Errorf("pending migrations incorrect: count = %d", count)
+	}
+	expected := `ALTER TABLE "schema_migrations" ADD COLUMN "checksum" VARCHAR(64) NOT NULL DEFAULT ''`
+	if fake.Legacy || !ranStatement(fake, expected) {
+		t.Errorf("history table not upgraded: statements = %v", fake.Statements)
+	}
+	if fake.History[0].Checksum != first.Checksum() {
+		t.Errorf("checksum not recorded: checksum = %s, expected = %s",
+			fake.History[0].Checksum, first.Checksum())
+	}
+}
+
+// Test that a failure to list the columns of the history table is returned
+// rather than taken for a missing checksum column.
+func TestMigratorHistoryProbeError(t *testing.T) {
+	migration := &Migration{Version: 1, Name: "create_users", Up: "CREATE TABLE users (id INTEGER)"}
+	migrator, fake := openFakeMigrator(t, "probe", "sqlite3", migration)
+	fake.Fail = func(query string) error {
+		if strings.Contains(query, "WHERE 1 = 0") {
+			return errors.New("connection reset")
+		}
+		return nil
+	}
+
+	if _, err := migrator.Up(); err == nil || !strings.Contains(err.Error(), "connection reset") {
+		t.Errorf("probe error not returned: error = %v", err)
+	}
+	for _, statement := range fake.Statements {
+		if strings.HasPrefix(statement.Query, "ALTER TABLE") || statement.Query == migration.Up {
+			t.Errorf("statement run after a failed probe: statement = %s", statement.Query)
+		}
+	}
+}
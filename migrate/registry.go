@@ -0,0 +1,72 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrate
+
+import (
+	"github.com/jadengis/icebox"
+	"sync"
+)
+
+// The migrations registered with the package, keyed by version.
+var registry = struct {
+	sync.Mutex
+	migrations map[int64]*Migration
+}{migrations: make(map[int64]*Migration)}
+
+// Register adds a migration written in Go to the package registry. It is
+// intended to be called from the init function of the file defining the
+// migration, in the same way database/sql drivers register themselves.
+//
+// Register panics if the migration has nothing to apply, or if a migration
+// with the same version has already been registered.
+func Register(version int64, name string, up, down func(*icebox.Tx) error) {
+	RegisterMigration(&Migration{
+		Version:  version,
+		Name:     name,
+		UpFunc:   up,
+		DownFunc: down,
+	})
+}
+
+// RegisterMigration adds the given migration, which may mix SQL and Go code,
+// to the package registry. It panics under the same conditions as Register.
+func RegisterMigration(migration *Migration) {
+	registry.Lock()
+	defer registry.Unlock()
+	if !migration.canApply() {
+		panic((&migrationError{
+			version: migration.Version,
+			msg:     "migration has nothing to apply"}).Error())
+	}
+	if existing, found := registry.migrations[migration.Version]; found {
+		panic((&migrationError{
+			version: migration.Version,
+			msg:     "version is shared by " + existing.Name + " and " + migration.Name}).Error())
+	}
+	registry.migrations[migration.Version] = migration
+}
+
+// Registered returns the migrations added to the package registry, sorted by
+// version.
+func Registered() []*Migration {
+	registry.Lock()
+	defer registry.Unlock()
+	migrations := make([]*Migration, 0, len(registry.migrations))
+	for _, migration := range registry.migrations {
+		migrations = append(migrations, migration)
+	}
+	sortMigrations(migrations)
+	return migrations
+}
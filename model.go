@@ -37,17 +37,17 @@ type IdEntity interface {
 // For a new type to be recognized as an icebox entity, it must contain this
 // Model as an embedded type.
 type Model struct {
-	Id        Id         `icebox:"column:id,primaryKey"`
+	ID        Id         `icebox:"column:id,primaryKey"`
 	CreatedAt *time.Time `icebox:"column:created_at"`
 	UpdatedAt *time.Time `icebox:"column:updated_at"`
 }
 
 // Id retrieves this Models underlying Id.
 func (m *Model) Id() Id {
-	return m.id
+	return m.ID
 }
 
 // SetId sets this Models underlying Id.
 func (m *Model) SetId(id Id) {
-	m.id = id
+	m.ID = id
 }
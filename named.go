@@ -0,0 +1,203 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icebox
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"reflect"
+	"unicode"
+)
+
+// Named starts a raw query with :name parameters, which are bound from the
+// given map or struct and rewritten to the placeholders of the dialect, for
+// example
+//
+//	db.Named("SELECT * FROM users WHERE team = :team AND role IN (:roles)",
+//		map[string]interface{}{"team": 7, "roles": []string{"admin", "owner"}})
+//
+// Maps must be keyed by string. Structs, or pointers to structs, are bound by
+// the column names of their fields, as Raw scans them. Slices are expanded
+// into a list of placeholders, one per element, for use in IN clauses. An
+// empty slice is bound as NULL, so that IN matches nothing, and beware that
+// NOT IN matches nothing either, since a comparison with NULL is never true.
+// Byte slices and values implementing driver.Valuer, such as Array, are bound
+// as one value. Any ? in the query is left as is, so operators such as the ?
+// of jsonb can be used on PostgreSQL.
+func (db *DB) Named(query string, arg interface{}) *RawQuery {
+	r := &RawQuery{db: db, q: db.DB}
+	r.bindNamed(query, arg)
+	return r
+}
+
+// Named starts a raw query with named parameters within the transaction, as
+// DB.Named does.
+func (tx *Tx) Named(query string, arg interface{}) *RawQuery {
+	r := &RawQuery{db: tx.db, q: tx.Tx}
+	r.bindNamed(query, arg)
+	return r
+}
+
+// WhereNamed restricts the query to the rows matching the given condition
+// with :name parameters bound from the given map or struct, as DB.Named
+// binds them.
+func (q *Query) WhereNamed(expression string, arg interface{}) *Query {
+	values, err := namedValues(q.db, arg)
+	if err != nil {
+		q.fail(err)
+		return q
+	}
+	expression, args, err := bindNames(expression, values, questionMark)
+	if err != nil {
+		q.fail(err)
+		return q
+	}
+	return q.Where(expression, args...)
+}
+
+// Bind the named parameters of the given query to the given argument.
+func (r *RawQuery) bindNamed(query string, arg interface{}) {
+	values, err := namedValues(r.db, arg)
+	if err != nil {
+		r.err = err
+		return
+	}
+	r.query, r.args, r.err = bindNames(query, values, r.db.dialect.Placeholder)
+}
+
+// Render the ? placeholder, whatever its position, for expressions which are
+// rewritten to the placeholders of the dialect once they are built into a
+// query.
+func questionMark(int) string {
+	return "?"
+}
+
+// Get the function looking up the values of named parameters in the given
+// map or struct.
+func namedValues(db *DB, arg interface{}) (func(string) (interface{}, bool, error), error) {
+	value := reflect.Indirect(reflect.ValueOf(arg))
+	switch value.Kind() {
+	case reflect.Map:
+		if value.Type().Key().Kind() != reflect.String {
+			break
+		}
+		return func(name string) (interface{}, bool, error) {
+			element := value.MapIndex(reflect.ValueOf(name).Convert(value.Type().Key()))
+			if !element.IsValid() {
+				return nil, false, nil
+			}
+			return element.Interface(), true, nil
+		}, nil
+	case reflect.Struct:
+		table, err := db.rowTable(value.Type())
+		if err != nil {
+			return nil, err
+		}
+		fields := planFor(table).byName
+		return func(name string) (interface{}, bool, error) {
+			f, found := fields[name]
+			if !found {
+				return nil, false, nil
+			}
+			field, ok := fieldValue(value, f.index)
+			arg, err := columnValue(db.dialect, f.column, field, ok)
+			return arg, true, err
+		}, nil
+	}
+	return nil, &destinationError{
+		badType: reflect.TypeOf(arg),
+		msg:     "named parameters must be bound from a map keyed by string or a struct"}
+}
+
+// Rewrite the :name parameters of the given query to the placeholders
+// rendered by the given function, looking up their values with the given
+// function. Slices are expanded into one placeholder per element. Casts such
+// as ::text, text within quotes, and any ? in the query, such as the jsonb
+// operators of PostgreSQL, are kept.
+func bindNames(query string, lookup func(string) (interface{}, bool, error), placeholder func(int) string) (string, []interface{}, error) {
+	var buffer bytes.Buffer
+	var args []interface{}
+	var quote rune
+	runes := []rune(query)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == ':' && i+1 < len(runes) && runes[i+1] == ':':
+			buffer.WriteString("::")
+			i++
+			continue
+		case r == ':' && i+1 < len(runes) && isNameStart(runes[i+1]):
+			end := i + 1
+			for end < len(runes) && isNamePart(runes[end]) {
+				end++
+			}
+			name := string(runes[i+1 : end])
+			value, found, err := lookup(name)
+			if err != nil {
+				return "", nil, err
+			}
+			if !found {
+				return "", nil, &queryError{msg: "no value for parameter :" + name}
+			}
+			args = appendNamedArg(&buffer, args, value, placeholder)
+			i = end - 1
+			continue
+		}
+		buffer.WriteRune(r)
+	}
+	return buffer.String(), args, nil
+}
+
+// Write the placeholders of the given value to the buffer, numbered after
+// the given arguments, and append the value to the arguments, expanding
+// slices into their elements.
+func appendNamedArg(buffer *bytes.Buffer, args []interface{}, value interface{}, placeholder func(int) string) []interface{} {
+	slice := reflect.ValueOf(value)
+	_, valuer := value.(driver.Valuer)
+	expand := !valuer && (slice.Kind() == reflect.Slice || slice.Kind() == reflect.Array) &&
+		slice.Type().Elem().Kind() != reflect.Uint8
+	if !expand {
+		buffer.WriteString(placeholder(len(args) + 1))
+		return append(args, value)
+	}
+	if slice.Len() == 0 {
+		buffer.WriteString("NULL")
+		return args
+	}
+	for i := 0; i < slice.Len(); i++ {
+		if i > 0 {
+			buffer.WriteString(", ")
+		}
+		buffer.WriteString(placeholder(len(args) + 1))
+		args = append(args, slice.Index(i).Interface())
+	}
+	return args
+}
+
+// Returns whether the given rune can start a parameter name.
+func isNameStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+// Returns whether the given rune can be part of a parameter name.
+func isNamePart(r rune) bool {
+	return isNameStart(r) || unicode.IsDigit(r)
+}
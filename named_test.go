@@ -0,0 +1,100 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icebox
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Test that named parameters are rewritten to the placeholders of the
+// dialect, expanding slices.
+func TestNamedParameters(t *testing.T) {
+	db := openFakeAccounts(t, "postgres")
+	defer db.Close()
+
+	testCases := []struct {
+		query    string
+		arg      interface{}
+		expected string
+		args     []interface{}
+	}{
+		{
+			"SELECT * FROM t WHERE a = :a AND b IN (:b) AND c = :a",
+			map[string]interface{}{"a": 1, "b": []string{"x", "y"}},
+			"SELECT * FROM t WHERE a = $1 AND b IN ($2, $3) AND c = $4",
+			[]interface{}{1, "x", "y", 1},
+		},
+		{
+			"SELECT ':a', name::text FROM t WHERE b IN (:b)",
+			map[string][]int{"b": {}},
+			"SELECT ':a', name::text FROM t WHERE b IN (NULL)",
+			nil,
+		},
+		{
+			"SELECT * FROM t WHERE email = :email AND id > :id",
+			fakeAccount{Id: 4, Email: "a@x"},
+			"SELECT * FROM t WHERE email = $1 AND id > $2",
+			[]interface{}{"a@x", int64(4)},
+		},
+		{
+			"SELECT * FROM t WHERE data = :data",
+			map[string]interface{}{"data": []byte("raw")},
+			"SELECT * FROM t WHERE data = $1",
+			[]interface{}{[]byte("raw")},
+		},
+		{
+			"SELECT * FROM t WHERE data ? :key AND tags ?| :tags AND note = '?'",
+			map[string]interface{}{"key": "k", "tags": "{a}"},
+			"SELECT * FROM t WHERE data ? $1 AND tags ?| $2 AND note = '?'",
+			[]interface{}{"k", "{a}"},
+		},
+	}
+	for _, tc := range testCases {
+		raw := db.Named(tc.query, tc.arg)
+		if raw.err != nil {
+			t.Fatalf("parameters could not be bound: error = %s", raw.err.Error())
+		}
+		if raw.query != tc.expected || !reflect.DeepEqual(raw.args, tc.args) {
+			t.Errorf("parameters bound incorrectly: query = %s, args = %v, expected = %s, %v",
+				raw.query, raw.args, tc.expected, tc.args)
+		}
+	}
+
+	if err := db.Named("SELECT :missing", map[string]int{}).Scan(&[]fakeAccount{}); err == nil {
+		t.Errorf("error not raised for a missing parameter")
+	}
+	if err := db.Named("SELECT :a", 5).Scan(&[]fakeAccount{}); err == nil {
+		t.Errorf("error not raised for parameters bound from an int")
+	}
+}
+
+// Test that builder conditions take named parameters.
+func TestWhereNamed(t *testing.T) {
+	db := openFakeAccounts(t, "postgres")
+	defer db.Close()
+
+	query, args, err := db.From(new(fakeAccount)).
+		Where(`"name" <> ?`, "").
+		WhereNamed(`"id" IN (:ids)`, map[string]interface{}{"ids": []int64{1, 2}}).
+		SQL()
+	if err != nil {
+		t.Fatalf("query could not be built: error = %s", err.Error())
+	}
+	expected := `SELECT "id", "email", "name" FROM "fake_accounts" WHERE ("name" <> $1) AND ("id" IN ($2, $3))`
+	if query != expected || len(args) != 3 {
+		t.Errorf("query incorrect: query = %s, args = %v, expected = %s", query, args, expected)
+	}
+}
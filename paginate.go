@@ -0,0 +1,352 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icebox
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/jadengis/icebox/schema"
+	"reflect"
+	"strings"
+)
+
+// Paginator pages through the rows of a Query with keyset pagination: each
+// page starts after the sort key of the last row of the previous page, so
+// that pages are read from an index however deep they are, unlike with an
+// offset. For example
+//
+//	paginator := db.From(new(Post)).Where(`"draft" = ?`, false).
+//		Paginate(50, secret).
+//		SortBy("published_at", true)
+//	page, err := paginator.Page(token, &posts)
+//
+// Pages are identified by opaque tokens, signed with the secret of the
+// paginator so that they cannot be forged or tampered with.
+type Paginator struct {
+	query  *Query
+	size   int
+	secret []byte
+	keys   []sortKey
+	err    error
+}
+
+// Page describes the position of a page read by a Paginator.
+//
+// Next is the token of the following page, empty if this is the last page.
+//
+// Previous is the token of the preceding page, empty if this is the first
+// page.
+type Page struct {
+	Next     string
+	Previous string
+}
+
+// A column rows are sorted by, with its direction.
+type sortKey struct {
+	column     string
+	descending bool
+}
+
+// Get the name of the sort key in page tokens, prefixed with a minus sign
+// when descending.
+func (k sortKey) String() string {
+	if k.descending {
+		return "-" + k.column
+	}
+	return k.column
+}
+
+// The payload of a page token: the table and a hash of the conditions of the
+// query it was issued for, the sort key columns, the direction of the page it
+// leads to, and the values of the sort key columns of the row the page starts
+// after.
+type pageToken struct {
+	Table    string            `json:"t"`
+	Where    []byte            `json:"w"`
+	Keys     []string          `json:"k"`
+	Backward bool              `json:"b,omitempty"`
+	Values   []json.RawMessage `json:"v"`
+}
+
+// Paginate pages through the rows of the query, reading the given number of
+// rows per page. The ordering, limit and offset of the query are replaced by
+// those of the pages. The secret signs the page tokens, and must be kept
+// private.
+//
+// Page returns an error if the size is not positive or the secret is empty,
+// as tokens signed with an empty secret could be forged by anyone.
+func (q *Query) Paginate(size int, secret []byte) *Paginator {
+	p := &Paginator{query: q, size: size, secret: secret}
+	switch {
+	case size <= 0:
+		p.err = &queryError{msg: "page size must be positive"}
+	case len(secret) == 0:
+		p.err = &queryError{msg: "page tokens cannot be signed with an empty secret"}
+	}
+	return p
+}
+
+// SortBy adds a column the rows are sorted by. The column must be indexed,
+// unique or the primary key, so that pages can be read from an index, and
+// should not be NULL. The primary key is added as the last sort key unless it
+// already is one, so that rows with equal values of the other sort keys keep
+// a stable order, and it is the only one by default.
+func (p *Paginator) SortBy(column string, descending bool) *Paginator {
+	p.keys = append(p.keys, sortKey{column: column, descending: descending})
+	return p
+}
+
+// Page reads the page with the given token into dest, which must be a
+// pointer to a slice of structs or of pointers to structs of the type of the
+// table of the query, and returns the tokens of the pages around it. An
+// empty token reads the first page.
+//
+// This returns an error if the token is invalid, was signed with another
+// secret, or was issued for another table, other conditions or other sort
+// keys.
+func (p *Paginator) Page(token string, dest interface{}) (*Page, error) {
+	q := p.query
+	if q.err != nil {
+		return nil, q.err
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	keys, fields, err := p.sortFields()
+	if err != nil {
+		return nil, err
+	}
+	slice := reflect.ValueOf(dest)
+	if slice.Kind() != reflect.Ptr || slice.Elem().Kind() != reflect.Slice {
+		return nil, &destinationError{
+			badType: reflect.TypeOf(dest),
+			msg:     "destination must be a pointer to a slice"}
+	}
+	slice = slice.Elem()
+
+	page := q.clone()
+	page.order, page.limit, page.offset = nil, p.size+1, 0
+	var after *pageToken
+	if token != "" {
+		if after, err = p.decode(token, keys); err != nil {
+			return nil, err
+		}
+		args, err := p.keyValues(after, fields)
+		if err != nil {
+			return nil, err
+		}
+		page.Where(keysetCondition(q, keys, after.Backward), args...)
+	}
+	backward := after != nil && after.Backward
+	for _, key := range keys {
+		expression := q.db.dialect.Quote(key.column)
+		if key.descending != backward {
+			expression += " DESC"
+		}
+		page.OrderBy(expression)
+	}
+
+	slice.Set(slice.Slice(0, 0))
+	if err := page.All(dest); err != nil {
+		return nil, err
+	}
+	more := slice.Len() > p.size
+	if more {
+		slice.Set(slice.Slice(0, p.size))
+	}
+	if backward {
+		swap := reflect.Swapper(slice.Interface())
+		for i, j := 0, slice.Len()-1; i < j; i, j = i+1, j-1 {
+			swap(i, j)
+		}
+	}
+
+	result := new(Page)
+	if slice.Len() == 0 {
+		return result, nil
+	}
+	hasNext, hasPrevious := more, after != nil
+	if backward {
+		hasNext, hasPrevious = true, more
+	}
+	if hasNext {
+		last := reflect.Indirect(slice.Index(slice.Len() - 1))
+		if result.Next, err = p.encode(keys, fields, last, false); err != nil {
+			return nil, err
+		}
+	}
+	if hasPrevious {
+		first := reflect.Indirect(slice.Index(0))
+		if result.Previous, err = p.encode(keys, fields, first, true); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Get the sort keys of the paginator, ending with the primary key, along
+// with their fields. This returns an error if a sort key is not indexed.
+func (p *Paginator) sortFields() ([]sortKey, []*fieldPlan, error) {
+	plan := planFor(p.query.table)
+	if plan.primaryKey == nil {
+		return nil, nil, &queryError{msg: "table " + p.query.table.Name() + " has no primary key to paginate on"}
+	}
+	keys := p.keys
+	primaryKey := plan.primaryKey.column.Name()
+	found := false
+	for _, key := range keys {
+		found = found || key.column == primaryKey
+	}
+	if !found {
+		keys = append(append([]sortKey(nil), keys...), sortKey{column: primaryKey})
+	}
+
+	fields := make([]*fieldPlan, len(keys))
+	for i, key := range keys {
+		field := plan.byName[key.column]
+		if field == nil {
+			return nil, nil, &queryError{msg: "no field for sort key " + key.column}
+		}
+		if _, indexed := field.column.ConstraintFor(schema.Index); !indexed && !field.unique && !field.primaryKey {
+			return nil, nil, &queryError{msg: "sort key " + key.column + " is not indexed"}
+		}
+		fields[i] = field
+	}
+	return keys, fields, nil
+}
+
+// Build the condition selecting the rows after the sort key values of a
+// token, or before them when paging backward. Each sort key is compared in
+// turn, so that keys of different directions can be mixed.
+func keysetCondition(q *Query, keys []sortKey, backward bool) string {
+	d := q.db.dialect
+	alternatives := make([]string, len(keys))
+	for i, key := range keys {
+		terms := make([]string, 0, i+1)
+		for _, equal := range keys[:i] {
+			terms = append(terms, d.Quote(equal.column)+" = ?")
+		}
+		operator := " > ?"
+		if key.descending != backward {
+			operator = " < ?"
+		}
+		terms = append(terms, d.Quote(key.column)+operator)
+		alternatives[i] = "(" + strings.Join(terms, " AND ") + ")"
+	}
+	return strings.Join(alternatives, " OR ")
+}
+
+// Get the arguments of the keyset condition for the given token: the values
+// of the sort key decoded into the types of their fields, repeated as the
+// condition compares them.
+func (p *Paginator) keyValues(token *pageToken, fields []*fieldPlan) ([]interface{}, error) {
+	values := make([]interface{}, len(fields))
+	for i, field := range fields {
+		decoded := reflect.New(field.fieldType)
+		if err := json.Unmarshal(token.Values[i], decoded.Interface()); err != nil {
+			return nil, &queryError{msg: "invalid page token"}
+		}
+		value, err := columnValue(p.query.db.dialect, field.column, decoded.Elem(), true)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	var args []interface{}
+	for i := range fields {
+		args = append(args, values[:i+1]...)
+	}
+	return args, nil
+}
+
+// Encode the token of the page after the given row, or before it when
+// paging backward.
+func (p *Paginator) encode(keys []sortKey, fields []*fieldPlan, row reflect.Value, backward bool) (string, error) {
+	token := pageToken{Table: p.query.table.Name(), Where: p.conditionHash(), Backward: backward}
+	for i, field := range fields {
+		token.Keys = append(token.Keys, keys[i].String())
+		value, ok := fieldValue(row, field.index)
+		if !ok {
+			return "", &queryError{msg: "sort key " + keys[i].column + " of a row is NULL"}
+		}
+		data, err := json.Marshal(value.Interface())
+		if err != nil {
+			return "", err
+		}
+		token.Values = append(token.Values, data)
+	}
+	payload, err := json.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(p.sign(payload)), nil
+}
+
+// Decode the given token, checking its signature and that it was issued for
+// the table and conditions of the query and the given sort keys.
+func (p *Paginator) decode(token string, keys []sortKey) (*pageToken, error) {
+	invalid := &queryError{msg: "invalid page token"}
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, invalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, invalid
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(signature, p.sign(payload)) {
+		return nil, invalid
+	}
+
+	decoded := new(pageToken)
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	if err := decoder.Decode(decoded); err != nil || len(decoded.Keys) != len(keys) ||
+		len(decoded.Values) != len(keys) {
+		return nil, invalid
+	}
+	if decoded.Table != p.query.table.Name() || !bytes.Equal(decoded.Where, p.conditionHash()) {
+		return nil, &queryError{msg: "page token was issued for another query"}
+	}
+	for i, key := range keys {
+		if decoded.Keys[i] != key.String() {
+			return nil, &queryError{msg: "page token was issued for other sort keys"}
+		}
+	}
+	return decoded, nil
+}
+
+// Hash the conditions of the query, so that a token cannot be used to page
+// through the rows of a query with other conditions. The arguments of the
+// conditions are left out, as they may not have a stable representation.
+func (p *Paginator) conditionHash() []byte {
+	hash := sha256.New()
+	for _, condition := range p.query.conditions {
+		hash.Write([]byte(condition.expression))
+		hash.Write([]byte{0})
+	}
+	return hash.Sum(nil)
+}
+
+// Sign the given token payload with the secret of the paginator.
+func (p *Paginator) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
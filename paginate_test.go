@@ -0,0 +1,198 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icebox
+
+import (
+	"database/sql/driver"
+	"github.com/jadengis/icebox/schema"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Build a result set of fake accounts with the given ids, named after them.
+func fakeAccountRows(ids ...int64) fakeResultSet {
+	result := fakeResultSet{columns: []string{"id", "email", "name"}}
+	for _, id := range ids {
+		name := string(rune('a' + id))
+		result.rows = append(result.rows, []driver.Value{id, name + "@x", name})
+	}
+	return result
+}
+
+// Test that pages start after the sort keys of the previous page in both
+// directions.
+func TestPaginate(t *testing.T) {
+	db := openFakeAccounts(t, "postgres")
+	defer db.Close()
+	paginator := db.From(new(fakeAccount)).
+		Where(`"name" <> ?`, "").
+		Paginate(2, []byte("secret")).
+		SortBy("email", true)
+
+	fakeQueue(fakeAccountRows(5, 4, 3), fakeAccountRows(3, 2), fakeAccountRows(4, 5))
+	var accounts []fakeAccount
+	first, err := paginator.Page("", &accounts)
+	if err != nil {
+		t.Fatalf("first page failed: error = %s", err.Error())
+	}
+	if len(accounts) != 2 || accounts[1].Id != 4 || first.Next == "" || first.Previous != "" {
+		t.Errorf("first page incorrect: rows = %v, page = %+v", accounts, first)
+	}
+	second, err := paginator.Page(first.Next, &accounts)
+	if err != nil {
+		t.Fatalf("second page failed: error = %s", err.Error())
+	}
+	if len(accounts) != 2 || accounts[0].Id != 3 || second.Next != "" || second.Previous == "" {
+		t.Errorf("second page incorrect: rows = %v, page = %+v", accounts, second)
+	}
+	back, err := paginator.Page(second.Previous, &accounts)
+	if err != nil {
+		t.Fatalf("previous page failed: error = %s", err.Error())
+	}
+	if ids := []int64{accounts[0].Id, accounts[1].Id}; !reflect.DeepEqual(ids, []int64{5, 4}) ||
+		back.Next == "" || back.Previous != "" {
+		t.Errorf("previous page incorrect: rows = %v, page = %+v", accounts, back)
+	}
+
+	statements := fakeStatements()
+	expected := []string{
+		`SELECT "id", "email", "name" FROM "fake_accounts" WHERE ("name" <> $1) ` +
+			`ORDER BY "email" DESC, "id" LIMIT 3`,
+		`SELECT "id", "email", "name" FROM "fake_accounts" WHERE ("name" <> $1) ` +
+			`AND (("email" < $2) OR ("email" = $3 AND "id" > $4)) ORDER BY "email" DESC, "id" LIMIT 3`,
+		`SELECT "id", "email", "name" FROM "fake_accounts" WHERE ("name" <> $1) ` +
+			`AND (("email" > $2) OR ("email" = $3 AND "id" < $4)) ORDER BY "email", "id" DESC LIMIT 3`,
+	}
+	for i, statement := range statements {
+		if statement.query != expected[i] {
+			t.Errorf("page query incorrect: query = %s, expected = %s", statement.query, expected[i])
+		}
+	}
+	if args := statements[1].args; !reflect.DeepEqual(args, []driver.Value{"", "e@x", "e@x", int64(4)}) {
+		t.Errorf("page arguments incorrect: args = %v", args)
+	}
+}
+
+type fakeListing struct {
+	Id    int64             `icebox:"column,primaryKey"`
+	Place *fakeListingPlace `icebox:"embedded"`
+}
+
+type fakeListingPlace struct {
+	Rank int64 `icebox:"column,index"`
+}
+
+// Open a fake database with the schema of the fake accounts and listings.
+func openFakeListings(t testing.TB) *DB {
+	s, err := schema.NewSchema("test_schema", new(fakeAccount), new(fakeListing))
+	if err != nil {
+		t.Fatalf("schema could not be generated: error = %s", err.Error())
+	}
+	return openFake(t, "sqlite3", s)
+}
+
+// Test that tampered, forged and mismatched tokens are rejected.
+func TestPaginateTokens(t *testing.T) {
+	db := openFakeAccounts(t, "sqlite3")
+	defer db.Close()
+	fakeQueue(fakeAccountRows(1, 2))
+	query := db.From(new(fakeAccount))
+
+	var accounts []*fakeAccount
+	page, err := query.Paginate(1, []byte("secret")).Page("", &accounts)
+	if err != nil {
+		t.Fatalf("first page failed: error = %s", err.Error())
+	}
+	payload := strings.Split(page.Next, ".")[0]
+	tokens := map[string]*Paginator{
+		"tampered":  query.Paginate(1, []byte("secret")),
+		"forged":    query.Paginate(1, []byte("other")),
+		"mismatch":  query.Paginate(1, []byte("secret")).SortBy("email", false),
+		"malformed": query.Paginate(1, []byte("secret")),
+		"filtered":  db.From(new(fakeAccount)).Where(`"name" = ?`, "b").Paginate(1, []byte("secret")),
+	}
+	for kind, paginator := range tokens {
+		token := page.Next
+		switch kind {
+		case "tampered":
+			token = payload + "x." + strings.Split(page.Next, ".")[1]
+		case "malformed":
+			token = "garbage"
+		}
+		if _, err := paginator.Page(token, &accounts); err == nil {
+			t.Errorf("error not raised for a %s token", kind)
+		}
+	}
+
+	if _, err := query.Paginate(1, []byte("secret")).SortBy("name", false).Page("", &accounts); err == nil {
+		t.Errorf("error not raised for a sort key which is not indexed")
+	}
+}
+
+// Test that paginators without a positive page size or a secret are
+// rejected.
+func TestPaginateInvalid(t *testing.T) {
+	db := openFakeAccounts(t, "sqlite3")
+	defer db.Close()
+	query := db.From(new(fakeAccount))
+
+	testCases := []struct {
+		size   int
+		secret []byte
+	}{
+		{0, []byte("secret")},
+		{-1, []byte("secret")},
+		{1, nil},
+		{1, []byte{}},
+	}
+	for _, tc := range testCases {
+		fakeQueue()
+		var accounts []*fakeAccount
+		if _, err := query.Paginate(tc.size, tc.secret).Page("", &accounts); err == nil {
+			t.Errorf("error not raised for an invalid paginator: size = %d, secret = %q",
+				tc.size, tc.secret)
+		}
+		if statements := fakeStatements(); len(statements) != 0 {
+			t.Errorf("invalid paginator ran a query: statements = %v", statements)
+		}
+	}
+}
+
+// Test that tokens are only accepted for the table they were issued for, and
+// that rows whose sort key cannot be read are reported.
+func TestPaginateScope(t *testing.T) {
+	db := openFakeListings(t)
+	defer db.Close()
+	fakeQueue(fakeAccountRows(1, 2))
+	var accounts []fakeAccount
+	page, err := db.From(new(fakeAccount)).Paginate(1, []byte("secret")).Page("", &accounts)
+	if err != nil {
+		t.Fatalf("first page failed: error = %s", err.Error())
+	}
+	var listings []fakeListing
+	if _, err := db.From(new(fakeListing)).Paginate(1, []byte("secret")).Page(page.Next, &listings); err == nil {
+		t.Errorf("error not raised for a token of another table")
+	}
+
+	fakeQueue(fakeResultSet{
+		columns: []string{"id", "place_rank"},
+		rows:    [][]driver.Value{{int64(1), nil}, {int64(2), nil}},
+	})
+	paginator := db.From(new(fakeListing)).Paginate(1, []byte("secret")).SortBy("place_rank", false)
+	if _, err := paginator.Page("", &listings); err == nil {
+		t.Errorf("error not raised for a sort key behind a nil pointer")
+	}
+}
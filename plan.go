@@ -0,0 +1,146 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icebox
+
+import (
+	"github.com/jadengis/icebox/schema"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// The plans of the tables rows have been read from or written to, by table.
+// Tables are never mutated once they are in use, so a plan is built the first
+// time a table is used and lives as long as the program.
+var tablePlans sync.Map
+
+// The reflection work of reading and writing the rows of a table, done once
+// and shared by every statement on the table.
+//
+// Fields are the columns bound to fields of the type of the table, in
+// ordinal order.
+//
+// ByName are the fields by column name.
+//
+// PrimaryKey is the field of the primary key, if it is bound to one.
+//
+// Tenant is the field of the tenant column, if it is bound to one.
+//
+// Scans are the scan plans of the result column sets read so far, keyed by
+// their joined names.
+//
+// Inserts are the insert plans built so far.
+type tablePlan struct {
+	table      schema.Table
+	fields     []*fieldPlan
+	byName     map[string]*fieldPlan
+	primaryKey *fieldPlan
+	tenant     *fieldPlan
+	mutex      sync.Mutex
+	scans      map[string][]*fieldPlan
+	inserts    map[insertKey]*insertPlan
+}
+
+// The binding of a column to a field of the type of its table.
+//
+// Index is the field index of the column.
+//
+// FieldType is the type of the field.
+//
+// Optional reports whether the path to the field goes through a pointer to
+// an embedded struct.
+//
+// Exported reports whether every field on the path is exported, so that the
+// field can be set.
+type fieldPlan struct {
+	column     schema.Column
+	index      []int
+	fieldType  reflect.Type
+	optional   bool
+	exported   bool
+	primaryKey bool
+	unique     bool
+	tenant     bool
+}
+
+// Get the plan of the given table, building it on first use.
+func planFor(table schema.Table) *tablePlan {
+	if plan, found := tablePlans.Load(table); found {
+		return plan.(*tablePlan)
+	}
+	plan, _ := tablePlans.LoadOrStore(table, newTablePlan(table))
+	return plan.(*tablePlan)
+}
+
+// Build the plan of the given table.
+func newTablePlan(table schema.Table) *tablePlan {
+	plan := &tablePlan{
+		table:   table,
+		byName:  make(map[string]*fieldPlan),
+		scans:   make(map[string][]*fieldPlan),
+		inserts: make(map[insertKey]*insertPlan),
+	}
+	if table.Type() == nil {
+		return plan
+	}
+	for _, column := range table.Columns() {
+		if column.FieldIndex() == nil {
+			continue
+		}
+		field := &fieldPlan{column: column, index: column.FieldIndex()}
+		field.fieldType, field.optional, field.exported = fieldTypeByIndex(table.Type(), field.index)
+		_, field.primaryKey = column.ConstraintFor(schema.PrimaryKey)
+		_, field.unique = column.ConstraintFor(schema.Unique)
+		field.tenant = column.Metadata()[schema.MetadataTenant] == "true"
+		if field.primaryKey && plan.primaryKey == nil {
+			plan.primaryKey = field
+		}
+		if field.tenant && plan.tenant == nil {
+			plan.tenant = field
+		}
+		plan.fields = append(plan.fields, field)
+		plan.byName[column.Name()] = field
+	}
+	return plan
+}
+
+// Get the fields the given result columns are read into, nil for the
+// columns which are discarded. This returns an error if a column is bound to
+// a field which cannot be set.
+func (p *tablePlan) scanPlan(names []string) ([]*fieldPlan, error) {
+	key := strings.Join(names, "\x00")
+	p.mutex.Lock()
+	fields, found := p.scans[key]
+	p.mutex.Unlock()
+	if found {
+		return fields, nil
+	}
+
+	fields = make([]*fieldPlan, len(names))
+	for i, name := range names {
+		field := p.byName[name]
+		if field != nil && !field.exported {
+			return nil, &columnError{
+				cause:  &destinationError{badType: p.table.Type(), msg: "field cannot be set"},
+				column: name,
+				msg:    "could not scan row"}
+		}
+		fields[i] = field
+	}
+	p.mutex.Lock()
+	p.scans[key] = fields
+	p.mutex.Unlock()
+	return fields, nil
+}
@@ -0,0 +1,153 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icebox
+
+import (
+	"database/sql/driver"
+	"strconv"
+	"testing"
+)
+
+// Test that plans are built once per table and shared between statements.
+func TestTablePlan(t *testing.T) {
+	db := openFakeAccounts(t, "sqlite3")
+	defer db.Close()
+	table, _ := db.Schema().TableFor(new(fakeAccount))
+
+	plan := planFor(table)
+	if planFor(table) != plan {
+		t.Errorf("table plan rebuilt for the same table")
+	}
+	if plan.primaryKey == nil || plan.primaryKey.column.Name() != "id" {
+		t.Errorf("primary key missing from the plan")
+	}
+	if field := plan.byName["email"]; field == nil || !field.unique || field.index[0] != 1 {
+		t.Errorf("email field planned incorrectly: field = %+v", field)
+	}
+
+	names := []string{"name", "unknown", "id"}
+	fields, err := plan.scanPlan(names)
+	if err != nil {
+		t.Fatalf("scan plan could not be built: error = %s", err.Error())
+	}
+	if fields[0] != plan.byName["name"] || fields[1] != nil || fields[2] != plan.primaryKey {
+		t.Errorf("scan plan incorrect: fields = %v", fields)
+	}
+	if again, _ := plan.scanPlan(names); &again[0] != &fields[0] {
+		t.Errorf("scan plan rebuilt for the same columns")
+	}
+
+	fakeQueue()
+	db.Insert(&fakeAccount{Email: "a@x"})
+	db.Insert(&fakeAccount{Email: "b@x"})
+	if len(plan.inserts) != 1 {
+		t.Errorf("insert plan not reused: plans = %d", len(plan.inserts))
+	}
+}
+
+// The number of rows read by the scan benchmarks.
+const benchmarkRows = 100
+
+// Build the result set read by the scan benchmarks.
+func benchmarkResult() fakeResultSet {
+	result := fakeResultSet{columns: []string{"id", "email", "name"}}
+	for i := 0; i < benchmarkRows; i++ {
+		id := strconv.Itoa(i)
+		result.rows = append(result.rows, []driver.Value{int64(i), id + "@x", "user " + id})
+	}
+	return result
+}
+
+// Benchmark reading rows with a cursor, for comparison with
+// BenchmarkScanDatabaseSQL.
+func BenchmarkScanCursor(b *testing.B) {
+	db := openFakeAccounts(b, "sqlite3")
+	defer db.Close()
+	result := benchmarkResult()
+	query := db.From(new(fakeAccount))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fakeQueue(result)
+		cursor, err := query.Cursor()
+		if err != nil {
+			b.Fatal(err)
+		}
+		var account fakeAccount
+		for cursor.Next() {
+			if err := cursor.Scan(&account); err != nil {
+				b.Fatal(err)
+			}
+		}
+		cursor.Close()
+	}
+}
+
+// Benchmark reading rows with hand-written database/sql scanning.
+func BenchmarkScanDatabaseSQL(b *testing.B) {
+	db := openFakeAccounts(b, "sqlite3")
+	defer db.Close()
+	result := benchmarkResult()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fakeQueue(result)
+		rows, err := db.Query(`SELECT "id", "email", "name" FROM "fake_accounts"`)
+		if err != nil {
+			b.Fatal(err)
+		}
+		var account fakeAccount
+		for rows.Next() {
+			if err := rows.Scan(&account.Id, &account.Email, &account.Name); err != nil {
+				b.Fatal(err)
+			}
+		}
+		rows.Close()
+	}
+}
+
+// Benchmark inserting a row, for comparison with BenchmarkInsertDatabaseSQL.
+func BenchmarkInsert(b *testing.B) {
+	db := openFakeAccounts(b, "sqlite3")
+	defer db.Close()
+	account := &fakeAccount{Email: "a@x", Name: "a"}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fakeQueue()
+		account.Id = 0
+		if err := db.Insert(account); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Benchmark inserting a row with a hand-written database/sql statement.
+func BenchmarkInsertDatabaseSQL(b *testing.B) {
+	db := openFakeAccounts(b, "sqlite3")
+	defer db.Close()
+	account := &fakeAccount{Email: "a@x", Name: "a"}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fakeQueue()
+		result, err := db.Exec(`INSERT INTO "fake_accounts" ("email", "name") VALUES (?, ?)`,
+			account.Email, account.Name)
+		if err != nil {
+			b.Fatal(err)
+		}
+		account.Id, _ = result.LastInsertId()
+	}
+}
@@ -0,0 +1,362 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icebox
+
+import (
+	"bytes"
+	"database/sql"
+	"github.com/jadengis/icebox/dialect"
+	"github.com/jadengis/icebox/schema"
+	"reflect"
+	"strconv"
+)
+
+// Query builds a SELECT statement over the table of a type in the schema of
+// a DB. Its methods return the query so calls can be chained, for example
+//
+//	cursor, err := db.From(new(User)).
+//		Where("age >= ?", 18).
+//		OrderBy(`"id"`).
+//		Cursor()
+//
+// Conditions are SQL expressions with ? placeholders, which are rewritten to
+// the placeholders of the dialect. Errors are collected as the query is
+// described and returned when it is run.
+type Query struct {
+	db         *DB
+	q          queryer
+	table      schema.Table
+	conditions []condition
+	order      []string
+	columns    []string
+	distinct   bool
+	unscoped   bool
+	limit      int
+	offset     int
+	err        error
+}
+
+// A condition of the WHERE clause of a Query, with its arguments.
+type condition struct {
+	expression string
+	args       []interface{}
+}
+
+// From starts a query over the table of the type of the given object, which
+// must be a struct or pointer to struct of a type in the schema of the DB.
+// The query runs on a replica of the DB, if it has any.
+func (db *DB) From(object interface{}) *Query {
+	return newQuery(db.readQueryer(), db, object)
+}
+
+// From starts a query within the transaction, as DB.From does.
+func (tx *Tx) From(object interface{}) *Query {
+	return newQuery(tx.queryer(), tx.db, object)
+}
+
+// Construct a query run through the given queryer.
+func newQuery(q queryer, db *DB, object interface{}) *Query {
+	query := &Query{db: db, q: q}
+	query.table, query.err = db.tableFor(object)
+	return query
+}
+
+// Where restricts the query to the rows matching the given condition. The
+// conditions of successive calls are combined with AND.
+func (q *Query) Where(expression string, args ...interface{}) *Query {
+	q.conditions = append(q.conditions, condition{expression: expression, args: args})
+	return q
+}
+
+// Contains restricts the query to the rows whose array column contains every
+// element of the given slice.
+func (q *Query) Contains(column string, slice interface{}) *Query {
+	return q.arrayCondition(column, func(a dialect.Arrayer, column, placeholder string) string {
+		return a.ArrayContains(column, placeholder)
+	}, Array(slice))
+}
+
+// Overlaps restricts the query to the rows whose array column shares an
+// element with the given slice.
+func (q *Query) Overlaps(column string, slice interface{}) *Query {
+	return q.arrayCondition(column, func(a dialect.Arrayer, column, placeholder string) string {
+		return a.ArrayOverlaps(column, placeholder)
+	}, Array(slice))
+}
+
+// Any restricts the query to the rows whose array column has the given value
+// as an element.
+func (q *Query) Any(column string, value interface{}) *Query {
+	return q.arrayCondition(column, func(a dialect.Arrayer, column, placeholder string) string {
+		return a.ArrayAny(column, placeholder)
+	}, value)
+}
+
+// Add a condition rendered by the given array operator of the dialect, which
+// must be an Arrayer.
+func (q *Query) arrayCondition(column string, operator func(dialect.Arrayer, string, string) string, arg interface{}) *Query {
+	arrayer, ok := q.db.dialect.(dialect.Arrayer)
+	if !ok {
+		q.fail(&queryError{msg: "dialect " + q.db.dialect.Name() + " has no array operators"})
+		return q
+	}
+	if err := q.checkColumn(column); err != nil {
+		q.fail(err)
+		return q
+	}
+	return q.Where(operator(arrayer, q.db.dialect.Quote(column), "?"), arg)
+}
+
+// OrderBy orders the rows of the query by the given SQL expression, such as
+// `"created_at" DESC`. Successive calls add further orderings.
+func (q *Query) OrderBy(expression string) *Query {
+	q.order = append(q.order, expression)
+	return q
+}
+
+// Limit restricts the query to at most the given number of rows.
+func (q *Query) Limit(limit int) *Query {
+	q.limit = limit
+	return q
+}
+
+// Offset skips the given number of rows of the query. MySQL and SQLite only
+// accept an offset along with a Limit.
+func (q *Query) Offset(offset int) *Query {
+	q.offset = offset
+	return q
+}
+
+// Copy the query, so that the copy can be refined without changing it.
+func (q *Query) clone() *Query {
+	c := *q
+	c.conditions = append([]condition(nil), q.conditions...)
+	c.order = append([]string(nil), q.order...)
+	c.columns = append([]string(nil), q.columns...)
+	if q.columns == nil {
+		c.columns = nil
+	}
+	return &c
+}
+
+// Record the first error of the query.
+func (q *Query) fail(err error) {
+	if q.err == nil {
+		q.err = err
+	}
+}
+
+// Check that the table of the query has the given column.
+func (q *Query) checkColumn(column string) error {
+	if q.table == nil {
+		return nil
+	}
+	_, err := q.table.ColumnFor(column)
+	return err
+}
+
+// SQL renders the statement of the query and its arguments.
+func (q *Query) SQL() (string, []interface{}, error) {
+	if q.err != nil {
+		return "", nil, q.err
+	}
+	return q.render(q.selectList(), true)
+}
+
+// Render the select list of the query, which is every column of its table
+// unless Select was called.
+func (q *Query) selectList() string {
+	d := q.db.dialect
+	var buffer bytes.Buffer
+	if q.distinct {
+		buffer.WriteString("DISTINCT ")
+	}
+	columns := q.columns
+	if columns == nil {
+		for _, column := range q.table.Columns() {
+			columns = append(columns, column.Name())
+		}
+	}
+	for i, column := range columns {
+		if i > 0 {
+			buffer.WriteString(", ")
+		}
+		buffer.WriteString(d.Quote(column))
+	}
+	return buffer.String()
+}
+
+// Render the statement of the query selecting the given list, along with
+// its arguments, applying the default scopes of its table and restricting it
+// to the tenant of its DB. The ordering,
+// limit and offset of the query are only rendered if full is set, since they
+// don't apply to aggregates.
+func (q *Query) render(list string, full bool) (string, []interface{}, error) {
+	q = q.withDefaultScopes().withTenant()
+	if q.err != nil {
+		return "", nil, q.err
+	}
+	d := q.db.dialect
+	var buffer bytes.Buffer
+	buffer.WriteString("SELECT ")
+	buffer.WriteString(list)
+	buffer.WriteString(" FROM ")
+	buffer.WriteString(q.db.tableName(q.table))
+
+	var args []interface{}
+	for i, c := range q.conditions {
+		if i == 0 {
+			buffer.WriteString(" WHERE ")
+		} else {
+			buffer.WriteString(" AND ")
+		}
+		buffer.WriteString("(")
+		buffer.WriteString(bindPlaceholders(d, c.expression, len(args)))
+		buffer.WriteString(")")
+		args = append(args, c.args...)
+	}
+	if !full {
+		return buffer.String(), args, nil
+	}
+	for i, expression := range q.order {
+		if i == 0 {
+			buffer.WriteString(" ORDER BY ")
+		} else {
+			buffer.WriteString(", ")
+		}
+		buffer.WriteString(expression)
+	}
+	if q.limit > 0 {
+		buffer.WriteString(" LIMIT ")
+		buffer.WriteString(strconv.Itoa(q.limit))
+	}
+	if q.offset > 0 {
+		buffer.WriteString(" OFFSET ")
+		buffer.WriteString(strconv.Itoa(q.offset))
+	}
+	return buffer.String(), args, nil
+}
+
+// Cursor runs the query, returning a Cursor which reads its rows one at a
+// time. The rows are never held in memory together, so a cursor can walk
+// over results too large to load at once. The cursor must be closed.
+func (q *Query) Cursor() (*Cursor, error) {
+	query, args, err := q.SQL()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := q.q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	names, err := rows.Columns()
+	if err != nil {
+		rows.Close()
+		return nil, err
+	}
+	scanner, err := newRowScanner(q.table, names)
+	if err != nil {
+		rows.Close()
+		return nil, err
+	}
+	return &Cursor{rows: rows, table: q.table, scanner: scanner}, nil
+}
+
+// All runs the query and appends its rows to the slice dest points to, which
+// must be a slice of structs or of pointers to structs of the type of the
+// table of the query.
+func (q *Query) All(dest interface{}) error {
+	slice := reflect.ValueOf(dest)
+	if slice.Kind() != reflect.Ptr || slice.Elem().Kind() != reflect.Slice {
+		return &destinationError{
+			badType: reflect.TypeOf(dest),
+			msg:     "destination must be a pointer to a slice"}
+	}
+	slice = slice.Elem()
+	elemType := slice.Type().Elem()
+	if q.table != nil && getConcreteType(elemType) != q.table.Type() {
+		return &destinationError{
+			badType: elemType,
+			msg:     "destination is not of the type of table " + q.table.Name()}
+	}
+
+	cursor, err := q.Cursor()
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+	return scanAll(cursor.rows, cursor.scanner, slice)
+}
+
+// Rewrite the ? placeholders of the given expression to the placeholders of
+// the given dialect, numbering them after the given count of preceding
+// arguments. Question marks within quoted strings and identifiers are kept.
+func bindPlaceholders(d dialect.Dialect, expression string, preceding int) string {
+	var buffer bytes.Buffer
+	var quote rune
+	n := preceding
+	for _, r := range expression {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '?':
+			n++
+			buffer.WriteString(d.Placeholder(n))
+			continue
+		}
+		buffer.WriteRune(r)
+	}
+	return buffer.String()
+}
+
+// Cursor reads the rows of a Query one at a time, as sql.Rows does, scanning
+// each into a struct of the type of the table of the query. The matching of
+// result columns to fields is done once and reused for every row.
+type Cursor struct {
+	rows    *sql.Rows
+	table   schema.Table
+	scanner *rowScanner
+}
+
+// Next advances the cursor to the next row, returning false once there are
+// no more rows or an error occurred, which Err returns.
+func (c *Cursor) Next() bool {
+	return c.rows.Next()
+}
+
+// Scan reads the current row into dest, which must be a pointer to a struct
+// of the type of the table of the query, as the package level Scan does.
+func (c *Cursor) Scan(dest interface{}) error {
+	value, err := destinationValue(c.table, dest)
+	if err != nil {
+		return err
+	}
+	return c.scanner.scan(c.rows, value)
+}
+
+// Err returns the error, if any, encountered while iterating.
+func (c *Cursor) Err() error {
+	return c.rows.Err()
+}
+
+// Close closes the cursor, releasing its connection. It is safe to close a
+// cursor more than once.
+func (c *Cursor) Close() error {
+	return c.rows.Close()
+}
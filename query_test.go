@@ -0,0 +1,126 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icebox
+
+import (
+	"database/sql/driver"
+	"github.com/jadengis/icebox/dialect"
+	"github.com/jadengis/icebox/schema"
+	"reflect"
+	"testing"
+)
+
+// Test that queries render their clauses in order with numbered placeholders.
+func TestQuerySQL(t *testing.T) {
+	s, err := schema.NewSchema("test_schema", new(fakePost))
+	if err != nil {
+		t.Fatalf("schema could not be generated: error = %s", err.Error())
+	}
+	db := openFake(t, "postgres", s)
+	defer db.Close()
+
+	query, args, err := db.From(new(fakePost)).
+		Where(`"id" > ?`, 10).
+		Contains("tags", []string{"go"}).
+		Any("tags", "sql").
+		OrderBy(`"id" DESC`).
+		Limit(20).
+		Offset(40).
+		SQL()
+	if err != nil {
+		t.Fatalf("query could not be built: error = %s", err.Error())
+	}
+	expected := `SELECT "id", "tags" FROM "fake_posts" WHERE ("id" > $1) AND ("tags" @> $2) ` +
+		`AND ($3 = ANY("tags")) ORDER BY "id" DESC LIMIT 20 OFFSET 40`
+	if query != expected {
+		t.Errorf("query incorrect: query = %s, expected = %s", query, expected)
+	}
+	if len(args) != 3 || args[0] != 10 || args[2] != "sql" {
+		t.Errorf("query arguments incorrect: args = %v", args)
+	}
+
+	if _, _, err := db.From(new(fakePost)).Contains("missing", []string{}).SQL(); err == nil {
+		t.Errorf("error not raised for an unknown array column")
+	}
+	mysql := openFake(t, "mysql", s)
+	defer mysql.Close()
+	if _, _, err := mysql.From(new(fakePost)).Overlaps("tags", []string{"go"}).SQL(); err == nil {
+		t.Errorf("error not raised for array operators in mysql")
+	}
+}
+
+// Test that only placeholders outside quotes are rewritten.
+func TestBindPlaceholders(t *testing.T) {
+	d, _ := dialect.For("postgres")
+	testCases := []struct {
+		expression string
+		expected   string
+	}{
+		{"a = ? AND b = ?", "a = $3 AND b = $4"},
+		{"a = '?' AND b = ?", "a = '?' AND b = $3"},
+		{`"why?" = ?`, `"why?" = $3`},
+	}
+	for _, tc := range testCases {
+		if bound := bindPlaceholders(d, tc.expression, 2); bound != tc.expected {
+			t.Errorf("placeholders incorrect: bound = %s, expected = %s", bound, tc.expected)
+		}
+	}
+}
+
+// Test that cursors scan rows one at a time into the given destination.
+func TestCursor(t *testing.T) {
+	db := openFakeAccounts(t, "sqlite3")
+	defer db.Close()
+	result := fakeResultSet{
+		columns: []string{"id", "email", "name"},
+		rows: [][]driver.Value{
+			{int64(1), "a@x", "a"},
+			{int64(2), "b@x", "b"},
+		},
+	}
+	fakeQueue(result)
+
+	cursor, err := db.From(new(fakeAccount)).Where(`"name" <> ?`, "").Cursor()
+	if err != nil {
+		t.Fatalf("query failed: error = %s", err.Error())
+	}
+	defer cursor.Close()
+	var ids []int64
+	var account fakeAccount
+	for cursor.Next() {
+		if err := cursor.Scan(&account); err != nil {
+			t.Fatalf("row could not be scanned: error = %s", err.Error())
+		}
+		ids = append(ids, account.Id)
+	}
+	if err := cursor.Err(); err != nil {
+		t.Errorf("cursor failed: error = %s", err.Error())
+	}
+	if !reflect.DeepEqual(ids, []int64{1, 2}) || account.Email != "b@x" {
+		t.Errorf("rows scanned incorrectly: ids = %v, last = %+v", ids, account)
+	}
+	if err := cursor.Scan(new(fakeProfile)); err == nil {
+		t.Errorf("error not raised for a destination of another type")
+	}
+
+	fakeQueue(result)
+	var accounts []*fakeAccount
+	if err := db.From(new(fakeAccount)).All(&accounts); err != nil {
+		t.Fatalf("query failed: error = %s", err.Error())
+	}
+	if len(accounts) != 2 || accounts[1].Name != "b" {
+		t.Errorf("rows collected incorrectly: accounts = %v", accounts)
+	}
+}
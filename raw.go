@@ -0,0 +1,216 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icebox
+
+import (
+	"database/sql"
+	"github.com/jadengis/icebox/schema"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// The types of the structs which are scanned as scalars.
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+)
+
+// The tables generated for ad-hoc structs scanned by raw queries, by type, so
+// that each type is only mapped once.
+var adHocTables sync.Map
+
+// RawQuery is a query written in SQL, whose rows are scanned into structs.
+// The query is run as is, so it must use the placeholders of the dialect, and
+// it is not restricted to the tenant of its DB.
+type RawQuery struct {
+	db     *DB
+	q      queryer
+	query  string
+	args   []interface{}
+	strict bool
+	err    error
+}
+
+// Raw starts a query with the given SQL and arguments, for example
+//
+//	var totals []struct {
+//		Day   time.Time `icebox:"column"`
+//		Total int64     `icebox:"column"`
+//	}
+//	err := db.Raw("SELECT day, SUM(amount) AS total FROM orders GROUP BY day").Scan(&totals)
+func (db *DB) Raw(query string, args ...interface{}) *RawQuery {
+	return &RawQuery{db: db, q: db.DB, query: query, args: args}
+}
+
+// Raw starts a query within the transaction, as DB.Raw does.
+func (tx *Tx) Raw(query string, args ...interface{}) *RawQuery {
+	return &RawQuery{db: tx.db, q: tx.Tx, query: query, args: args}
+}
+
+// Strict makes Scan return an error when a result column matches no field
+// of the destination, rather than discarding the column.
+func (r *RawQuery) Strict() *RawQuery {
+	r.strict = true
+	return r
+}
+
+// Scan runs the query and reads its rows into dest, which must be a pointer
+// to a slice, to which the rows are appended, or a pointer to a single value,
+// into which the first row is read. A query reading a single value returns
+// sql.ErrNoRows if it has no rows.
+//
+// Rows are read into structs, or pointers to structs, by matching result
+// columns to fields by the column names of the table of the type in the
+// schema of the DB. Types which aren't in the schema are mapped from their
+// column tags, as in NewSchema, whatever their other tags. Rows of a single
+// column can also be read into scalars, such as ints, strings, time.Time or
+// sql.Scanners.
+func (r *RawQuery) Scan(dest interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	return scanQuery(r.q, r.db, r.query, r.args, r.strict, dest)
+}
+
+// Run the given query through the given queryer and read its rows into the
+// given destination, as RawQuery.Scan does.
+func scanQuery(q queryer, db *DB, query string, args []interface{}, strict bool, dest interface{}) error {
+	value := reflect.ValueOf(dest)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return &destinationError{
+			badType: reflect.TypeOf(dest),
+			msg:     "destination must be a non-nil pointer"}
+	}
+	value = value.Elem()
+	many := value.Kind() == reflect.Slice && !isScalarType(value.Type())
+	rowType := value.Type()
+	if many {
+		rowType = getConcreteType(rowType.Elem())
+	}
+	var table schema.Table
+	if !isScalarType(rowType) {
+		var err error
+		if table, err = db.rowTable(rowType); err != nil {
+			return err
+		}
+	}
+
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	scanner, err := newScanner(rows, table, strict)
+	if err != nil {
+		return err
+	}
+	if many {
+		return scanAll(rows, scanner, value)
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	if err := scanner.scan(rows, value); err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// Get the table whose columns are matched to the fields of the given struct
+// type, from the schema of the DB if it holds the type.
+func (db *DB) rowTable(rowType reflect.Type) (schema.Table, error) {
+	if rowType.Kind() != reflect.Struct {
+		return nil, &destinationError{
+			badType: rowType,
+			msg:     "rows can only be scanned into structs"}
+	}
+	object := reflect.New(rowType).Interface()
+	if db.schema != nil {
+		if table, err := db.schema.TableFor(object); err == nil {
+			return table, nil
+		}
+	}
+	if table, found := adHocTables.Load(rowType); found {
+		return table.(schema.Table), nil
+	}
+	table, err := schema.NewTable(object)
+	if err != nil {
+		return nil, err
+	}
+	stored, _ := adHocTables.LoadOrStore(rowType, table)
+	return stored.(schema.Table), nil
+}
+
+// Construct the scanner of the given rows into the given table, checking
+// that every result column has a field in strict mode. Without a table, the
+// rows are scanned into scalars, so they must have a single column.
+func newScanner(rows *sql.Rows, table schema.Table, strict bool) (*rowScanner, error) {
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if table == nil {
+		if len(names) != 1 {
+			return nil, &queryError{msg: "only rows of a single column can be scanned into scalars"}
+		}
+		return &rowScanner{targets: make([]interface{}, 1)}, nil
+	}
+	scanner, err := newRowScanner(table, names)
+	if err != nil {
+		return nil, err
+	}
+	if strict {
+		for i, field := range scanner.fields {
+			if field == nil {
+				return nil, &columnError{
+					cause:  &destinationError{badType: table.Type(), msg: "no field for the column"},
+					column: names[i],
+					msg:    "could not scan row"}
+			}
+		}
+	}
+	return scanner, nil
+}
+
+// Returns whether values of the given type are scanned from a single column
+// rather than mapped to a table: every type but structs, and the structs
+// which database/sql scans itself.
+func isScalarType(t reflect.Type) bool {
+	if t.Kind() == reflect.Slice {
+		return t.Elem().Kind() == reflect.Uint8
+	}
+	return t.Kind() != reflect.Struct || t == timeType || reflect.PtrTo(t).Implements(scannerType)
+}
+
+// Append the remaining rows of the given rows to the given slice of structs
+// or pointers to structs.
+func scanAll(rows *sql.Rows, scanner *rowScanner, slice reflect.Value) error {
+	elemType := slice.Type().Elem()
+	for rows.Next() {
+		row := reflect.New(getConcreteType(elemType))
+		if err := scanner.scan(rows, row.Elem()); err != nil {
+			return err
+		}
+		if elemType.Kind() != reflect.Ptr {
+			row = row.Elem()
+		}
+		slice.Set(reflect.Append(slice, row))
+	}
+	return rows.Err()
+}
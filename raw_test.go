@@ -0,0 +1,92 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icebox
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+)
+
+type fakeReport struct {
+	Domain string `icebox:"column"`
+	Count  int64  `icebox:"column:accounts"`
+	Note   string
+}
+
+// Test that raw queries scan into the tables of the schema, and into ad-hoc
+// structs by their column tags.
+func TestRawScan(t *testing.T) {
+	db := openFakeAccounts(t, "sqlite3")
+	defer db.Close()
+	fakeQueue(
+		fakeResultSet{
+			columns: []string{"name", "id"},
+			rows:    [][]driver.Value{{"a", int64(1)}, {"b", int64(2)}},
+		},
+		fakeResultSet{
+			columns: []string{"domain", "accounts", "note"},
+			rows:    [][]driver.Value{{"x", int64(2), "ignored"}},
+		},
+	)
+
+	var accounts []fakeAccount
+	if err := db.Raw("SELECT name, id FROM fake_accounts WHERE id < ?", 3).Scan(&accounts); err != nil {
+		t.Fatalf("raw query failed: error = %s", err.Error())
+	}
+	expected := []fakeAccount{{Id: 1, Name: "a"}, {Id: 2, Name: "b"}}
+	if !reflect.DeepEqual(accounts, expected) {
+		t.Errorf("rows scanned incorrectly: rows = %+v, expected = %+v", accounts, expected)
+	}
+	if statements := fakeStatements(); len(statements) != 1 || statements[0].args[0] != int64(3) {
+		t.Errorf("raw query run incorrectly: statements = %v", statements)
+	}
+
+	var report fakeReport
+	if err := db.Raw("SELECT domain, COUNT(*) AS accounts, '' AS note FROM fake_accounts").Scan(&report); err != nil {
+		t.Fatalf("raw query failed: error = %s", err.Error())
+	}
+	if report != (fakeReport{Domain: "x", Count: 2}) {
+		t.Errorf("ad-hoc row scanned incorrectly: row = %+v", report)
+	}
+}
+
+// Test that strict raw queries reject result columns without a field, and
+// that scanning a single row reports missing rows.
+func TestRawScanErrors(t *testing.T) {
+	db := openFakeAccounts(t, "sqlite3")
+	defer db.Close()
+	result := fakeResultSet{
+		columns: []string{"domain", "note"},
+		rows:    [][]driver.Value{{"x", "y"}},
+	}
+	fakeQueue(result, result, fakeResultSet{columns: []string{"domain"}})
+
+	var reports []*fakeReport
+	if err := db.Raw("SELECT domain, note FROM reports").Scan(&reports); err != nil {
+		t.Errorf("unmatched column rejected outside strict mode: error = %s", err.Error())
+	}
+	if err := db.Raw("SELECT domain, note FROM reports").Strict().Scan(&reports); err == nil {
+		t.Errorf("error not raised for an unmatched column in strict mode")
+	}
+	var report fakeReport
+	if err := db.Raw("SELECT domain FROM reports").Scan(&report); err != sql.ErrNoRows {
+		t.Errorf("missing row not reported: error = %v", err)
+	}
+	if err := db.Raw("SELECT 1").Scan(new(int)); err == nil {
+		t.Errorf("error not raised for a destination which is not a struct")
+	}
+}
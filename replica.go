@@ -0,0 +1,195 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icebox
+
+import (
+	"database/sql"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// The largest weight of a replica in the schedule of reads. Larger
+	// weights are scaled down along with the others, so that the schedule
+	// stays small however large the weights are.
+	maxReplicaWeight int = 1000
+)
+
+// The replicas of a DB, shared by the DBs derived from it.
+//
+// Next counts the reads routed to replicas so far. It comes first so that
+// it is aligned for atomic operations.
+//
+// Pools are the replicas, in the order they were added.
+//
+// Weights are the weights of the pools.
+//
+// Schedule holds the indexes of the pools in the order reads are routed to
+// them, each pool appearing as many times as its reduced weight.
+type replicaSet struct {
+	next     uint64
+	mutex    sync.RWMutex
+	pools    []*replica
+	weights  []int
+	schedule []int
+}
+
+// A replica pool, with the statements prepared on it.
+type replica struct {
+	db    *sql.DB
+	stmts *stmtCache
+}
+
+// AddReplica adds a read replica of the database of the DB, read from by
+// queries built with From and by Model.Select. Reads are spread over the
+// replicas in turn, each receiving a share proportional to its weight, so
+// that equal weights route reads round-robin. A weight below one counts as
+// one, and weights are scaled down to at most 1000, keeping their ratios
+// approximately.
+//
+// Writes, transactions, and raw and named queries, which may write, run on
+// the primary database. Reads which must see a write made just before them
+// should be run on the DB returned by Primary, since replicas may lag behind
+// the primary.
+func (db *DB) AddReplica(pool *sql.DB, weight int) {
+	if weight < 1 {
+		weight = 1
+	}
+	db.stmts.mutex.Lock()
+	size := db.stmts.size
+	db.stmts.mutex.Unlock()
+
+	set := db.replicas
+	set.mutex.Lock()
+	defer set.mutex.Unlock()
+	set.pools = append(set.pools, &replica{db: pool, stmts: newStmtCache(size)})
+	set.weights = append(set.weights, weight)
+	set.schedule = weightedSchedule(set.weights)
+}
+
+// Primary returns a DB sharing the connections of this DB whose reads run
+// on the primary database rather than on a replica, so that they see the
+// writes made just before them, for example
+//
+//	db.Insert(&post)
+//	err := db.Primary().From(new(Post)).Where(`"id" = ?`, post.Id).All(&posts)
+func (db *DB) Primary() *DB {
+	primaryDB := *db
+	primaryDB.primary = true
+	return &primaryDB
+}
+
+// Get the replica the next read of the DB is routed to, or nil if it reads
+// from the primary.
+func (db *DB) replica() *replica {
+	if db.primary {
+		return nil
+	}
+	set := db.replicas
+	set.mutex.RLock()
+	defer set.mutex.RUnlock()
+	if len(set.schedule) == 0 {
+		return nil
+	}
+	next := atomic.AddUint64(&set.next, 1) - 1
+	return set.pools[set.schedule[next%uint64(len(set.schedule))]]
+}
+
+// Get the queryer running reads against the next replica, through its cache
+// of prepared statements, or against the primary if there is no replica.
+func (db *DB) readQueryer() queryer {
+	if pool := db.replica(); pool != nil {
+		return &stmtQueryer{cache: pool.stmts, db: pool.db}
+	}
+	return db.queryer()
+}
+
+// Resize the statement caches of the replicas.
+func (s *replicaSet) resize(size int) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, pool := range s.pools {
+		pool.stmts.resize(size)
+	}
+}
+
+// Close the statements prepared on the replicas, and then the replicas,
+// returning the first error.
+func (s *replicaSet) close() error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var first error
+	for _, pool := range s.pools {
+		pool.stmts.resize(0)
+		if err := pool.db.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Build the schedule of the given weights with smooth weighted round-robin,
+// so that the reads of a heavy replica are interleaved with those of the
+// others rather than run in a burst. The weights are reduced first, so that
+// the schedule is no longer than it needs to be.
+func weightedSchedule(weights []int) []int {
+	weights = reduceWeights(weights)
+	total := 0
+	for _, weight := range weights {
+		total += weight
+	}
+	current := make([]int, len(weights))
+	schedule := make([]int, 0, total)
+	for len(schedule) < total {
+		best := 0
+		for i, weight := range weights {
+			current[i] += weight
+			if current[i] > current[best] {
+				best = i
+			}
+		}
+		current[best] -= total
+		schedule = append(schedule, best)
+	}
+	return schedule
+}
+
+// Reduce the given weights by their greatest common divisor, and scale them
+// down so that none is above maxReplicaWeight, keeping every weight at least
+// one.
+func reduceWeights(weights []int) []int {
+	divisor, largest := 0, 0
+	for _, weight := range weights {
+		a, b := divisor, weight
+		for b != 0 {
+			a, b = b, a%b
+		}
+		divisor = a
+		if weight > largest {
+			largest = weight
+		}
+	}
+	reduced := make([]int, len(weights))
+	for i, weight := range weights {
+		reduced[i] = weight / divisor
+		if largest/divisor > maxReplicaWeight {
+			reduced[i] = int(float64(weight)*float64(maxReplicaWeight)/float64(largest) + 0.5)
+		}
+		if reduced[i] < 1 {
+			reduced[i] = 1
+		}
+	}
+	return reduced
+}
@@ -0,0 +1,144 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icebox
+
+import (
+	"database/sql"
+	"github.com/jadengis/icebox/internal/fakedb"
+	"reflect"
+	"testing"
+)
+
+// Add a fake replica with the given data source name to the given DB.
+func addFakeReplica(t testing.TB, db *DB, name string, weight int) {
+	fakeDatabase.Share(name)
+	replica, err := sql.Open(fakedb.DriverName, name)
+	if err != nil {
+		t.Fatalf("fake replica could not be opened: error = %s", err.Error())
+	}
+	db.AddReplica(replica, weight)
+}
+
+// Test that reads built with From are spread over the replicas, while
+// writes, transactions, raw queries and reads forced to the primary run on
+// the primary.
+func TestReplicaRouting(t *testing.T) {
+	db := openFakeAccounts(t, "sqlite3")
+	defer db.Close()
+	addFakeReplica(t, db, "replica_a", 1)
+	addFakeReplica(t, db, "replica_b", 1)
+	fakeQueue()
+
+	var accounts []fakeAccount
+	for i := 0; i < 3; i++ {
+		if err := db.From(new(fakeAccount)).All(&accounts); err != nil {
+			t.Fatalf("query failed: error = %s", err.Error())
+		}
+	}
+	if err := db.Insert(&fakeAccount{Email: "a@x"}); err != nil {
+		t.Fatalf("insert failed: error = %s", err.Error())
+	}
+	if err := db.Primary().From(new(fakeAccount)).All(&accounts); err != nil {
+		t.Fatalf("query on the primary failed: error = %s", err.Error())
+	}
+	if err := db.Raw(`SELECT * FROM "fake_accounts"`).Scan(&accounts); err != nil {
+		t.Fatalf("raw query failed: error = %s", err.Error())
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("transaction could not be begun: error = %s", err.Error())
+	}
+	if err := tx.From(new(fakeAccount)).All(&accounts); err != nil {
+		t.Fatalf("query in transaction failed: error = %s", err.Error())
+	}
+	tx.Commit()
+
+	var sources []string
+	for _, statement := range fakeStatements() {
+		sources = append(sources, statement.source)
+	}
+	expected := []string{"replica_a", "replica_b", "replica_a", "", "", "", ""}
+	if !reflect.DeepEqual(sources, expected) {
+		t.Errorf("statements routed incorrectly: sources = %q, expected = %q", sources, expected)
+	}
+}
+
+// Test that the Select of a Model reads from a replica, unless the DB reads
+// from the primary, and that SelectTx reads within its transaction.
+func TestReplicaSelecter(t *testing.T) {
+	db := openFakeAccounts(t, "sqlite3")
+	defer db.Close()
+	addFakeReplica(t, db, "replica_a", 1)
+	fakeQueue()
+
+	var selecter Selecter = new(Model)
+	if err := selecter.Select(db); err != nil {
+		t.Fatalf("select failed: error = %s", err.Error())
+	}
+	if err := selecter.Select(db.Primary()); err != nil {
+		t.Fatalf("select on the primary failed: error = %s", err.Error())
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("transaction could not be begun: error = %s", err.Error())
+	}
+	if err := selecter.SelectTx(tx); err != nil {
+		t.Fatalf("select in transaction failed: error = %s", err.Error())
+	}
+	tx.Commit()
+
+	var sources []string
+	for _, statement := range fakeStatements() {
+		sources = append(sources, statement.source)
+	}
+	expected := []string{"replica_a", "", ""}
+	if !reflect.DeepEqual(sources, expected) {
+		t.Errorf("selects routed incorrectly: sources = %q, expected = %q", sources, expected)
+	}
+}
+
+// Test that replicas receive reads in proportion to their weights,
+// interleaved with each other.
+func TestWeightedSchedule(t *testing.T) {
+	testCases := []struct {
+		weights  []int
+		expected []int
+	}{
+		{[]int{1}, []int{0}},
+		{[]int{1, 1, 1}, []int{0, 1, 2}},
+		{[]int{3, 1}, []int{0, 0, 1, 0}},
+		{[]int{1, 2}, []int{1, 0, 1}},
+		{[]int{300, 100}, []int{0, 0, 1, 0}},
+		{[]int{1 << 40, 1 << 20}, nil},
+		{[]int{int(^uint(0) >> 1), 1}, nil},
+	}
+	for _, tc := range testCases {
+		schedule := weightedSchedule(tc.weights)
+		if tc.expected == nil {
+			counts := make([]int, len(tc.weights))
+			for _, pool := range schedule {
+				counts[pool]++
+			}
+			if len(schedule) > len(tc.weights)*maxReplicaWeight || counts[0] != maxReplicaWeight || counts[1] != 1 {
+				t.Errorf("large weights reduced incorrectly: weights = %v, counts = %v", tc.weights, counts)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(schedule, tc.expected) {
+			t.Errorf("schedule incorrect: weights = %v, schedule = %v, expected = %v",
+				tc.weights, schedule, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icebox
+
+import (
+	"database/sql"
+	"github.com/jadengis/icebox/schema"
+	"reflect"
+)
+
+// Scan reads the current row of the given rows into dest, which must be a
+// pointer to a struct of the type of the given table. The columns of the
+// result are matched to the columns of the table by name, and result columns
+// the table doesn't know are discarded.
+//
+// Columns of embedded structs are written to the nested fields they were
+// generated from, and JSON and array columns are decoded into their fields.
+// NULLs are written to pointer fields as nil, and a nil pointer to an
+// embedded struct is only allocated once one of its columns is not NULL.
+func Scan(rows *sql.Rows, table schema.Table, dest interface{}) error {
+	value, err := destinationValue(table, dest)
+	if err != nil {
+		return err
+	}
+	names, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	scanner, err := newRowScanner(table, names)
+	if err != nil {
+		return err
+	}
+	return scanner.scan(rows, value)
+}
+
+// Reads rows with a given set of result columns into structs of the type of
+// a table, following the scan plan of the table for those columns. A scanner
+// is reused for every row of a result.
+//
+// Fields are the fields of the result columns, nil for the columns which are
+// discarded.
+//
+// Deferred are the columns behind pointers to embedded structs, by result
+// column.
+//
+// Targets are the destinations passed to Rows.Scan, reused between rows.
+type rowScanner struct {
+	fields   []*fieldPlan
+	deferred []*deferredColumn
+	targets  []interface{}
+}
+
+// Construct the scanner of rows with the given result columns into structs
+// of the type of the given table.
+func newRowScanner(table schema.Table, names []string) (*rowScanner, error) {
+	fields, err := planFor(table).scanPlan(names)
+	if err != nil {
+		return nil, err
+	}
+	s := &rowScanner{
+		fields:   fields,
+		deferred: make([]*deferredColumn, len(names)),
+		targets:  make([]interface{}, len(names)),
+	}
+	discard := new(interface{})
+	for i, field := range fields {
+		switch {
+		case field == nil:
+			s.targets[i] = discard
+		case field.optional:
+			s.deferred[i] = newDeferredColumn(field.column, field.fieldType)
+			s.targets[i] = s.deferred[i].target
+		}
+	}
+	return s, nil
+}
+
+// Read the current row of the given rows into the given struct value, or
+// into the given scalar value for a scanner without fields.
+func (s *rowScanner) scan(rows *sql.Rows, value reflect.Value) error {
+	if s.fields == nil {
+		return rows.Scan(value.Addr().Interface())
+	}
+	for i, field := range s.fields {
+		if field != nil && !field.optional {
+			s.targets[i] = columnTarget(field.column, fieldByIndex(value, field.index))
+		}
+	}
+	if err := rows.Scan(s.targets...); err != nil {
+		return err
+	}
+	for _, d := range s.deferred {
+		if d != nil {
+			d.apply(value)
+		}
+	}
+	return nil
+}
+
+// A column behind a pointer to an embedded struct. The column is scanned into
+// a holder of its own, and written to the struct afterwards, so that the
+// struct isn't allocated for NULLs.
+//
+// Index is the index of the field of the column.
+//
+// Holder is a pointer to the value scanned.
+//
+// Target is the destination passed to Rows.Scan.
+//
+// Valid reports whether the value scanned was not NULL.
+type deferredColumn struct {
+	index  []int
+	holder reflect.Value
+	target interface{}
+	valid  func() bool
+}
+
+// Construct the deferred column for the given column with a field of the
+// given type. Plain columns are scanned into a pointer to a pointer, which
+// database/sql sets to nil for NULL, while the scanners of JSON and array
+// columns are wrapped to record NULLs.
+func newDeferredColumn(column schema.Column, fieldType reflect.Type) *deferredColumn {
+	d := &deferredColumn{index: column.FieldIndex(), holder: reflect.New(fieldType)}
+	target := columnTarget(column, d.holder.Elem())
+	if scanner, ok := target.(sql.Scanner); ok {
+		recorder := &nullRecorder{Scanner: scanner}
+		d.target = recorder
+		d.valid = func() bool { return recorder.valid }
+		return d
+	}
+	pointer := reflect.New(reflect.PtrTo(fieldType))
+	d.target = pointer.Interface()
+	d.valid = func() bool {
+		if pointer.Elem().IsNil() {
+			return false
+		}
+		d.holder.Elem().Set(pointer.Elem().Elem())
+		return true
+	}
+	return d
+}
+
+// Write the scanned value to the given struct value, allocating the embedded
+// structs on its path if it isn't NULL. A NULL resets a field which can be
+// reached without allocating.
+func (d *deferredColumn) apply(value reflect.Value) {
+	if d.valid() {
+		fieldByIndex(value, d.index).Set(d.holder.Elem())
+		return
+	}
+	if field, ok := fieldValue(value, d.index); ok {
+		field.Set(reflect.Zero(field.Type()))
+	}
+}
+
+// A sql.Scanner recording whether the value it scanned was NULL.
+type nullRecorder struct {
+	sql.Scanner
+	valid bool
+}
+
+// Scan the given value, recording whether it is NULL.
+func (r *nullRecorder) Scan(src interface{}) error {
+	r.valid = src != nil
+	return r.Scanner.Scan(src)
+}
+
+// Get the type of the field of the given struct type at the given index,
+// along with whether the path to it goes through a pointer, and whether every
+// field on the path is exported so that the field can be set.
+func fieldTypeByIndex(structType reflect.Type, index []int) (reflect.Type, bool, bool) {
+	optional, exported := false, true
+	for i, x := range index {
+		if i > 0 && structType.Kind() == reflect.Ptr {
+			optional = true
+			structType = structType.Elem()
+		}
+		field := structType.Field(x)
+		exported = exported && field.PkgPath == ""
+		structType = field.Type
+	}
+	return structType, optional, exported
+}
+
+// Get the struct value the given destination points to, checking that it is
+// of the type of the given table.
+func destinationValue(table schema.Table, dest interface{}) (reflect.Value, error) {
+	value := reflect.ValueOf(dest)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return reflect.Value{}, &destinationError{
+			badType: reflect.TypeOf(dest),
+			msg:     "destination must be a non-nil pointer"}
+	}
+	value = value.Elem()
+	if table.Type() == nil || value.Type() != table.Type() {
+		return reflect.Value{}, &destinationError{
+			badType: value.Type(),
+			msg:     "destination is not of the type of table " + table.Name()}
+	}
+	return value, nil
+}
+
+// Get the field of the given struct value at the given index, allocating any
+// nil struct pointers along the path.
+func fieldByIndex(value reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				value.Set(reflect.New(value.Type().Elem()))
+			}
+			value = value.Elem()
+		}
+		value = value.Field(x)
+	}
+	return value
+}
@@ -0,0 +1,167 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icebox
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"github.com/jadengis/icebox/schema"
+	"reflect"
+	"testing"
+)
+
+type fakeAddress struct {
+	Street string `icebox:"column"`
+	City   string `icebox:"column"`
+}
+
+type fakeCustomer struct {
+	Id       int64        `icebox:"column,primaryKey"`
+	Home     fakeAddress  `icebox:"embedded"`
+	Billing  *fakeAddress `icebox:"inline:bill_"`
+	Nickname string
+}
+
+// Generate the table for the fake customer for use in the tests.
+func fakeCustomerTable(t *testing.T) schema.Table {
+	s, err := schema.NewSchema("test_schema", new(fakeCustomer))
+	if err != nil {
+		t.Fatalf("schema could not be generated: error = %s", err.Error())
+	}
+	table, err := s.TableFor(new(fakeCustomer))
+	if err != nil {
+		t.Fatalf("table could not be found: error = %s", err.Error())
+	}
+	return table
+}
+
+// Test that embedded structs are flattened into prefixed columns.
+func TestEmbeddedColumns(t *testing.T) {
+	var names []string
+	for _, column := range fakeCustomerTable(t).Columns() {
+		names = append(names, column.Name())
+	}
+	expected := []string{"id", "home_street", "home_city", "bill_street", "bill_city"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("columns incorrect: names = %v, expected = %v", names, expected)
+	}
+}
+
+// Test that rows are scanned into nested structs, and unknown columns are
+// discarded.
+func TestScan(t *testing.T) {
+	db := openFake(t, "sqlite3", nil)
+	defer db.Close()
+	fakeQueue(fakeResultSet{
+		columns: []string{"bill_city", "id", "home_street", "home_city", "rank"},
+		rows:    [][]driver.Value{{"Paris", int64(7), "1 Main St", "Springfield", int64(3)}},
+	})
+
+	rows, err := db.Query("SELECT * FROM fake_customers")
+	if err != nil {
+		t.Fatalf("query failed: error = %s", err.Error())
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		t.Fatalf("no row returned")
+	}
+	var customer fakeCustomer
+	if err := Scan(rows, fakeCustomerTable(t), &customer); err != nil {
+		t.Fatalf("row could not be scanned: error = %s", err.Error())
+	}
+	expected := fakeCustomer{
+		Id:      7,
+		Home:    fakeAddress{Street: "1 Main St", City: "Springfield"},
+		Billing: &fakeAddress{City: "Paris"},
+	}
+	if !reflect.DeepEqual(customer, expected) {
+		t.Errorf("scanned row incorrect: row = %+v, expected = %+v", customer, expected)
+	}
+}
+
+// Test that rows can only be scanned into the type of the table.
+func TestScanDestination(t *testing.T) {
+	db := openFake(t, "sqlite3", nil)
+	defer db.Close()
+	fakeQueue(fakeResultSet{columns: []string{"id"}, rows: [][]driver.Value{{int64(1)}}})
+
+	rows, err := db.Query("SELECT id FROM fake_customers")
+	if err != nil {
+		t.Fatalf("query failed: error = %s", err.Error())
+	}
+	defer rows.Close()
+	rows.Next()
+	var address fakeAddress
+	if err := Scan(rows, fakeCustomerTable(t), &address); err == nil {
+		t.Errorf("error not raised for a destination of the wrong type")
+	}
+	if err := Scan(rows, fakeCustomerTable(t), fakeCustomer{}); err == nil {
+		t.Errorf("error not raised for a destination which isn't a pointer")
+	}
+}
+
+type fakeContact struct {
+	Id       int64          `icebox:"column,primaryKey"`
+	Phone    *string        `icebox:"column"`
+	Nickname sql.NullString `icebox:"column"`
+	Office   *fakeAddress   `icebox:"embedded"`
+}
+
+// Test that NULLs are scanned into nil pointers, and that embedded structs
+// are only allocated for values.
+func TestScanNulls(t *testing.T) {
+	s, err := schema.NewSchema("test_schema", new(fakeContact))
+	if err != nil {
+		t.Fatalf("schema could not be generated: error = %s", err.Error())
+	}
+	table, _ := s.TableFor(new(fakeContact))
+	db := openFake(t, "sqlite3", s)
+	defer db.Close()
+	fakeQueue(fakeResultSet{
+		columns: []string{"id", "phone", "nickname", "office_street", "office_city"},
+		rows:    [][]driver.Value{{int64(1), nil, nil, nil, nil}},
+	})
+
+	rows, err := db.Query("SELECT * FROM fake_contacts")
+	if err != nil {
+		t.Fatalf("query failed: error = %s", err.Error())
+	}
+	defer rows.Close()
+	rows.Next()
+	phone := "stale"
+	contact := fakeContact{Phone: &phone, Office: &fakeAddress{City: "stale"}}
+	if err := Scan(rows, table, &contact); err != nil {
+		t.Fatalf("row could not be scanned: error = %s", err.Error())
+	}
+	expected := fakeContact{Id: 1, Office: &fakeAddress{}}
+	if !reflect.DeepEqual(contact, expected) {
+		t.Errorf("scanned row incorrect: row = %+v, expected = %+v", contact, expected)
+	}
+
+	contact = fakeContact{}
+	fakeQueue(fakeResultSet{
+		columns: []string{"id", "office_street", "office_city"},
+		rows:    [][]driver.Value{{int64(2), nil, nil}},
+	})
+	rows, _ = db.Query("SELECT * FROM fake_contacts")
+	defer rows.Close()
+	rows.Next()
+	if err := Scan(rows, table, &contact); err != nil {
+		t.Fatalf("row could not be scanned: error = %s", err.Error())
+	}
+	if contact.Office != nil {
+		t.Errorf("embedded struct allocated for NULLs: office = %+v", contact.Office)
+	}
+}
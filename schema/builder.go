@@ -0,0 +1,342 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"github.com/jadengis/icebox/types"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// SchemaBuilder builds a Schema programmatically rather than from struct
+// tags, so that types which cannot be tagged, such as generated protobuf
+// messages or vendored structs, can still be mapped. For example
+//
+//	s, err := schema.Builder().
+//		Table("users").For(new(vendor.User)).
+//		Column("id", types.Int).PrimaryKey().
+//		Column("email", types.VarChar, schema.Size(320)).NotNull().Unique().
+//		Build("app")
+//
+// Tagged types can be mixed in with Objects. Errors are collected as the
+// schema is described and returned by Build.
+type SchemaBuilder struct {
+	naming  NamingStrategy
+	objects []interface{}
+	tables  []*TableBuilder
+}
+
+// TableBuilder describes a table of a SchemaBuilder. Its methods return the
+// builder so calls can be chained, and the methods of the SchemaBuilder can
+// be called on it to move on to the next table.
+type TableBuilder struct {
+	*SchemaBuilder
+	name    string
+	object  interface{}
+	columns []*ColumnBuilder
+}
+
+// ColumnBuilder describes a column of a TableBuilder. Its methods add
+// constraints and metadata to the column, and the methods of the
+// TableBuilder can be called on it to move on to the next column.
+type ColumnBuilder struct {
+	*TableBuilder
+	name        string
+	sqlType     types.SQLType
+	fieldName   string
+	constraints []*constraintImpl
+	metadata    map[string]string
+}
+
+// TypeOption sets an argument of the SQLType of a column added with
+// TableBuilder.Column.
+type TypeOption func(*typeOptions)
+
+// The arguments of a SQLType set by TypeOptions.
+type typeOptions struct {
+	size   string
+	values []string
+	elem   types.IceboxType
+}
+
+// Size sets the size of the SQLType, for example the length of a VarChar.
+func Size(size int) TypeOption {
+	return func(options *typeOptions) {
+		options.size = strconv.Itoa(size)
+	}
+}
+
+// Values restricts the SQLType to the given values, as for the fields of Enum
+// types. It is used with the Enum type, or with an integer type.
+func Values(values ...string) TypeOption {
+	return func(options *typeOptions) {
+		options.values = values
+	}
+}
+
+// Of sets the element type of an Array type, which defaults to Text.
+func Of(elem types.IceboxType) TypeOption {
+	return func(options *typeOptions) {
+		options.elem = elem
+	}
+}
+
+// Builder returns an empty SchemaBuilder which names the tables of tagged
+// objects with the DefaultNaming strategy.
+func Builder() *SchemaBuilder {
+	return &SchemaBuilder{naming: DefaultNaming}
+}
+
+// WithNaming sets the naming strategy used for the tables of tagged objects,
+// and to match columns to the fields of the type a table is bound to.
+func (b *SchemaBuilder) WithNaming(naming NamingStrategy) *SchemaBuilder {
+	b.naming = naming
+	return b
+}
+
+// Objects adds tables generated from the struct tags of the given objects,
+// as NewSchema does.
+func (b *SchemaBuilder) Objects(objects ...interface{}) *SchemaBuilder {
+	b.objects = append(b.objects, objects...)
+	return b
+}
+
+// Table starts the description of a table with the given name.
+func (b *SchemaBuilder) Table(name string) *TableBuilder {
+	table := &TableBuilder{SchemaBuilder: b, name: name}
+	b.tables = append(b.tables, table)
+	return table
+}
+
+// For binds the table to the type of the given object, so that the table
+// can be found with Schema.TableFor and its columns mapped to the fields of
+// the type. Columns are bound to the field whose name the naming strategy
+// turns into the column name, unless a field is given with Field.
+func (t *TableBuilder) For(object interface{}) *TableBuilder {
+	t.object = object
+	return t
+}
+
+// Column adds a column with the given name and type to the table.
+func (t *TableBuilder) Column(name string, iceboxType types.IceboxType, options ...TypeOption) *ColumnBuilder {
+	typeOptions := &typeOptions{elem: types.Text}
+	for _, option := range options {
+		option(typeOptions)
+	}
+	sqlType := types.NewSQLType(iceboxType)
+	switch {
+	case iceboxType == types.Array:
+		sqlType = types.NewArraySQLType(types.NewSQLType(typeOptions.elem))
+	case typeOptions.values != nil:
+		sqlType = types.NewSQLTypeWithValues(iceboxType, typeOptions.values)
+	case typeOptions.size != "":
+		sqlType = types.NewSQLTypeWithSize(iceboxType, typeOptions.size)
+	}
+	column := &ColumnBuilder{
+		TableBuilder: t,
+		name:         name,
+		sqlType:      sqlType,
+		metadata:     make(map[string]string),
+	}
+	t.columns = append(t.columns, column)
+	return column
+}
+
+// Field binds the column to the struct field with the given name. Fields of
+// nested structs are named by their path, such as "Address.Street".
+func (c *ColumnBuilder) Field(name string) *ColumnBuilder {
+	c.fieldName = name
+	return c
+}
+
+// NotNull adds a NOT NULL constraint to the column.
+func (c *ColumnBuilder) NotNull() *ColumnBuilder {
+	return c.constrain(NotNull, "")
+}
+
+// Unique adds a UNIQUE constraint to the column.
+func (c *ColumnBuilder) Unique() *ColumnBuilder {
+	return c.constrain(Unique, "")
+}
+
+// PrimaryKey makes the column the primary key of the table.
+func (c *ColumnBuilder) PrimaryKey() *ColumnBuilder {
+	return c.constrain(PrimaryKey, "")
+}
+
+// References adds a foreign key to the given target, such as "users(id)".
+func (c *ColumnBuilder) References(target string) *ColumnBuilder {
+	return c.constrain(ForeignKey, target)
+}
+
+// Check adds a CHECK constraint with the given expression to the column.
+func (c *ColumnBuilder) Check(expression string) *ColumnBuilder {
+	return c.constrain(Check, expression)
+}
+
+// Default sets the default value of the column to the given SQL expression.
+func (c *ColumnBuilder) Default(value string) *ColumnBuilder {
+	return c.constrain(Default, value)
+}
+
+// Index adds an index on the column.
+func (c *ColumnBuilder) Index() *ColumnBuilder {
+	return c.constrain(Index, "")
+}
+
+// Comment sets the comment of the column, as the comment subtag does.
+func (c *ColumnBuilder) Comment(comment string) *ColumnBuilder {
+	return c.Meta(MetadataComment, comment)
+}
+
+// Tenant marks the column as holding the tenant of its rows, as the tenant
+// subtag does.
+func (c *ColumnBuilder) Tenant() *ColumnBuilder {
+	return c.Meta(MetadataTenant, "true")
+}
+
+// Meta sets the metadata of the column with the given key.
+func (c *ColumnBuilder) Meta(key, value string) *ColumnBuilder {
+	c.metadata[key] = value
+	return c
+}
+
+// Add a constraint to the column.
+func (c *ColumnBuilder) constrain(constraintType ConstraintType, details string) *ColumnBuilder {
+	c.constraints = append(c.constraints, newConstraint(constraintType, details))
+	return c
+}
+
+// Build constructs a Schema with the given name from the tables described
+// so far. The builder can be used again afterwards, and every call builds a
+// new Schema.
+//
+// The nullability of a column bound to a field is derived from the type of
+// the field, as for tagged objects.
+//
+// This returns an error if a table or column is described twice, if a table
+// is bound to a type which is not a struct, if a column cannot be bound to a
+// field of its table's type, or if a NOT NULL column is bound to a field
+// which can hold NULL.
+func (b *SchemaBuilder) Build(name string) (Schema, error) {
+	schema, err := NewSchemaWithNaming(name, b.naming, b.objects...)
+	if err != nil {
+		return nil, err
+	}
+	s := schema.(*schemaImpl)
+	for _, tableBuilder := range b.tables {
+		table, err := tableBuilder.build()
+		if err != nil {
+			return nil, &schemaGenError{
+				cause: err,
+				msg:   "error building table " + tableBuilder.name}
+		}
+		if _, found := s.tables[table.name]; found {
+			return nil, &schemaGenError{
+				cause: &duplicateError{kind: "table", name: table.name},
+				msg:   "error building table " + table.name}
+		}
+		if _, found := s.types[table.dataType]; found && table.dataType != nil {
+			return nil, &schemaGenError{
+				cause: &typeError{badType: table.dataType, msg: "type is bound to two tables"},
+				msg:   "error building table " + table.name}
+		}
+		s.addTable(table)
+	}
+	return s, nil
+}
+
+// Construct the table described by this builder.
+func (t *TableBuilder) build() (*tableImpl, error) {
+	var objectType reflect.Type
+	if t.object != nil {
+		objectType = getConcreteObjectType(reflect.TypeOf(t.object))
+		if objectType.Kind() != reflect.Struct {
+			return nil, &typeError{
+				badType: objectType,
+				msg:     "only structs and ptr to struct are supported types"}
+		}
+	}
+
+	table := newTable(objectType, t.name)
+	for _, columnBuilder := range t.columns {
+		if _, found := table.columns[columnBuilder.name]; found {
+			return nil, &duplicateError{kind: "column", name: columnBuilder.name}
+		}
+		column := newColumn(columnBuilder.name, columnBuilder.sqlType)
+		column.bulkAddConstraints(columnBuilder.constraints)
+		for key, value := range columnBuilder.metadata {
+			column.SetMetadata(key, value)
+		}
+		if objectType != nil {
+			field, found := columnBuilder.lookupField(objectType)
+			if !found {
+				return nil, &notFoundError{
+					key: columnBuilder.name,
+					msg: "no field of " + objectType.String() + " for column"}
+			}
+			column.field = field.Index
+			if err := handleNullability(column, field, isOptionalField(objectType, field.Index)); err != nil {
+				return nil, err
+			}
+		}
+		table.addColumn(column)
+	}
+	return table, nil
+}
+
+// Find the struct field of the given type the column is bound to.
+func (c *ColumnBuilder) lookupField(objectType reflect.Type) (reflect.StructField, bool) {
+	if c.fieldName != "" {
+		return lookupFieldPath(objectType, strings.Split(c.fieldName, "."))
+	}
+	for i := 0; i < objectType.NumField(); i++ {
+		field := objectType.Field(i)
+		if c.naming.ColumnName(field.Name) == c.name {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// Returns whether the field at the given index of the given type is reached
+// through a pointer to a nested struct, which may be nil.
+func isOptionalField(objectType reflect.Type, index []int) bool {
+	for _, i := range index[:len(index)-1] {
+		field := objectType.Field(i)
+		if field.Type.Kind() == reflect.Ptr {
+			return true
+		}
+		objectType = field.Type
+	}
+	return false
+}
+
+// Find the struct field at the given path of field names from the given type,
+// following pointers to nested structs.
+func lookupFieldPath(objectType reflect.Type, path []string) (reflect.StructField, bool) {
+	field, found := objectType.FieldByName(path[0])
+	if !found || len(path) == 1 {
+		return field, found
+	}
+	fieldType := getConcreteObjectType(field.Type)
+	if fieldType.Kind() != reflect.Struct {
+		return reflect.StructField{}, false
+	}
+	nested, found := lookupFieldPath(fieldType, path[1:])
+	nested.Index = append(append([]int(nil), field.Index...), nested.Index...)
+	return nested, found
+}
@@ -0,0 +1,115 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"github.com/jadengis/icebox/types"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// A struct standing in for a type which cannot be tagged.
+type fakeVendorUser struct {
+	ID      int64
+	Address string
+	Name    string
+	Nick    *string
+}
+
+// Test that the builder maps an untagged type alongside tagged objects.
+func TestBuilder(t *testing.T) {
+	s, err := Builder().
+		Objects(new(fakeOrderedStruct)).
+		Table("users").For(new(fakeVendorUser)).
+		Column("id", types.BigInt).PrimaryKey().
+		Column("email", types.VarChar, Size(320)).Field("Address").NotNull().Unique().
+		Column("name", types.Text).Comment("Full name").
+		Column("nick", types.Text).
+		Build("test_schema")
+	if err != nil {
+		t.Fatalf("schema could not be built: error = %s", err.Error())
+	}
+	if _, err := s.TableNamed("fake_ordered_structs"); err != nil {
+		t.Errorf("tagged table is missing: error = %s", err.Error())
+	}
+	table, err := s.TableFor(fakeVendorUser{})
+	if err != nil {
+		t.Fatalf("table could not be found for the type: error = %s", err.Error())
+	}
+	if table.Name() != "users" {
+		t.Errorf("table name incorrect: name = %s", table.Name())
+	}
+
+	var names []string
+	for _, column := range table.Columns() {
+		names = append(names, column.Name())
+	}
+	if !reflect.DeepEqual(names, []string{"id", "email", "name", "nick"}) {
+		t.Errorf("columns incorrect: names = %v", names)
+	}
+	email, _ := table.ColumnFor("email")
+	if email.Type().Type() != types.VarChar || email.Type().Size() != "320" {
+		t.Errorf("email type incorrect: type = %s, size = %s",
+			email.Type().Type(), email.Type().Size())
+	}
+	for _, constraintType := range []ConstraintType{NotNull, Unique} {
+		if _, found := email.ConstraintFor(constraintType); !found {
+			t.Errorf("email constraint missing: type = %s", constraintType)
+		}
+	}
+	if field := email.(*columnImpl).field; !reflect.DeepEqual(field, []int{1}) {
+		t.Errorf("email bound to the wrong field: index = %v", field)
+	}
+	name, _ := table.ColumnFor("name")
+	if comment := name.Metadata()[MetadataComment]; comment != "Full name" {
+		t.Errorf("comment incorrect: comment = %s", comment)
+	}
+	nick, _ := table.ColumnFor("nick")
+	if name.Nullable() || !nick.Nullable() {
+		t.Errorf("nullability not derived from the fields: name = %t, nick = %t",
+			name.Nullable(), nick.Nullable())
+	}
+}
+
+// Test that the builder reports badly described schemas.
+func TestBuilderErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		builder *SchemaBuilder
+	}{
+		{"unknown field", Builder().Table("users").For(new(fakeVendorUser)).
+			Column("email", types.Text).SchemaBuilder},
+		{"duplicate column", Builder().Table("users").
+			Column("id", types.Int).Column("id", types.Int).SchemaBuilder},
+		{"duplicate table", Builder().Table("users").Table("users").SchemaBuilder},
+		{"duplicate type", Builder().Table("users").For(new(fakeVendorUser)).
+			Table("people").For(new(fakeVendorUser)).SchemaBuilder},
+		{"not a struct", Builder().Table("users").For(new(int)).SchemaBuilder},
+	}
+
+	for _, tc := range testCases {
+		if _, err := tc.builder.Build("test_schema"); err == nil {
+			t.Errorf("error not raised for %s", tc.name)
+		} else if (tc.name == "duplicate column" || tc.name == "duplicate table") &&
+			!strings.Contains(err.Error(), "is defined twice") {
+			t.Errorf("wrong error raised for a %s: error = %s", tc.name, err.Error())
+		}
+	}
+	if _, err := Builder().Table("users").For(new(fakeVendorUser)).
+		Column("nick", types.Text).NotNull().Build("test_schema"); err == nil {
+		t.Errorf("error not raised for a NOT NULL column bound to a pointer")
+	}
+}
@@ -16,6 +16,7 @@ package schema
 
 import (
 	"github.com/jadengis/icebox/types"
+	"sort"
 )
 
 // Column is a description of a column in a SQL table.
@@ -27,16 +28,67 @@ import (
 //
 // Type returns the SQLType of the column in the schema.
 //
+// Ordinal returns the position of the column in its table, starting from 0.
+// Columns generated from a struct are numbered in field declaration order.
+//
 // Constraints returns the list of contraints on the column, such as
-// PRIMARY KEY or NOT NULL.
+// PRIMARY KEY or NOT NULL, ordered by constraint type.
 //
 // ConstraintFor returns the constraint on the column for the given
 // constraint type, and whether or not it exists.
+//
+// Metadata returns a copy of the free-form metadata attached to the column,
+// for example by the handlers of custom subtags.
+//
+// Nullable returns whether the column accepts NULL, which is the case unless
+// it is NOT NULL or a primary key. Columns generated from a struct are
+// nullable only when their field can hold NULL, such as pointers and the
+// sql.Null types, as the NULL could not be scanned back otherwise. This does
+// not make them NOT NULL in the database, which is left to the notNull
+// subtag.
+//
+// FieldIndex returns the index of the struct field the column is bound to in
+// the type of its table, as used by reflect.Value.FieldByIndex. Columns of
+// embedded structs have an index with more than one element. This is nil for
+// columns without a Go type.
 type Column interface {
 	Name() string
 	Type() types.SQLType
+	Ordinal() int
 	Constraints() []Constraint
 	ConstraintFor(ConstraintType) (Constraint, bool)
+	Metadata() map[string]string
+	Nullable() bool
+	FieldIndex() []int
+}
+
+// Well known column metadata keys.
+//
+// MetadataComment:     A description of the column, rendered as a COMMENT on
+// the column by dialects which support it.
+//
+// MetadataDisplayName: A human readable name for the column.
+//
+// MetadataPII:         Marks a column as holding personally identifiable
+// information.
+//
+// MetadataTenant:      Marks a column as holding the tenant a row belongs to,
+// as the tenant subtag does.
+const (
+	MetadataComment     string = "comment"
+	MetadataDisplayName string = "displayName"
+	MetadataPII         string = "pii"
+	MetadataTenant      string = "tenant"
+)
+
+// ColumnEditor is handed to the TagHandler of a custom subtag while the
+// column is being generated, and lets the handler attach metadata to it.
+//
+// SetMetadata sets the metadata of the column with the given key to the
+// given value.
+type ColumnEditor interface {
+	Column
+	SetMetadata(string, string)
 }
 
 // The default implementation of the Column interface.
@@ -45,12 +97,25 @@ type Column interface {
 //
 // Type is the SQLType of the column in the schema.
 //
+// Ordinal is the position of the column in its table.
+//
 // Constraints is a map of all contraints on the column, such as
 // PRIMARY KEY or NOT NULL, key off by type.
+//
+// Metadata is a map of free-form metadata attached to the column.
+//
+// Field is the index of the struct field the column is bound to, as used by
+// reflect.Value.FieldByIndex. This is nil for columns without a Go type.
+//
+// Required is set when the field of the column cannot hold NULL.
 type columnImpl struct {
 	name        string
 	sqlType     types.SQLType
+	ordinal     int
 	constraints map[ConstraintType]*constraintImpl
+	metadata    map[string]string
+	field       []int
+	required    bool
 }
 
 // Returns the name of the column.
@@ -63,6 +128,11 @@ func (c *columnImpl) Type() types.SQLType {
 	return c.sqlType
 }
 
+// Returns the position of the column in its table.
+func (c *columnImpl) Ordinal() int {
+	return c.ordinal
+}
+
 // Looks-up the given constraint type in the column, and returns it if it exists, along
 // with a bool indicating its existence.
 func (c *columnImpl) ConstraintFor(constraintType ConstraintType) (Constraint, bool) {
@@ -70,21 +140,53 @@ func (c *columnImpl) ConstraintFor(constraintType ConstraintType) (Constraint, b
 	return constraint, found
 }
 
-// Returns a list of all constraints in the constraints map for this column.
+// Returns a list of all constraints in the constraints map for this column,
+// sorted by type.
 func (c *columnImpl) Constraints() []Constraint {
-	constraints := make([]Constraint, len(c.constraints))
+	constraints := make([]Constraint, 0, len(c.constraints))
 	for _, constraint := range c.constraints {
 		constraints = append(constraints, constraint)
 	}
+	sort.Slice(constraints, func(i, j int) bool {
+		return constraints[i].Type() < constraints[j].Type()
+	})
 	return constraints
 }
 
+// Returns a copy of the metadata map of this column.
+func (c *columnImpl) Metadata() map[string]string {
+	metadata := make(map[string]string, len(c.metadata))
+	for key, value := range c.metadata {
+		metadata[key] = value
+	}
+	return metadata
+}
+
+// Returns whether the column has neither a NOT NULL nor a primary key
+// constraint, and its field can hold NULL.
+func (c *columnImpl) Nullable() bool {
+	_, notNull := c.constraints[NotNull]
+	_, primaryKey := c.constraints[PrimaryKey]
+	return !notNull && !primaryKey && !c.required
+}
+
+// Returns the index of the struct field this column is bound to.
+func (c *columnImpl) FieldIndex() []int {
+	return c.field
+}
+
+// Sets the metadata of this column with the given key.
+func (c *columnImpl) SetMetadata(key, value string) {
+	c.metadata[key] = value
+}
+
 // Constructs a new column of the default implementation, with the given name and SQLType.
 func newColumn(name string, sqlType types.SQLType) *columnImpl {
 	return &columnImpl{
 		name:        name,
 		sqlType:     sqlType,
 		constraints: make(map[ConstraintType]*constraintImpl),
+		metadata:    make(map[string]string),
 	}
 }
 
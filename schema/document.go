@@ -0,0 +1,223 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"encoding/json"
+	"github.com/jadengis/icebox/tags"
+	"github.com/jadengis/icebox/types"
+)
+
+// The serialized form of a Schema.
+//
+// Every slice in the document is ordered, with columns in ordinal order, so
+// that serializing the same schema twice produces identical documents which
+// can be diffed. Loaded columns are numbered in document order.
+type schemaDocument struct {
+	Name   string          `json:"name"`
+	Tables []tableDocument `json:"tables"`
+}
+
+// The serialized form of a Table. The type is the name of the Go type which
+// generated the table, and is informational only.
+type tableDocument struct {
+	Name      string             `json:"name"`
+	Type      string             `json:"type,omitempty"`
+	Columns   []columnDocument   `json:"columns"`
+	Relations []relationDocument `json:"relations,omitempty"`
+}
+
+// The serialized form of a Column.
+type columnDocument struct {
+	Name        string               `json:"name"`
+	Type        string               `json:"type"`
+	Size        string               `json:"size,omitempty"`
+	Values      []string             `json:"values,omitempty"`
+	Elem        string               `json:"elem,omitempty"`
+	Constraints []constraintDocument `json:"constraints,omitempty"`
+	Metadata    map[string]string    `json:"metadata,omitempty"`
+}
+
+// The serialized form of a Constraint.
+type constraintDocument struct {
+	Type    string `json:"type"`
+	Details string `json:"details,omitempty"`
+}
+
+// The serialized form of a Relation. The table is the name of the table the
+// relation points to.
+type relationDocument struct {
+	Type    string `json:"type"`
+	Table   string `json:"table"`
+	Details string `json:"details,omitempty"`
+}
+
+// MarshalJSON encodes the given schema as an indented JSON document
+// describing its tables, columns, constraints and relations.
+func MarshalJSON(s Schema) ([]byte, error) {
+	return json.MarshalIndent(newSchemaDocument(s), "", "  ")
+}
+
+// UnmarshalJSON decodes a schema from a JSON document produced by
+// MarshalJSON. The tables of the decoded schema have no Go type, so they can
+// only be looked up by name.
+func UnmarshalJSON(data []byte) (Schema, error) {
+	document := new(schemaDocument)
+	if err := json.Unmarshal(data, document); err != nil {
+		return nil, &documentError{cause: err, msg: "invalid schema document"}
+	}
+	return document.schema()
+}
+
+// Build the document describing the given schema.
+func newSchemaDocument(s Schema) *schemaDocument {
+	tables := s.Tables()
+	document := &schemaDocument{
+		Name:   s.Name(),
+		Tables: make([]tableDocument, 0, len(tables)),
+	}
+	for _, table := range tables {
+		document.Tables = append(document.Tables, newTableDocument(table))
+	}
+	return document
+}
+
+// Build the document describing the given table.
+func newTableDocument(table Table) tableDocument {
+	columns := table.Columns()
+	document := tableDocument{
+		Name:    table.Name(),
+		Columns: make([]columnDocument, 0, len(columns)),
+	}
+	if table.Type() != nil {
+		document.Type = table.Type().String()
+	}
+	for _, column := range columns {
+		document.Columns = append(document.Columns, newColumnDocument(column))
+	}
+	for _, relation := range table.Relations() {
+		document.Relations = append(document.Relations, relationDocument{
+			Type:    relation.Type().String(),
+			Table:   relation.PointsTo().Name(),
+			Details: relation.Details(),
+		})
+	}
+	return document
+}
+
+// Build the document describing the given column.
+func newColumnDocument(column Column) columnDocument {
+	document := columnDocument{
+		Name:     column.Name(),
+		Type:     column.Type().Type().String(),
+		Size:     column.Type().Size(),
+		Values:   column.Type().Values(),
+		Metadata: column.Metadata(),
+	}
+	if elem := column.Type().Elem(); elem != nil {
+		document.Elem = elem.Type().String()
+	}
+	for _, constraint := range column.Constraints() {
+		document.Constraints = append(document.Constraints, constraintDocument{
+			Type:    constraint.Type().String(),
+			Details: constraint.Details(),
+		})
+	}
+	return document
+}
+
+// Build the schema described by this document. Relations are resolved once
+// every table has been built, so that they may point to any table.
+func (d *schemaDocument) schema() (*schemaImpl, error) {
+	schema := newSchema(d.Name)
+	for _, tableDoc := range d.Tables {
+		table := newTable(nil, tableDoc.Name)
+		for _, columnDoc := range tableDoc.Columns {
+			column, err := columnDoc.column()
+			if err != nil {
+				return nil, err
+			}
+			table.addColumn(column)
+		}
+		schema.addTable(table)
+	}
+
+	for _, tableDoc := range d.Tables {
+		table := schema.tables[tableDoc.Name]
+		for _, relationDoc := range tableDoc.Relations {
+			relation, err := relationDoc.relation(schema)
+			if err != nil {
+				return nil, err
+			}
+			table.relations[relation.relationType] = relation
+		}
+	}
+	return schema, nil
+}
+
+// Build the column described by this document.
+func (d *columnDocument) column() (*columnImpl, error) {
+	iceboxType, ok := types.ParseIceboxType(d.Type)
+	if !ok {
+		return nil, &unknownTypeError{
+			typeName: d.Type,
+			msg:      "column type could not be resolved"}
+	}
+	sqlType := types.NewSQLTypeWithSize(iceboxType, d.Size)
+	switch {
+	case len(d.Values) > 0:
+		sqlType = types.NewSQLTypeWithValues(iceboxType, d.Values)
+	case iceboxType == types.Array:
+		elemType, ok := types.ParseIceboxType(d.Elem)
+		if !ok {
+			return nil, &unknownTypeError{
+				typeName: d.Elem,
+				msg:      "array element type could not be resolved"}
+		}
+		sqlType = types.NewArraySQLType(types.NewSQLType(elemType))
+	}
+	column := newColumn(d.Name, sqlType)
+	for _, constraintDoc := range d.Constraints {
+		constraintType, err := getConstraintType(tags.SubTag(constraintDoc.Type))
+		if err != nil {
+			return nil, err
+		}
+		column.constraints[constraintType] = newConstraint(constraintType, constraintDoc.Details)
+	}
+	for key, value := range d.Metadata {
+		column.SetMetadata(key, value)
+	}
+	return column, nil
+}
+
+// Build the relation described by this document, pointing into the given
+// schema.
+func (d *relationDocument) relation(schema *schemaImpl) (*relationImpl, error) {
+	relationType, err := getRelationType(tags.SubTag(d.Type))
+	if err != nil {
+		return nil, err
+	}
+	table, found := schema.tables[d.Table]
+	if !found {
+		return nil, &notFoundError{
+			key: d.Table,
+			msg: "relation points to a missing table"}
+	}
+	return &relationImpl{
+		relationType: relationType,
+		table:        table,
+		details:      d.Details,
+	}, nil
+}
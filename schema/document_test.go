@@ -0,0 +1,146 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"bytes"
+	"github.com/jadengis/icebox/types"
+	"strings"
+	"testing"
+)
+
+type fakeDocumentUser struct {
+	Id    int    `icebox:"column,primaryKey"`
+	Email string `icebox:"column,notNull,unique,default:''"`
+}
+
+// Generate a schema with a relation between its tables for use in the tests.
+func fakeDocumentSchema(t *testing.T) Schema {
+	s, err := NewSchema("test_schema", new(fakeDocumentUser), new(fakeStruct))
+	if err != nil {
+		t.Fatalf("schema could not be generated: error = %s", err.Error())
+	}
+	users := s.(*schemaImpl).tables["fake_document_users"]
+	users.relations[OneToMany] = &relationImpl{
+		relationType: OneToMany,
+		table:        s.(*schemaImpl).tables["fake_structs"],
+		details:      "user_id",
+	}
+	return s
+}
+
+// Verify that the loaded schema matches the generated one.
+func checkLoadedSchema(t *testing.T, loaded Schema) {
+	if loaded.Name() != "test_schema" {
+		t.Errorf("schema name is incorrect: name = %s", loaded.Name())
+	}
+	users, err := loaded.TableNamed("fake_document_users")
+	if err != nil {
+		t.Fatalf("table is missing: error = %s", err.Error())
+	}
+	email, err := users.ColumnFor("email")
+	if err != nil {
+		t.Fatalf("column is missing: error = %s", err.Error())
+	}
+	if email.Type().Type() != types.VarChar || email.Type().Size() != "255" {
+		t.Errorf("column type is incorrect: type = %s, size = %s",
+			email.Type().Type(), email.Type().Size())
+	}
+	if constraint, found := email.ConstraintFor(Default); !found || constraint.Details() != "''" {
+		t.Errorf("column is missing its default constraint")
+	}
+	relation, found := users.RelationFor(OneToMany)
+	if !found {
+		t.Fatalf("relation is missing")
+	}
+	if relation.PointsTo().Name() != "fake_structs" || relation.Details() != "user_id" {
+		t.Errorf("relation is incorrect: table = %s, details = %s",
+			relation.PointsTo().Name(), relation.Details())
+	}
+}
+
+// Test that a schema survives a round trip through JSON, and that encoding is
+// stable.
+func TestJSONRoundTrip(t *testing.T) {
+	s := fakeDocumentSchema(t)
+	document, err := MarshalJSON(s)
+	if err != nil {
+		t.Fatalf("schema could not be encoded: error = %s", err.Error())
+	}
+	loaded, err := UnmarshalJSON(document)
+	if err != nil {
+		t.Fatalf("schema could not be decoded: error = %s", err.Error())
+	}
+	checkLoadedSchema(t, loaded)
+
+	for i := 0; i < 10; i++ {
+		again, err := MarshalJSON(s)
+		if err != nil {
+			t.Fatalf("schema could not be encoded: error = %s", err.Error())
+		}
+		if !bytes.Equal(document, again) {
+			t.Fatalf("encoding is not stable: first = %s, again = %s", document, again)
+		}
+	}
+}
+
+// Test that a schema survives a round trip through YAML.
+func TestYAMLRoundTrip(t *testing.T) {
+	s := fakeDocumentSchema(t)
+	document, err := MarshalYAML(s)
+	if err != nil {
+		t.Fatalf("schema could not be encoded: error = %s", err.Error())
+	}
+	loaded, err := UnmarshalYAML(document)
+	if err != nil {
+		t.Fatalf("schema could not be decoded: error = %s\n%s", err.Error(), document)
+	}
+	checkLoadedSchema(t, loaded)
+}
+
+// Test that hand written YAML in a slightly different style can be loaded,
+// and that bad documents are rejected.
+func TestUnmarshalYAML(t *testing.T) {
+	document := `# a hand written schema
+name: shop
+tables:
+- name: orders
+  columns:
+  - name: id
+    type: bigInt
+    constraints:
+    - type: primaryKey
+  - name: note
+    type: 'text'
+`
+	loaded, err := UnmarshalYAML([]byte(document))
+	if err != nil {
+		t.Fatalf("schema could not be decoded: error = %s", err.Error())
+	}
+	orders, err := loaded.TableNamed("orders")
+	if err != nil {
+		t.Fatalf("table is missing: error = %s", err.Error())
+	}
+	if len(orders.Columns()) != 2 {
+		t.Errorf("table has the wrong number of columns: count = %d", len(orders.Columns()))
+	}
+
+	_, err = UnmarshalYAML([]byte("name: shop\ntables:\n  - name: orders\n    columns:\n      - type: asdf\n"))
+	if err == nil {
+		t.Errorf("error not raised for unknown column type")
+	} else if !strings.Contains(err.Error(), "asdf") {
+		t.Errorf("raised error doesn't mention the type: error = %s", err.Error())
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"github.com/jadengis/icebox/types"
+	"reflect"
+	"strconv"
+)
+
+// Enum is implemented by named string and integer types with a declared set
+// of values, such as
+//
+//	type Status string
+//
+//	func (Status) EnumValues() []string {
+//		return []string{"active", "suspended"}
+//	}
+//
+// Columns of string enums have the Enum type, which dialects render as a
+// native ENUM or as a VarChar with a CHECK constraint. Columns of integer
+// enums keep their integer type, restricted by a CHECK constraint. The values
+// of integer enums are given in decimal.
+//
+// EnumValues returns the values permitted for the type. It is called on the
+// zero value of the type.
+type Enum interface {
+	EnumValues() []string
+}
+
+// The reflect.Type of the Enum interface.
+var enumInterface = reflect.TypeOf((*Enum)(nil)).Elem()
+
+// Get the values of the given field type, along with whether the type is an
+// Enum. Pointers to enums are enums too.
+func enumValues(fieldType reflect.Type) ([]string, bool) {
+	fieldType = getConcreteObjectType(fieldType)
+	switch {
+	case fieldType.Implements(enumInterface):
+		return reflect.Zero(fieldType).Interface().(Enum).EnumValues(), true
+	case reflect.PtrTo(fieldType).Implements(enumInterface):
+		return reflect.New(fieldType).Interface().(Enum).EnumValues(), true
+	default:
+		return nil, false
+	}
+}
+
+// Restrict the given SQLType of a field to the given enum values. This returns
+// an error if the type is neither a string nor an integer type, or if the
+// values of an integer enum are not integers.
+func enumSQLType(sqlType types.SQLType, values []string) (types.SQLType, error) {
+	if len(values) == 0 {
+		return nil, &unknownTypeError{
+			typeName: sqlType.Type().String(),
+			msg:      "enum has no values"}
+	}
+	switch sqlType.Type() {
+	case types.VarChar, types.Enum:
+		return types.NewSQLTypeWithValues(types.Enum, values), nil
+	case types.TinyInt, types.TinyUint, types.SmallInt, types.SmallUint,
+		types.MediumInt, types.MediumUint, types.Int, types.Uint, types.BigInt, types.BigUint:
+		for _, value := range values {
+			if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+				return nil, &unknownTypeError{
+					typeName: value,
+					msg:      "integer enum value is not an integer"}
+			}
+		}
+		return types.NewSQLTypeWithValues(sqlType.Type(), values), nil
+	default:
+		return nil, &unknownTypeError{
+			typeName: sqlType.Type().String(),
+			msg:      "only string and integer types can be enums"}
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"github.com/jadengis/icebox/types"
+	"reflect"
+	"testing"
+)
+
+type fakeStatus string
+
+func (fakeStatus) EnumValues() []string {
+	return []string{"active", "suspended"}
+}
+
+type fakePriority int
+
+func (*fakePriority) EnumValues() []string {
+	return []string{"1", "2", "3"}
+}
+
+type fakeBadPriority int
+
+func (fakeBadPriority) EnumValues() []string {
+	return []string{"low"}
+}
+
+type fakeEnumStruct struct {
+	Status   fakeStatus    `icebox:"column"`
+	Priority *fakePriority `icebox:"column"`
+}
+
+type fakeBadEnumStruct struct {
+	Priority fakeBadPriority `icebox:"column"`
+}
+
+// Test that enum fields are restricted to their values.
+func TestEnumColumns(t *testing.T) {
+	table, err := generateTable(new(fakeEnumStruct), DefaultNaming)
+	if err != nil {
+		t.Fatalf("table could not be generated: error = %s", err.Error())
+	}
+	testCases := []struct {
+		column     string
+		iceboxType types.IceboxType
+		values     []string
+	}{
+		{"status", types.Enum, []string{"active", "suspended"}},
+		{"priority", types.Int, []string{"1", "2", "3"}},
+	}
+	for _, tc := range testCases {
+		column, _ := table.ColumnFor(tc.column)
+		if column.Type().Type() != tc.iceboxType ||
+			!reflect.DeepEqual(column.Type().Values(), tc.values) {
+			t.Errorf("enum type incorrect: type = %s, values = %v, expected = %s %v",
+				column.Type().Type(), column.Type().Values(), tc.iceboxType, tc.values)
+		}
+	}
+
+	if _, err := generateTable(new(fakeBadEnumStruct), DefaultNaming); err == nil {
+		t.Errorf("error not raised for an integer enum with non integer values")
+	}
+}
+
+// Test that enum values survive serialization.
+func TestEnumDocument(t *testing.T) {
+	s, err := NewSchema("test_schema", new(fakeEnumStruct))
+	if err != nil {
+		t.Fatalf("schema could not be generated: error = %s", err.Error())
+	}
+	data, err := MarshalYAML(s)
+	if err != nil {
+		t.Fatalf("schema could not be encoded: error = %s", err.Error())
+	}
+	loaded, err := UnmarshalYAML(data)
+	if err != nil {
+		t.Fatalf("schema could not be decoded: error = %s\n%s", err.Error(), data)
+	}
+	table, _ := loaded.TableNamed("fake_enum_structs")
+	column, _ := table.ColumnFor("status")
+	if !reflect.DeepEqual(column.Type().Values(), []string{"active", "suspended"}) {
+		t.Errorf("enum values lost: values = %v", column.Type().Values())
+	}
+}
@@ -45,6 +45,17 @@ func (e *unknownTypeError) Error() string {
 	return e.msg + " : " + e.typeName
 }
 
+// Error type for a table or column which is defined twice.
+type duplicateError struct {
+	kind string
+	name string
+}
+
+// Produce an error message for a duplicateError.
+func (e *duplicateError) Error() string {
+	return fmt.Sprintf("%s %s is defined twice", e.kind, e.name)
+}
+
 // schemaGenError is a general wrapper for schema generation errors.
 type schemaGenError struct {
 	cause error
@@ -66,3 +77,25 @@ type typeError struct {
 func (e *typeError) Error() string {
 	return fmt.Sprintf("unsupported type %s : %s", e.badType, e.msg)
 }
+
+// Error type for a serialized schema document which cannot be loaded.
+type documentError struct {
+	cause error
+	msg   string
+}
+
+// Error message for a document error.
+func (e *documentError) Error() string {
+	return fmt.Sprintf("%s : %s", e.msg, e.cause.Error())
+}
+
+// Error type for a malformed line of a YAML document.
+type yamlError struct {
+	line int
+	msg  string
+}
+
+// Error message for a yaml error.
+func (e *yamlError) Error() string {
+	return fmt.Sprintf("line %d : %s", e.line, e.msg)
+}
@@ -15,37 +15,56 @@
 package schema
 
 import (
-	"github.com/jadengis/icebox/logger"
 	"github.com/jadengis/icebox/tags"
 	"github.com/jadengis/icebox/types"
 	"reflect"
 )
 
 // NewSchema will construct a database schema given a name for the database
-// and a list of objects that will comprise this schema.
+// and a list of objects that will comprise this schema. Tables and columns
+// are named by the DefaultNaming strategy.
 //
 // If there are an errors during schema generation, this function will return
 // an error.
 func NewSchema(name string, objects ...interface{}) (Schema, error) {
+	return NewSchemaWithNaming(name, DefaultNaming, objects...)
+}
+
+// NewSchemaWithNaming will construct a database schema like NewSchema, but
+// with tables and columns named by the given naming strategy.
+func NewSchemaWithNaming(name string, naming NamingStrategy, objects ...interface{}) (Schema, error) {
 	// Iterate through the all the objects, and build tables for each.
 	schema := newSchema(name)
 	for i := 0; i < len(objects); i++ {
-		table, err := generateTable(objects[i])
+		table, err := generateTable(objects[i], naming)
 		if err != nil {
 			return nil, &schemaGenError{
 				cause: err,
 				msg:   "error generating table"}
 		}
-		schema.tables[table.dataType] = table
+		schema.addTable(table)
 	}
 	return schema, nil
 }
 
+// NewTable generates a Table from the struct tags of the given object, as
+// NewSchema does, but outside of any schema. This maps ad-hoc structs, such as
+// the rows of reporting queries, which are not stored in a table of their own.
+func NewTable(object interface{}) (Table, error) {
+	table, err := generateTable(object, DefaultNaming)
+	if err != nil {
+		return nil, &schemaGenError{
+			cause: err,
+			msg:   "error generating table"}
+	}
+	return table, nil
+}
+
 // Construct and populate the database table corresponding to the given object.
 // This function use the default implementation of the Table interface.
 //
 // If table generation fails, this method will return an error.
-func generateTable(object interface{}) (*tableImpl, error) {
+func generateTable(object interface{}, naming NamingStrategy) (*tableImpl, error) {
 	objectType := reflect.TypeOf(object)
 	if objectType.Kind() == reflect.Ptr {
 		objectType = objectType.Elem()
@@ -56,29 +75,113 @@ func generateTable(object interface{}) (*tableImpl, error) {
 			msg:     "only structs and ptr to struct are supported types"}
 	}
 
-	// Build a Table for objectType by iterating through its struct fields,
-	// parsing out tags, and building the appropraite columns with the appropriate
-	// constraints.
-	name := getTableName(object)
+	name := getTableName(object, naming)
 	table := newTable(objectType, name)
+	if err := generateColumns(table, objectType, nil, "", false, naming); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// Add the columns for the fields of the given struct type to the table.
+//
+// Build columns by iterating through the struct fields, parsing out tags, and
+// building the appropraite columns with the appropriate constraints, then
+// handing the column to the handlers of any custom subtags. Columns are added
+// in field declaration order. Embedded fields are expanded in place into the
+// columns of their own fields, so index is the path to the given struct type
+// from the type of the table, and prefix is prepended to the column names.
+// Optional is set when the path goes through a pointer, so that the struct
+// may be nil.
+func generateColumns(table *tableImpl, objectType reflect.Type, index []int, prefix string, optional bool, naming NamingStrategy) error {
 	for i := 0; i < objectType.NumField(); i++ {
 		field := objectType.Field(i)
+		field.Index = append(append([]int(nil), index...), field.Index...)
 
 		// Check the field for an Icebox tag, and parse subtags if needed.
-		if tag, ok := field.Tag.Lookup(tags.Icebox.String()); ok {
-			parsedTag, err := tags.Parse(tag)
-			if err != nil {
-				logger.Error("could not parse tags", err)
+		tag, ok := field.Tag.Lookup(tags.Icebox.String())
+		if !ok {
+			continue
+		}
+		parsedTag, err := tags.Parse(tag)
+		if err != nil {
+			return &schemaGenError{
+				cause: err,
+				msg:   "could not parse tags on field " + field.Name}
+		}
+		if embeddedPrefix, found := getEmbeddedPrefix(field, parsedTag, naming); found {
+			if err := generateEmbeddedColumns(table, field, parsedTag, prefix+embeddedPrefix, optional, naming); err != nil {
+				return err
+			}
+			continue
+		}
+		column, err := handleColumnTag(field, parsedTag, prefix, naming)
+		if err != nil {
+			return &schemaGenError{
+				cause: err,
+				msg:   "could not map the type of field " + field.Name}
+		}
+		if column != nil {
+			constraints := handleConstraintTags(parsedTag)
+			column.bulkAddConstraints(constraints)
+			if err := handleNullability(column, field, optional); err != nil {
+				return &schemaGenError{
+					cause: err,
+					msg:   "conflicting nullability on field " + field.Name}
+			}
+			if err := handleMetadataTags(column, parsedTag); err != nil {
+				return &schemaGenError{
+					cause: err,
+					msg:   "could not parse metadata on field " + field.Name}
 			}
-			column := handleColumnTag(field, parsedTag)
-			if column != nil {
-				constraints := handleConstraintTags(parsedTag)
-				column.bulkAddConstraints(constraints)
-				table.columns[column.name] = column
+			if err := handleCustomTags(column, field, parsedTag); err != nil {
+				return err
 			}
+			if _, found := table.columns[column.name]; found {
+				return &schemaGenError{
+					cause: &duplicateError{kind: "column", name: column.name},
+					msg:   "could not add column for field " + field.Name}
+			}
+			table.addColumn(column)
 		}
 	}
-	return table, nil
+	return nil
+}
+
+// Get the column prefix of an embedded field from its embedded or inline
+// subtag, along with whether the field is embedded at all. The prefix
+// defaults to the column name of the field followed by an underscore, and
+// may be given as an empty quoted string to use no prefix.
+func getEmbeddedPrefix(field reflect.StructField, parsedTag tags.ParsedTag, naming NamingStrategy) (string, bool) {
+	info, found := parsedTag.GetInfo(tags.Embedded)
+	if !found {
+		info, found = parsedTag.GetInfo(tags.Inline)
+	}
+	if !found {
+		return "", false
+	}
+	if len(info) == 0 {
+		return naming.ColumnName(field.Name) + "_", true
+	}
+	return tags.Unquote(info), true
+}
+
+// Add the columns of the fields of the given embedded struct field to the
+// table, with the given column prefix.
+func generateEmbeddedColumns(table *tableImpl, field reflect.StructField, parsedTag tags.ParsedTag, prefix string, optional bool, naming NamingStrategy) error {
+	if _, found := parsedTag.GetInfo(tags.Column); found {
+		return &schemaGenError{
+			cause: &typeError{badType: field.Type, msg: "embedded fields cannot also be columns"},
+			msg:   "could not embed field " + field.Name}
+	}
+	fieldType := getConcreteObjectType(field.Type)
+	if fieldType.Kind() != reflect.Struct {
+		return &schemaGenError{
+			cause: &typeError{badType: field.Type, msg: "only structs and ptr to struct can be embedded"},
+			msg:   "could not embed field " + field.Name}
+	}
+	optional = optional || field.Type.Kind() == reflect.Ptr
+	return generateColumns(table, fieldType, field.Index, prefix, optional, naming)
 }
 
 // Get the concrete type of a reflect.Type, that is, resolve what the given type
@@ -92,25 +195,47 @@ func getConcreteObjectType(objectType reflect.Type) reflect.Type {
 
 // Process a column tag on struct, and return a corresponding column.
 // This function uses the default Column implementation.
-func handleColumnTag(field reflect.StructField, parsedTag tags.ParsedTag) *columnImpl {
+// The name of the column is prepended with the given prefix, and fields with
+// the json subtag are stored as JSON whatever their type. Fields of Enum
+// types are restricted to their values, and this returns an error if these
+// are invalid, or if the type of the field cannot be mapped.
+func handleColumnTag(field reflect.StructField, parsedTag tags.ParsedTag, prefix string, naming NamingStrategy) (*columnImpl, error) {
 	if info, found := parsedTag.GetInfo(tags.Column); found {
 		delete(parsedTag, tags.Column)
 		if len(info) == 0 {
-			info = sqlNameFromCamelCase(field.Name)
+			info = naming.ColumnName(field.Name)
+		}
+		info = prefix + info
+		if _, found := parsedTag.GetInfo(tags.JSON); found {
+			delete(parsedTag, tags.JSON)
+			column := newColumn(info, types.NewSQLType(types.JSON))
+			column.field = field.Index
+			return column, nil
 		}
 		sqlType, err := mapSQLTypeFromField(field)
 		if err != nil {
-			return nil
+			return nil, err
+		}
+		if values, ok := enumValues(field.Type); ok {
+			if sqlType, err = enumSQLType(sqlType, values); err != nil {
+				return nil, err
+			}
 		}
-		return newColumn(info, sqlType)
+		column := newColumn(info, sqlType)
+		column.field = field.Index
+		return column, nil
 	}
-	return nil
+	return nil, nil
 }
 
 // Map the type of the given struct field to its corresponding SQLType.
 // This returns an error if the struct field type is not supported.
 func mapSQLTypeFromField(field reflect.StructField) (types.SQLType, error) {
-	switch kind := getConcreteObjectType(field.Type).Kind(); kind {
+	fieldType := getConcreteObjectType(field.Type)
+	if sqlType, found := structSQLTypes[fieldType]; found {
+		return sqlType(), nil
+	}
+	switch kind := fieldType.Kind(); kind {
 	case reflect.Bool:
 		return types.NewSQLType(types.Bit), nil
 	case reflect.Int8:
@@ -139,6 +264,8 @@ func mapSQLTypeFromField(field reflect.StructField) (types.SQLType, error) {
 		return types.NewSQLType(types.Double), nil
 	case reflect.String:
 		return types.NewSQLTypeWithSize(types.VarChar, "255"), nil
+	case reflect.Slice:
+		return mapArraySQLType(field)
 	default:
 		return nil, &unknownTypeError{
 			typeName: kind.String(),
@@ -147,6 +274,26 @@ func mapSQLTypeFromField(field reflect.StructField) (types.SQLType, error) {
 	}
 }
 
+// Map a slice field of a scalar type to an Array of the corresponding type.
+// String elements are Text, as array elements have no size. Byte slices and
+// slices of other kinds are not supported.
+func mapArraySQLType(field reflect.StructField) (types.SQLType, error) {
+	elemType := getConcreteObjectType(field.Type).Elem()
+	switch elemType.Kind() {
+	case reflect.Uint8, reflect.Slice, reflect.Map, reflect.Struct, reflect.Ptr, reflect.Interface:
+		return nil, &unknownTypeError{
+			typeName: field.Type.String(),
+			msg:      "unsupported go type"}
+	case reflect.String:
+		return types.NewArraySQLType(types.NewSQLType(types.Text)), nil
+	}
+	elem, err := mapSQLTypeFromField(reflect.StructField{Name: field.Name, Type: elemType})
+	if err != nil {
+		return nil, err
+	}
+	return types.NewArraySQLType(elem), nil
+}
+
 // Construct a slice of constraints from the given parsed tag.
 func handleConstraintTags(parsedTag tags.ParsedTag) []*constraintImpl {
 	var constraints []*constraintImpl
@@ -162,3 +309,27 @@ func handleConstraintTags(parsedTag tags.ParsedTag) []*constraintImpl {
 	}
 	return constraints
 }
+
+// Attach the metadata of the comment, meta and tenant subtags of the given
+// parsed tag to the column.
+func handleMetadataTags(column *columnImpl, parsedTag tags.ParsedTag) error {
+	if info, found := parsedTag.GetInfo(tags.Meta); found {
+		delete(parsedTag, tags.Meta)
+		pairs, err := tags.ParsePairs(info)
+		if err != nil {
+			return err
+		}
+		for key, value := range pairs {
+			column.SetMetadata(key, value)
+		}
+	}
+	if info, found := parsedTag.GetInfo(tags.Comment); found {
+		delete(parsedTag, tags.Comment)
+		column.SetMetadata(MetadataComment, tags.Unquote(info))
+	}
+	if _, found := parsedTag.GetInfo(tags.Tenant); found {
+		delete(parsedTag, tags.Tenant)
+		column.SetMetadata(MetadataTenant, "true")
+	}
+	return nil
+}
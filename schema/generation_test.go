@@ -17,6 +17,7 @@ package schema
 import (
 	"github.com/jadengis/icebox/types"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -42,7 +43,7 @@ func TestGenerateSchema(t *testing.T) {
 
 func TestGenerateTable(t *testing.T) {
 	// generate a table and test it for correctness
-	table, err := generateTable(new(fakeStruct))
+	table, err := generateTable(new(fakeStruct), DefaultNaming)
 
 	// Validate that table properties were appropriately generated.
 	if err != nil {
@@ -90,3 +91,108 @@ func TestGenerateTable(t *testing.T) {
 		}
 	}
 }
+
+type fakeOrderedStruct struct {
+	Zebra   string  `icebox:"column"`
+	Apple   int     `icebox:"column"`
+	Ignored bool    ``
+	Mango   float64 `icebox:"column,notNull,unique,primaryKey"`
+}
+
+// Test that columns are returned in field declaration order, every time.
+func TestColumnOrder(t *testing.T) {
+	table, err := generateTable(new(fakeOrderedStruct), DefaultNaming)
+	if err != nil {
+		t.Fatalf("table failed to generate: error = %s", err.Error())
+	}
+	expected := []string{"zebra", "apple", "mango"}
+	for run := 0; run < 10; run++ {
+		columns := table.Columns()
+		if len(columns) != len(expected) {
+			t.Fatalf("table has the wrong number of columns: count = %d", len(columns))
+		}
+		for i, column := range columns {
+			if column.Name() != expected[i] || column.Ordinal() != i {
+				t.Errorf("column out of order: name = %s, ordinal = %d, expected = %s at %d",
+					column.Name(), column.Ordinal(), expected[i], i)
+			}
+		}
+		constraints := columns[2].Constraints()
+		for i := 1; i < len(constraints); i++ {
+			if constraints[i-1].Type() >= constraints[i].Type() {
+				t.Errorf("constraints out of order: %s before %s",
+					constraints[i-1].Type(), constraints[i].Type())
+			}
+		}
+	}
+}
+
+type fakeBadEmbeddedStruct struct {
+	Count int `icebox:"embedded"`
+}
+
+type fakeCollidingEmbeddedStruct struct {
+	Street string             `icebox:"column:home_street"`
+	Home   fakeEmbeddedStruct `icebox:"embedded"`
+}
+
+type fakeEmbeddedStruct struct {
+	Street string `icebox:"column,notNull"`
+}
+
+// Test that only structs can be embedded, and that embedded columns cannot
+// collide with other columns.
+func TestEmbeddedErrors(t *testing.T) {
+	if _, err := generateTable(new(fakeBadEmbeddedStruct), DefaultNaming); err == nil {
+		t.Errorf("error not raised for an embedded int")
+	}
+	if _, err := generateTable(new(fakeCollidingEmbeddedStruct), DefaultNaming); err == nil {
+		t.Errorf("error not raised for colliding columns")
+	}
+}
+
+type fakeArrayStruct struct {
+	Tags   []string `icebox:"column"`
+	Scores []int64  `icebox:"column"`
+}
+
+type fakeByteSliceStruct struct {
+	Data []byte `icebox:"column"`
+}
+
+type fakeUnsupportedStruct struct {
+	Handler func() `icebox:"column"`
+}
+
+// Test that slices of scalars map to arrays, and that columns of types which
+// cannot be mapped fail generation.
+func TestArrayColumns(t *testing.T) {
+	table, err := generateTable(new(fakeArrayStruct), DefaultNaming)
+	if err != nil {
+		t.Fatalf("table could not be generated: error = %s", err.Error())
+	}
+	testCases := []struct {
+		column string
+		elem   types.IceboxType
+	}{
+		{"tags", types.Text},
+		{"scores", types.Int},
+	}
+	for _, tc := range testCases {
+		column, err := table.ColumnFor(tc.column)
+		if err != nil {
+			t.Fatalf("array column is missing: error = %s", err.Error())
+		}
+		if column.Type().Type() != types.Array || column.Type().Elem().Type() != tc.elem {
+			t.Errorf("array type incorrect: column = %s, type = %s", tc.column, column.Type().Type())
+		}
+	}
+	if _, err := generateTable(new(fakeByteSliceStruct), DefaultNaming); err == nil {
+		t.Errorf("error not raised for a byte slice column")
+	}
+	if _, err := generateTable(new(fakeUnsupportedStruct), DefaultNaming); err == nil {
+		t.Errorf("error not raised for a column of an unsupported type")
+	} else if !strings.Contains(err.Error(), "Handler") {
+		t.Errorf("raised error doesn't name the field: error = %s", err.Error())
+	}
+}
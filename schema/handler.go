@@ -0,0 +1,78 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"github.com/jadengis/icebox/tags"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// TagHandler is invoked during schema generation for every column carrying a
+// custom subtag. It is given the column being generated, the struct field it
+// was generated from, and the subtag info. Returning an error fails the
+// generation of the schema.
+type TagHandler func(column ColumnEditor, field reflect.StructField, info string) error
+
+// The handlers registered for custom subtags.
+var tagHandlers = struct {
+	sync.RWMutex
+	handlers map[tags.SubTag]TagHandler
+}{handlers: make(map[tags.SubTag]TagHandler)}
+
+// RegisterTagHandler sets the handler invoked for columns carrying the given
+// custom subtag, which must first be added with tags.Register. A custom
+// subtag without a handler is accepted, but has no effect on the schema.
+func RegisterTagHandler(subTag tags.SubTag, handler TagHandler) error {
+	if !tags.IsCustom(subTag) {
+		return &unknownTypeError{
+			typeName: subTag.String(),
+			msg:      "subtag is not a registered custom subtag"}
+	}
+	tagHandlers.Lock()
+	defer tagHandlers.Unlock()
+	tagHandlers.handlers[subTag] = handler
+	return nil
+}
+
+// Invoke the handlers of the custom subtags left in the parsed tag on the
+// given column. Handlers run in order of subtag name.
+func handleCustomTags(column *columnImpl, field reflect.StructField, parsedTag tags.ParsedTag) error {
+	subTags := make([]tags.SubTag, 0, len(parsedTag))
+	for subTag := range parsedTag {
+		subTags = append(subTags, subTag)
+	}
+	sort.Slice(subTags, func(i, j int) bool {
+		return subTags[i] < subTags[j]
+	})
+
+	for _, subTag := range subTags {
+		// The handler is called without the lock held, since it may itself
+		// register handlers.
+		tagHandlers.RLock()
+		handler, found := tagHandlers.handlers[subTag]
+		tagHandlers.RUnlock()
+		if !found {
+			continue
+		}
+		if err := handler(column, field, parsedTag[subTag]); err != nil {
+			return &schemaGenError{
+				cause: err,
+				msg:   "error handling subtag " + subTag.String() + " on field " + field.Name}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"errors"
+	"github.com/jadengis/icebox/tags"
+	"github.com/jadengis/icebox/types"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	searchable tags.SubTag = "testSearchable"
+	encrypted  tags.SubTag = "testEncrypted"
+	registrar  tags.SubTag = "testRegistrar"
+)
+
+func init() {
+	tags.Register(searchable, nil)
+	tags.Register(encrypted, nil)
+	tags.Register(registrar, nil)
+	RegisterTagHandler(searchable, func(column ColumnEditor, field reflect.StructField, info string) error {
+		column.SetMetadata("search weight", info)
+		return nil
+	})
+	RegisterTagHandler(encrypted, func(column ColumnEditor, field reflect.StructField, info string) error {
+		if field.Type.Kind() != reflect.String {
+			return errors.New("only strings can be encrypted")
+		}
+		column.SetMetadata("encrypted", "true")
+		return nil
+	})
+}
+
+type fakeTaggedStruct struct {
+	Title string `icebox:"column,testSearchable:2"`
+	Token string `icebox:"column,testEncrypted"`
+}
+
+type fakeBadTaggedStruct struct {
+	Secret int `icebox:"column,testEncrypted"`
+}
+
+// Test that custom subtag handlers attach metadata to the columns.
+func TestCustomTagHandlers(t *testing.T) {
+	s, err := NewSchema("test_schema", new(fakeTaggedStruct))
+	if err != nil {
+		t.Fatalf("schema could not be generated: error = %s", err.Error())
+	}
+	table, _ := s.TableFor(new(fakeTaggedStruct))
+	title, _ := table.ColumnFor("title")
+	if weight := title.Metadata()["search weight"]; weight != "2" {
+		t.Errorf("searchable metadata incorrect: weight = %s", weight)
+	}
+	token, _ := table.ColumnFor("token")
+	if token.Metadata()["encrypted"] != "true" {
+		t.Errorf("encrypted metadata missing: metadata = %v", token.Metadata())
+	}
+
+	// Metadata survives serialization.
+	document, err := MarshalYAML(s)
+	if err != nil {
+		t.Fatalf("schema could not be encoded: error = %s", err.Error())
+	}
+	loaded, err := UnmarshalYAML(document)
+	if err != nil {
+		t.Fatalf("schema could not be decoded: error = %s\n%s", err.Error(), document)
+	}
+	table, _ = loaded.TableNamed(table.Name())
+	title, _ = table.ColumnFor("title")
+	if weight := title.Metadata()["search weight"]; weight != "2" {
+		t.Errorf("loaded searchable metadata incorrect: weight = %s", weight)
+	}
+}
+
+// Test that handler errors fail schema generation.
+func TestCustomTagHandlerError(t *testing.T) {
+	_, err := NewSchema("test_schema", new(fakeBadTaggedStruct))
+	if err == nil {
+		t.Errorf("error not raised for failing handler")
+	} else if !strings.Contains(err.Error(), "only strings") {
+		t.Errorf("raised error doesn't contain the handler error: error = %s", err.Error())
+	}
+	if err := RegisterTagHandler(tags.SubTag("asdf"), nil); err == nil {
+		t.Errorf("error not raised for unregistered subtag")
+	}
+}
+
+type fakeRegistrarStruct struct {
+	Title string `icebox:"column,testRegistrar"`
+}
+
+// Test that a handler may register handlers while it is being invoked.
+func TestCustomTagHandlerRegisters(t *testing.T) {
+	registered := false
+	err := RegisterTagHandler(registrar, func(column ColumnEditor, field reflect.StructField, info string) error {
+		registered = true
+		return RegisterTagHandler(registrar, func(column ColumnEditor, field reflect.StructField, info string) error {
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("handler could not be registered: error = %s", err.Error())
+	}
+	if _, err := NewSchema("test_schema", new(fakeRegistrarStruct)); err != nil {
+		t.Fatalf("schema could not be generated: error = %s", err.Error())
+	}
+	if !registered {
+		t.Errorf("registering handler was not invoked")
+	}
+}
+
+type fakeMetadataStruct struct {
+	Email string `icebox:"column,comment:'Login address',meta:(displayName='E-mail',pii=true)"`
+}
+
+// Test that the comment and meta subtags are exposed as column metadata, and
+// that metadata can be set programmatically.
+func TestColumnMetadata(t *testing.T) {
+	s, err := NewSchema("test_schema", new(fakeMetadataStruct))
+	if err != nil {
+		t.Fatalf("schema could not be generated: error = %s", err.Error())
+	}
+	tableName := DefaultNaming.TableName("fakeMetadataStruct")
+	if err := SetColumnMetadata(s, tableName, "email", "owner", "accounts"); err != nil {
+		t.Fatalf("metadata could not be set: error = %s", err.Error())
+	}
+	table, _ := s.TableNamed(tableName)
+	column, _ := table.ColumnFor("email")
+	expected := map[string]string{
+		MetadataComment:     "Login address",
+		MetadataDisplayName: "E-mail",
+		MetadataPII:         "true",
+		"owner":             "accounts",
+	}
+	if !reflect.DeepEqual(column.Metadata(), expected) {
+		t.Errorf("metadata incorrect: metadata = %v, expected = %v",
+			column.Metadata(), expected)
+	}
+	if err := SetColumnMetadata(s, tableName, "asdf", "owner", "accounts"); err == nil {
+		t.Errorf("error not raised for an unknown column")
+	}
+}
+
+// Struct with a tenant column for testing.
+type fakeTenantStruct struct {
+	ID    int64  `icebox:"column,primaryKey"`
+	OrgID int64  `icebox:"column,tenant"`
+	Name  string `icebox:"column"`
+}
+
+// Test that the tenant subtag and the tenant builder option mark the column
+// holding the tenant of a row.
+func TestTenantColumn(t *testing.T) {
+	tagged, err := NewTable(new(fakeTenantStruct))
+	if err != nil {
+		t.Fatalf("table could not be generated: error = %s", err.Error())
+	}
+	built, err := Builder().
+		Table("orders").For(new(fakeTenantStruct)).
+		Column("id", types.BigInt).PrimaryKey().Field("ID").
+		Column("org_id", types.BigInt).Field("OrgID").Tenant().
+		Column("name", types.Text).Field("Name").
+		Build("test_schema")
+	if err != nil {
+		t.Fatalf("schema could not be built: error = %s", err.Error())
+	}
+	builtTable, _ := built.TableNamed("orders")
+	for _, table := range []Table{tagged, builtTable} {
+		for _, column := range table.Columns() {
+			tenant := column.Metadata()[MetadataTenant] == "true"
+			if tenant != (column.Name() == "org_id") {
+				t.Errorf("tenant metadata incorrect: column = %s, tenant = %v",
+					column.Name(), tenant)
+			}
+		}
+	}
+}
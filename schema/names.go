@@ -40,17 +40,123 @@ func sqlNameFromCamelCase(name string) string {
 	return buffer.String()
 }
 
-// Split the given word into a slice of string all starting with
-// capital letters.
+// Split the given word into a slice of words, breaking before each capital
+// letter which starts a new word. Runs of capitals are kept together as an
+// acronym, with the last capital starting the next word if it is followed by
+// a lower case letter, so HTTPRequest splits into HTTP and Request. Digits
+// stay attached to the word before them.
 func splitOnCaps(word string) []string {
 	var words []string
-	l := 0
-	for s := word; s != ""; s = s[l:] {
-		l = strings.IndexFunc(s[1:], unicode.IsUpper) + 1
-		if l <= 0 {
-			l = len(s)
+	runes := []rune(word)
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		prev, cur := runes[i-1], runes[i]
+		if !unicode.IsUpper(cur) {
+			continue
 		}
-		words = append(words, s[:l])
+		endOfAcronym := unicode.IsUpper(prev) &&
+			i+1 < len(runes) && unicode.IsLower(runes[i+1])
+		if !unicode.IsUpper(prev) || endOfAcronym {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	if start < len(runes) {
+		words = append(words, string(runes[start:]))
 	}
 	return words
 }
+
+// Extract a lower camelCase name from an upper or lower CamelCase string.
+// Acronyms are treated as ordinary words, so UserID becomes userId.
+func camelNameFromCamelCase(name string) string {
+	var buffer bytes.Buffer
+	for i, word := range splitOnCaps(name) {
+		word = strings.ToLower(word)
+		if i > 0 {
+			runes := []rune(word)
+			runes[0] = unicode.ToUpper(runes[0])
+			word = string(runes)
+		}
+		buffer.WriteString(word)
+	}
+	return buffer.String()
+}
+
+// Words which are the same in the singular and plural.
+var uncountableWords = map[string]bool{
+	"data":        true,
+	"equipment":   true,
+	"fish":        true,
+	"information": true,
+	"metadata":    true,
+	"money":       true,
+	"news":        true,
+	"series":      true,
+	"sheep":       true,
+	"species":     true,
+}
+
+// Words with irregular plurals.
+var irregularPlurals = map[string]string{
+	"child":  "children",
+	"foot":   "feet",
+	"goose":  "geese",
+	"man":    "men",
+	"mouse":  "mice",
+	"person": "people",
+	"quiz":   "quizzes",
+	"tooth":  "teeth",
+	"woman":  "women",
+}
+
+// Words ending in f or fe whose plural ends in ves. Most such words, such as
+// safe or chief, take a plain s, so only these are changed.
+var vesPlurals = map[string]bool{
+	"calf":  true,
+	"elf":   true,
+	"half":  true,
+	"knife": true,
+	"leaf":  true,
+	"life":  true,
+	"loaf":  true,
+	"self":  true,
+	"shelf": true,
+	"thief": true,
+	"wife":  true,
+	"wolf":  true,
+}
+
+// Pluralize the last word of the given name following the rules of English.
+// The name may be in snake_case or camelCase.
+func englishPlural(name string) string {
+	start := strings.LastIndex(name, nameSeparator) + 1
+	if i := strings.LastIndexFunc(name, unicode.IsUpper); i > start {
+		start = i
+	}
+	prefix, word := name[:start], name[start:]
+	lower := strings.ToLower(word)
+
+	if uncountableWords[lower] {
+		return name
+	}
+	if plural, found := irregularPlurals[lower]; found {
+		// Keep the capitalization of the first letter of the original word.
+		return prefix + word[:1] + plural[1:]
+	}
+	switch {
+	case strings.HasSuffix(lower, "s"), strings.HasSuffix(lower, "x"),
+		strings.HasSuffix(lower, "z"), strings.HasSuffix(lower, "ch"),
+		strings.HasSuffix(lower, "sh"):
+		return name + "es"
+	case strings.HasSuffix(lower, "y") && len(lower) > 1 &&
+		!strings.ContainsRune("aeiou", rune(lower[len(lower)-2])):
+		return name[:len(name)-1] + "ies"
+	case vesPlurals[lower] && strings.HasSuffix(lower, "fe"):
+		return name[:len(name)-2] + "ves"
+	case vesPlurals[lower]:
+		return name[:len(name)-1] + "ves"
+	default:
+		return name + "s"
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+// NamingStrategy decides the SQL names of generated tables and columns.
+// A type implementing TableEntity always overrides the table name chosen by
+// the strategy, and a column tag with a name overrides the column name.
+//
+// TableName returns the table name for a Go type name, such as HTTPRequest.
+//
+// ColumnName returns the column name for a Go struct field name.
+type NamingStrategy interface {
+	TableName(string) string
+	ColumnName(string) string
+}
+
+// CaseStyle is the way the words of a name are joined together.
+//
+// SnakeCase: lower case words separated by underscores, e.g. http_request.
+//
+// CamelCase: lower camelCase, e.g. httpRequest.
+type CaseStyle int
+
+const (
+	SnakeCase CaseStyle = iota
+	CamelCase
+)
+
+// Pluralization is the way table names are pluralized.
+//
+// SimplePlural: an "s" is appended to the name, e.g. data becomes datas.
+//
+// EnglishPlural: the last word of the name is pluralized following the rules
+// of English, e.g. category becomes categories and person becomes people.
+//
+// NoPlural: table names are left singular.
+type Pluralization int
+
+const (
+	SimplePlural Pluralization = iota
+	EnglishPlural
+	NoPlural
+)
+
+// Naming is the configurable implementation of NamingStrategy.
+//
+// Style is the case style of both table and column names.
+//
+// Plural is the way table names are pluralized.
+//
+// TablePrefix is prepended to every table name, e.g. to give all the tables
+// of a schema a common namespace.
+type Naming struct {
+	Style       CaseStyle
+	Plural      Pluralization
+	TablePrefix string
+}
+
+// DefaultNaming is the naming strategy used by NewSchema, which names tables
+// and columns in snake_case and pluralizes table names by appending an "s".
+var DefaultNaming NamingStrategy = &Naming{Style: SnakeCase, Plural: SimplePlural}
+
+// Returns the styled, pluralized and prefixed table name for a type name.
+func (n *Naming) TableName(typeName string) string {
+	name := n.style(typeName)
+	switch n.Plural {
+	case SimplePlural:
+		name += "s"
+	case EnglishPlural:
+		name = englishPlural(name)
+	}
+	return n.TablePrefix + name
+}
+
+// Returns the styled column name for a field name.
+func (n *Naming) ColumnName(fieldName string) string {
+	return n.style(fieldName)
+}
+
+// Convert a CamelCase Go identifier into the configured case style.
+func (n *Naming) style(name string) string {
+	if n.Style == CamelCase {
+		return camelNameFromCamelCase(name)
+	}
+	return sqlNameFromCamelCase(name)
+}
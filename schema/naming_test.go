@@ -0,0 +1,105 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Test that the naming strategies produce the expected table names.
+func TestNamingTableName(t *testing.T) {
+	english := &Naming{Style: SnakeCase, Plural: EnglishPlural}
+	camel := &Naming{Style: CamelCase, Plural: EnglishPlural, TablePrefix: "app_"}
+	testCases := []struct {
+		naming   NamingStrategy
+		typeName string
+		result   string
+	}{
+		{DefaultNaming, "HTTPRequest", "http_requests"},
+		{DefaultNaming, "mockData", "mock_datas"},
+		{english, "Category", "categories"},
+		{english, "Person", "people"},
+		{english, "OrderBox", "order_boxes"},
+		{english, "Key", "keys"},
+		{english, "mockData", "mock_data"},
+		{english, "Knife", "knives"},
+		{english, "Life", "lives"},
+		{english, "Wife", "wives"},
+		{english, "BookShelf", "book_shelves"},
+		{english, "Safe", "safes"},
+		{english, "Cafe", "cafes"},
+		{english, "Giraffe", "giraffes"},
+		{english, "Chief", "chiefs"},
+		{english, "Golf", "golfs"},
+		{english, "Quiz", "quizzes"},
+		{english, "PopQuiz", "pop_quizzes"},
+		{camel, "HTTPRequest", "app_httpRequests"},
+		{camel, "SalesPerson", "app_salesPeople"},
+		{&Naming{Plural: NoPlural}, "UserID", "user_id"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("test table name %s", tc.typeName),
+			func(t *testing.T) {
+				name := tc.naming.TableName(tc.typeName)
+				if name != tc.result {
+					t.Errorf("table name incorrect: name = %s, expected = %s",
+						name, tc.result)
+				}
+			},
+		)
+	}
+}
+
+// Test that words are split with acronyms kept together.
+func TestColumnNames(t *testing.T) {
+	camel := &Naming{Style: CamelCase}
+	testCases := []struct {
+		fieldName string
+		snake     string
+		camel     string
+	}{
+		{"Id", "id", "id"},
+		{"UserID", "user_id", "userId"},
+		{"HTTPRequestURL", "http_request_url", "httpRequestUrl"},
+		{"Address2Line", "address2_line", "address2Line"},
+	}
+
+	for _, tc := range testCases {
+		if name := DefaultNaming.ColumnName(tc.fieldName); name != tc.snake {
+			t.Errorf("snake column name incorrect: name = %s, expected = %s", name, tc.snake)
+		}
+		if name := camel.ColumnName(tc.fieldName); name != tc.camel {
+			t.Errorf("camel column name incorrect: name = %s, expected = %s", name, tc.camel)
+		}
+	}
+}
+
+// Test that a schema generated with a naming strategy uses it, while
+// TableEntity types keep their own names.
+func TestNewSchemaWithNaming(t *testing.T) {
+	naming := &Naming{Style: SnakeCase, Plural: EnglishPlural, TablePrefix: "shop_"}
+	s, err := NewSchemaWithNaming("test_schema", naming, new(fakeOrderedStruct), namedMockData{})
+	if err != nil {
+		t.Fatalf("schema could not be generated: error = %s", err.Error())
+	}
+	if _, err := s.TableNamed("shop_fake_ordered_structs"); err != nil {
+		t.Errorf("prefixed table is missing: error = %s", err.Error())
+	}
+	if _, err := s.TableNamed(tableName); err != nil {
+		t.Errorf("custom named table is missing: error = %s", err.Error())
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"database/sql"
+	"github.com/jadengis/icebox/types"
+	"reflect"
+	"time"
+)
+
+// The reflect.Type of the sql.Scanner interface.
+var scannerInterface = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// Mapping between the struct types which can be stored in a single column
+// and their SQLTypes. The sql.Null types are stored as the type they wrap.
+var structSQLTypes = map[reflect.Type]func() types.SQLType{
+	reflect.TypeOf(time.Time{}):       sqlTypeOf(types.DateTime),
+	reflect.TypeOf(sql.NullTime{}):    sqlTypeOf(types.DateTime),
+	reflect.TypeOf(sql.NullBool{}):    sqlTypeOf(types.Bit),
+	reflect.TypeOf(sql.NullByte{}):    sqlTypeOf(types.TinyUint),
+	reflect.TypeOf(sql.NullInt16{}):   sqlTypeOf(types.SmallInt),
+	reflect.TypeOf(sql.NullInt32{}):   sqlTypeOf(types.MediumInt),
+	reflect.TypeOf(sql.NullInt64{}):   sqlTypeOf(types.Int),
+	reflect.TypeOf(sql.NullFloat64{}): sqlTypeOf(types.Double),
+	reflect.TypeOf(sql.NullString{}): func() types.SQLType {
+		return types.NewSQLTypeWithSize(types.VarChar, "255")
+	},
+}
+
+// Returns a constructor of SQLTypes of the given icebox type.
+func sqlTypeOf(iceboxType types.IceboxType) func() types.SQLType {
+	return func() types.SQLType {
+		return types.NewSQLType(iceboxType)
+	}
+}
+
+// Returns whether a field of the given type can hold NULL. Pointers, maps,
+// slices and interfaces hold NULL as nil, and sql.Scanner implementations
+// such as the sql.Null types handle NULL themselves.
+func isNullableType(fieldType reflect.Type) bool {
+	switch fieldType.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return true
+	default:
+		return reflect.PtrTo(fieldType).Implements(scannerInterface)
+	}
+}
+
+// Derive the nullability of the given column from the type of its field.
+// Columns whose field cannot hold NULL are not nullable, as the NULL could
+// not be scanned back, but are only given a NOT NULL constraint by the
+// notNull subtag, so that such columns can still be added to tables holding
+// rows. Fields reached through a pointer to an embedded struct are nullable,
+// as the whole struct may be nil.
+//
+// This returns an error if a field of a nullable type is tagged notNull, as
+// writing the nil value of the field would fail. Fields of embedded structs
+// may still be tagged notNull, in which case the struct is required.
+func handleNullability(column *columnImpl, field reflect.StructField, optional bool) error {
+	nullable := optional || isNullableType(field.Type)
+	if _, notNull := column.constraints[NotNull]; notNull && isNullableType(field.Type) {
+		return &typeError{
+			badType: field.Type,
+			msg:     "nullable field cannot be notNull, use a non-pointer type instead"}
+	}
+	column.required = !nullable
+	return nil
+}
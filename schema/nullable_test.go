@@ -0,0 +1,87 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"database/sql"
+	"github.com/jadengis/icebox/types"
+	"testing"
+	"time"
+)
+
+type fakeNullableStruct struct {
+	Id        int                `icebox:"column,primaryKey"`
+	Name      string             `icebox:"column"`
+	Nickname  *string            `icebox:"column"`
+	Score     sql.NullInt64      `icebox:"column"`
+	CreatedAt time.Time          `icebox:"column"`
+	DeletedAt *time.Time         `icebox:"column"`
+	Tags      []string           `icebox:"column"`
+	Home      *fakeAddressStruct `icebox:"embedded"`
+}
+
+type fakeAddressStruct struct {
+	Street string `icebox:"column"`
+}
+
+type fakeConflictingStruct struct {
+	Nickname *string `icebox:"column,notNull"`
+}
+
+// Test that nullability is derived from the field types.
+func TestNullable(t *testing.T) {
+	table, err := generateTable(new(fakeNullableStruct), DefaultNaming)
+	if err != nil {
+		t.Fatalf("table could not be generated: error = %s", err.Error())
+	}
+	testCases := []struct {
+		column     string
+		nullable   bool
+		iceboxType types.IceboxType
+	}{
+		{"id", false, types.Int},
+		{"name", false, types.VarChar},
+		{"nickname", true, types.VarChar},
+		{"score", true, types.Int},
+		{"created_at", false, types.DateTime},
+		{"deleted_at", true, types.DateTime},
+		{"tags", true, types.Array},
+		{"home_street", true, types.VarChar},
+	}
+	for _, tc := range testCases {
+		column, err := table.ColumnFor(tc.column)
+		if err != nil {
+			t.Errorf("column is missing: error = %s", err.Error())
+			continue
+		}
+		if column.Nullable() != tc.nullable || column.Type().Type() != tc.iceboxType {
+			t.Errorf("column incorrect: column = %s, nullable = %t, type = %s",
+				tc.column, column.Nullable(), column.Type().Type())
+		}
+	}
+	for _, name := range []string{"id", "name", "created_at"} {
+		column, _ := table.ColumnFor(name)
+		if _, found := column.ConstraintFor(NotNull); found {
+			t.Errorf("column given a NOT NULL constraint: column = %s", name)
+		}
+	}
+}
+
+// Test that nullable fields cannot be notNull.
+func TestNullableConflict(t *testing.T) {
+	if _, err := generateTable(new(fakeConflictingStruct), DefaultNaming); err == nil {
+		t.Errorf("error not raised for a notNull pointer")
+	}
+}
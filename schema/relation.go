@@ -16,6 +16,7 @@ package schema
 
 import (
 	"github.com/jadengis/icebox/tags"
+	"strconv"
 )
 
 // Relation represents a relation between two tables in a schema, for example
@@ -57,6 +58,22 @@ const (
 	ManyToMany
 )
 
+// String converts the given RelationType into its string respresentation.
+func (r RelationType) String() string {
+	if int(r) >= 0 && int(r) < len(relationTypeTags) {
+		return relationTypeTags[r].String()
+	}
+	return "relationType" + strconv.Itoa(int(r))
+}
+
+// Mapping between RelationType and tag names.
+var relationTypeTags = []tags.SubTag{
+	OneToOne:   tags.OneToOne,
+	OneToMany:  tags.OneToMany,
+	ManyToOne:  tags.ManyToOne,
+	ManyToMany: tags.ManyToMany,
+}
+
 // Map the given subtag to its corresponding relation type, if possible.
 // This returns an error if the mapping is not possible.
 func getRelationType(typeName tags.SubTag) (RelationType, error) {
@@ -16,6 +16,7 @@ package schema
 
 import (
 	"reflect"
+	"sort"
 )
 
 // Schema is a representation of a SQL database schema. Such a schema is determined
@@ -29,20 +30,31 @@ import (
 //
 // TableFor returns the Table corresponding to the type of the given object. If there is
 // no table for the given object, TableFor returns an error.
+//
+// TableNamed returns the Table with the given name. If there is no such table,
+// TableNamed returns an error.
+//
+// Tables returns the slice of all tables in this schema, ordered by table name.
 type Schema interface {
 	Name() string
 	TableFor(interface{}) (Table, error)
+	TableNamed(string) (Table, error)
+	Tables() []Table
 }
 
 // The default implementation of the Schema interface.
 //
 // Name is the name of this schema.
 //
-// Tables is a map from reflect.Type, that is the type of a given object, to its
-// corresponding table.
+// Tables is a map from table name to the corresponding table.
+//
+// Types is a map from reflect.Type, that is the type of a given object, to its
+// corresponding table. Tables loaded from a serialized schema have no type,
+// and are absent from this map.
 type schemaImpl struct {
 	name   string
-	tables map[reflect.Type]*tableImpl
+	tables map[string]*tableImpl
+	types  map[reflect.Type]*tableImpl
 }
 
 // Returns the internal name of the schema.
@@ -54,7 +66,7 @@ func (s *schemaImpl) Name() string {
 // This returns an error if the given object can't be found.
 func (s *schemaImpl) TableFor(object interface{}) (Table, error) {
 	objectType := getConcreteObjectType(reflect.TypeOf(object))
-	table, found := s.tables[objectType]
+	table, found := s.types[objectType]
 	if !found {
 		return nil, &notFoundError{
 			key: objectType,
@@ -64,10 +76,68 @@ func (s *schemaImpl) TableFor(object interface{}) (Table, error) {
 	return table, nil
 }
 
+// Returns the Table in the schema with the given name.
+// This returns an error if there is no such table.
+func (s *schemaImpl) TableNamed(name string) (Table, error) {
+	table, found := s.tables[name]
+	if !found {
+		return nil, &notFoundError{
+			key: name,
+			msg: "no table with the given name",
+		}
+	}
+	return table, nil
+}
+
+// Returns the slice of tables in the schema by pulling them from the table map.
+// The tables are sorted by name so that callers get a stable ordering.
+func (s *schemaImpl) Tables() []Table {
+	tables := make([]Table, 0, len(s.tables))
+	for _, table := range s.tables {
+		tables = append(tables, table)
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		return tables[i].Name() < tables[j].Name()
+	})
+	return tables
+}
+
+// SetColumnMetadata attaches metadata to a column of the given schema, for
+// metadata which is not known when writing the struct tags, such as comments
+// loaded from a data catalog. This returns an error if the column cannot be
+// found or belongs to a foreign Schema implementation.
+func SetColumnMetadata(s Schema, tableName, columnName, key, value string) error {
+	table, err := s.TableNamed(tableName)
+	if err != nil {
+		return err
+	}
+	column, err := table.ColumnFor(columnName)
+	if err != nil {
+		return err
+	}
+	editor, ok := column.(ColumnEditor)
+	if !ok {
+		return &unknownTypeError{
+			typeName: reflect.TypeOf(column).String(),
+			msg:      "column does not support metadata"}
+	}
+	editor.SetMetadata(key, value)
+	return nil
+}
+
 // Constructs a new Schema of the default implementation with the given name
-// and empty table map.
+// and empty table maps.
 func newSchema(name string) *schemaImpl {
 	return &schemaImpl{
 		name:   name,
-		tables: make(map[reflect.Type]*tableImpl)}
+		tables: make(map[string]*tableImpl),
+		types:  make(map[reflect.Type]*tableImpl)}
+}
+
+// Add the given table to the schema, indexing it by type if it has one.
+func (s *schemaImpl) addTable(table *tableImpl) {
+	s.tables[table.name] = table
+	if table.dataType != nil {
+		s.types[table.dataType] = table
+	}
 }
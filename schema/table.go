@@ -16,20 +16,22 @@ package schema
 
 import (
 	"reflect"
+	"sort"
 	"strings"
 )
 
 // Table is a description of a SQL table as an interface.
 //
-// Type returns the type of the object that generated this table.
+// Type returns the type of the object that generated this table. This is nil
+// for tables loaded from a serialized schema.
 //
 // Name returns the name of the SQL table.
 //
-// Columns returns the slice of all columns in this table.
+// Columns returns the slice of all columns in this table, ordered by ordinal.
 //
 // ColumnFor returns the Table column for the given column name.
 //
-// Relations returns the slice of Relations on this table.
+// Relations returns the slice of Relations on this table, ordered by type.
 //
 // RelationFor returns the relation on the table for the given relation type.
 type Table interface {
@@ -68,11 +70,15 @@ func (t *tableImpl) Name() string {
 }
 
 // Return the slice of columns in this table by pulling them from the column map.
+// The columns are sorted by ordinal, so generated SQL is stable between runs.
 func (t *tableImpl) Columns() []Column {
-	columns := make([]Column, len(t.columns))
+	columns := make([]Column, 0, len(t.columns))
 	for _, column := range t.columns {
 		columns = append(columns, column)
 	}
+	sort.Slice(columns, func(i, j int) bool {
+		return columns[i].Ordinal() < columns[j].Ordinal()
+	})
 	return columns
 }
 
@@ -90,11 +96,15 @@ func (t *tableImpl) ColumnFor(name string) (Column, error) {
 }
 
 // Returns the slice of relations on this table by pulling them from the relation map.
+// The relations are sorted by type.
 func (t *tableImpl) Relations() []Relation {
-	relations := make([]Relation, len(t.relations))
+	relations := make([]Relation, 0, len(t.relations))
 	for _, relation := range t.relations {
 		relations = append(relations, relation)
 	}
+	sort.Slice(relations, func(i, j int) bool {
+		return relations[i].Type() < relations[j].Type()
+	})
 	return relations
 }
 
@@ -116,6 +126,12 @@ func newTable(dataType reflect.Type, name string) *tableImpl {
 	}
 }
 
+// Add the given column to the table, placing it after the existing columns.
+func (t *tableImpl) addColumn(column *columnImpl) {
+	column.ordinal = len(t.columns)
+	t.columns[column.name] = column
+}
+
 // TableEntity provides externaly the requirements on a type to be used as a
 // table entity.
 type TableEntity interface {
@@ -132,24 +148,24 @@ type namedTable interface {
 
 // Get the table name for the given object.
 // This table name can either be specified (via the TableEntity interface)
-// or automatically generated from the types name.
-func getTableName(object interface{}) string {
+// or automatically generated from the types name by the naming strategy.
+func getTableName(object interface{}, naming NamingStrategy) string {
 	if val, ok := object.(namedTable); ok {
 		return val.TableName()
 	}
-	return tableNameFromObject(object)
+	return tableNameFromObject(object, naming)
 }
 
 // Extract a SQL style table name for an object.
-func tableNameFromObject(object interface{}) string {
-	return tableNameFromType(reflect.TypeOf(object))
+func tableNameFromObject(object interface{}, naming NamingStrategy) string {
+	return tableNameFromType(reflect.TypeOf(object), naming)
 }
 
 // Extract a SQL style table name for an object given its type.
-func tableNameFromType(objectType reflect.Type) string {
+func tableNameFromType(objectType reflect.Type, naming NamingStrategy) string {
 	var typeName = objectType.String()
 	if idx := strings.Index(typeName, "."); idx != -1 {
 		typeName = typeName[idx+1:]
 	}
-	return sqlNameFromCamelCase(typeName) + "s"
+	return naming.TableName(typeName)
 }
@@ -41,7 +41,7 @@ func (n namedMockData) TableName() string {
 // Test that we generate the right names for camel case structs.
 func TestTableNameFromObject(t *testing.T) {
 	mock := mockData{num: 5, word: "stuff"}
-	name := tableNameFromObject(mock)
+	name := tableNameFromObject(mock, DefaultNaming)
 	expected := "mock_data"
 	if name != "mock_datas" {
 		t.Errorf("table name was not %s as expected: name = %s", expected, name)
@@ -61,7 +61,7 @@ func TestGetTableName(t *testing.T) {
 	for i, tc := range testCases {
 		t.Run(fmt.Sprintf("test table name object %d", i),
 			func(t *testing.T) {
-				name := getTableName(tc.input)
+				name := getTableName(tc.input, DefaultNaming)
 				if name != tc.result {
 					t.Errorf("table name incorrect: name = %s, expected = %s",
 						name, tc.result)
@@ -70,3 +70,38 @@ func TestGetTableName(t *testing.T) {
 		)
 	}
 }
+
+// Struct with several constrained columns for testing.
+type fakeConstrainedStruct struct {
+	ID    int64  `icebox:"column,primaryKey"`
+	Email string `icebox:"column,unique,notNull"`
+	Name  string `icebox:"column"`
+}
+
+// Test that the columns of a table and the constraints of a column hold no
+// empty entries ahead of the real ones.
+func TestColumnsAndConstraintsLength(t *testing.T) {
+	table, err := NewTable(new(fakeConstrainedStruct))
+	if err != nil {
+		t.Fatalf("table could not be generated: error = %s", err.Error())
+	}
+	columns := table.Columns()
+	if len(columns) != 3 {
+		t.Fatalf("column count incorrect: count = %d, expected = %d", len(columns), 3)
+	}
+	for _, column := range columns {
+		if column == nil {
+			t.Fatalf("columns hold an empty entry: columns = %v", columns)
+		}
+	}
+	email, _ := table.ColumnFor("email")
+	constraints := email.Constraints()
+	if len(constraints) != 2 {
+		t.Fatalf("constraint count incorrect: count = %d, expected = %d", len(constraints), 2)
+	}
+	for _, constraint := range constraints {
+		if constraint == nil {
+			t.Errorf("constraints hold an empty entry: constraints = %v", constraints)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The schema document only holds mappings, sequences and strings, so icebox
+// reads and writes the small block-style subset of YAML needed for these
+// rather than depending on a full YAML implementation. Anything written by
+// MarshalYAML, or by hand in the same style, can be read by UnmarshalYAML.
+
+// MarshalYAML encodes the given schema as a YAML document with the same
+// structure as the document produced by MarshalJSON.
+func MarshalYAML(s Schema) ([]byte, error) {
+	var buffer bytes.Buffer
+	encodeYAMLMapping(&buffer, reflect.ValueOf(*newSchemaDocument(s)), 0, "")
+	return buffer.Bytes(), nil
+}
+
+// UnmarshalYAML decodes a schema from a YAML document produced by
+// MarshalYAML. As with UnmarshalJSON, the decoded tables have no Go type.
+func UnmarshalYAML(data []byte) (Schema, error) {
+	parser, err := newYAMLParser(data)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := parser.parseBlock(0)
+	if err != nil {
+		return nil, err
+	}
+	if parser.pos < len(parser.lines) {
+		return nil, parser.errorf("unexpected indentation")
+	}
+	// The parsed tree has the same shape as the JSON document, so round trip
+	// it through encoding/json to populate the document structs.
+	data, err = json.Marshal(tree)
+	if err != nil {
+		return nil, err
+	}
+	return UnmarshalJSON(data)
+}
+
+// Write the fields of the given document struct as a YAML mapping. Every key
+// is indented by the given amount, except the first which is preceded by the
+// given prefix instead, so that mappings can start on the line of a sequence
+// item marker.
+func encodeYAMLMapping(buffer *bytes.Buffer, v reflect.Value, indent int, prefix string) {
+	for i := 0; i < v.NumField(); i++ {
+		name, omitEmpty := jsonFieldName(v.Type().Field(i))
+		field := v.Field(i)
+		if omitEmpty && field.Len() == 0 {
+			continue
+		}
+		buffer.WriteString(prefix)
+		prefix = strings.Repeat(" ", indent)
+		buffer.WriteString(name)
+		buffer.WriteString(":")
+
+		switch field.Kind() {
+		case reflect.String:
+			buffer.WriteString(" ")
+			buffer.WriteString(strconv.Quote(field.String()))
+			buffer.WriteString("\n")
+		case reflect.Map:
+			buffer.WriteString("\n")
+			encodeYAMLStringMap(buffer, field, indent+2)
+		case reflect.Slice:
+			if field.Len() == 0 {
+				buffer.WriteString(" []\n")
+				continue
+			}
+			buffer.WriteString("\n")
+			for j := 0; j < field.Len(); j++ {
+				item := field.Index(j)
+				if item.Kind() == reflect.String {
+					buffer.WriteString(strings.Repeat(" ", indent+2) + "- ")
+					buffer.WriteString(strconv.Quote(item.String()))
+					buffer.WriteString("\n")
+					continue
+				}
+				encodeYAMLMapping(buffer, item, indent+4,
+					strings.Repeat(" ", indent+2)+"- ")
+			}
+		}
+	}
+}
+
+// Write a map of strings as a YAML mapping with sorted keys. Keys which are
+// not plain identifiers are quoted.
+func encodeYAMLStringMap(buffer *bytes.Buffer, v reflect.Value, indent int) {
+	keys := make([]string, 0, v.Len())
+	for _, key := range v.MapKeys() {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		buffer.WriteString(strings.Repeat(" ", indent))
+		if yamlKeyPattern.MatchString(key + ":") {
+			buffer.WriteString(key)
+		} else {
+			buffer.WriteString(strconv.Quote(key))
+		}
+		buffer.WriteString(": ")
+		buffer.WriteString(strconv.Quote(v.MapIndex(reflect.ValueOf(key)).String()))
+		buffer.WriteString("\n")
+	}
+}
+
+// Extract the key name and omitempty option from the json tag of a document
+// struct field.
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if i := strings.Index(tag, ","); i >= 0 {
+		return tag[:i], strings.Contains(tag[i:], "omitempty")
+	}
+	return tag, false
+}
+
+// A non-blank line of a YAML document.
+type yamlLine struct {
+	number int
+	indent int
+	text   string
+}
+
+// Matches a line holding a mapping key.
+var yamlKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*:( |$)`)
+
+// A recursive descent parser over the lines of a YAML document.
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// Split the given document into lines, dropping blank and comment lines.
+func newYAMLParser(data []byte) (*yamlParser, error) {
+	parser := new(yamlParser)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		text := strings.TrimLeft(line, " ")
+		if text == "" || strings.HasPrefix(text, "#") || text == "---" {
+			continue
+		}
+		if strings.HasPrefix(text, "\t") {
+			return nil, &documentError{
+				cause: &yamlError{line: number, msg: "tabs cannot be used for indentation"},
+				msg:   "invalid schema document"}
+		}
+		parser.lines = append(parser.lines, yamlLine{
+			number: number,
+			indent: len(line) - len(text),
+			text:   text,
+		})
+	}
+	return parser, scanner.Err()
+}
+
+// Parse the mapping or sequence starting at the current line.
+func (p *yamlParser) parseBlock(indent int) (interface{}, error) {
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+	if isYAMLSequenceItem(p.lines[p.pos].text) {
+		return p.parseSequence(indent)
+	}
+	return p.parseMapping(indent)
+}
+
+// Parse the mapping whose keys are at the given indentation.
+func (p *yamlParser) parseMapping(indent int) (interface{}, error) {
+	mapping := make(map[string]interface{})
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent {
+		line := p.lines[p.pos]
+		key, rest, err := splitYAMLKey(line.text)
+		if err != nil {
+			return nil, p.errorf(err.Error())
+		}
+		p.pos++
+
+		var value interface{}
+		switch {
+		case rest != "":
+			value, err = parseYAMLScalar(rest)
+		case p.pos < len(p.lines) && p.lines[p.pos].indent > indent:
+			value, err = p.parseBlock(p.lines[p.pos].indent)
+		case p.pos < len(p.lines) && p.lines[p.pos].indent == indent &&
+			isYAMLSequenceItem(p.lines[p.pos].text):
+			value, err = p.parseSequence(indent)
+		}
+		if err != nil {
+			return nil, p.wrap(line, err)
+		}
+		mapping[key] = value
+	}
+	return mapping, nil
+}
+
+// Parse the sequence whose item markers are at the given indentation.
+func (p *yamlParser) parseSequence(indent int) (interface{}, error) {
+	sequence := make([]interface{}, 0)
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent &&
+		isYAMLSequenceItem(p.lines[p.pos].text) {
+		line := p.lines[p.pos]
+		rest := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+		var item interface{}
+		var err error
+		switch {
+		case rest == "":
+			p.pos++
+			if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+				item, err = p.parseBlock(p.lines[p.pos].indent)
+			}
+		case isYAMLKey(rest):
+			// The item is a mapping starting on the marker line, so treat the
+			// rest of the line as the first key of the mapping.
+			itemIndent := indent + len(line.text) - len(rest)
+			p.lines[p.pos] = yamlLine{number: line.number, indent: itemIndent, text: rest}
+			item, err = p.parseMapping(itemIndent)
+		default:
+			p.pos++
+			item, err = parseYAMLScalar(rest)
+		}
+		if err != nil {
+			return nil, p.wrap(line, err)
+		}
+		sequence = append(sequence, item)
+	}
+	return sequence, nil
+}
+
+// Split a line holding a mapping key into the key and the rest of the line.
+// The key is either a plain identifier or a double quoted string.
+func splitYAMLKey(text string) (string, string, error) {
+	if yamlKeyPattern.MatchString(text) {
+		i := strings.Index(text, ":")
+		return text[:i], strings.TrimSpace(text[i+1:]), nil
+	}
+	if strings.HasPrefix(text, `"`) {
+		if i := strings.Index(text, `":`); i > 0 {
+			key, err := strconv.Unquote(text[:i+1])
+			if err == nil {
+				return key, strings.TrimSpace(text[i+2:]), nil
+			}
+		}
+	}
+	return "", "", &yamlError{msg: "expected a mapping key"}
+}
+
+// Returns whether the given line text starts with a mapping key.
+func isYAMLKey(text string) bool {
+	_, _, err := splitYAMLKey(text)
+	return err == nil
+}
+
+// Returns whether the given line text starts a sequence item.
+func isYAMLSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// Parse an inline scalar value. All scalars are strings in the schema
+// document, so plain values are never converted to numbers or bools.
+func parseYAMLScalar(text string) (interface{}, error) {
+	switch {
+	case text == "[]":
+		return make([]interface{}, 0), nil
+	case text == "{}":
+		return make(map[string]interface{}), nil
+	case strings.HasPrefix(text, `"`):
+		return strconv.Unquote(text)
+	case strings.HasPrefix(text, "'"):
+		if len(text) < 2 || !strings.HasSuffix(text, "'") {
+			return nil, &yamlError{msg: "unterminated single quoted string"}
+		}
+		return strings.Replace(text[1:len(text)-1], "''", "'", -1), nil
+	default:
+		return text, nil
+	}
+}
+
+// Build an error pointing at the current line.
+func (p *yamlParser) errorf(msg string) error {
+	line := p.lines[len(p.lines)-1]
+	if p.pos < len(p.lines) {
+		line = p.lines[p.pos]
+	}
+	return &documentError{
+		cause: &yamlError{line: line.number, msg: msg},
+		msg:   "invalid schema document"}
+}
+
+// Attach the given line to an error from a nested parse, unless it already
+// points at a line.
+func (p *yamlParser) wrap(line yamlLine, err error) error {
+	switch e := err.(type) {
+	case *documentError:
+		return e
+	case *yamlError:
+		if e.line == 0 {
+			e.line = line.number
+		}
+		return &documentError{cause: e, msg: "invalid schema document"}
+	default:
+		return &documentError{
+			cause: &yamlError{line: line.number, msg: err.Error()},
+			msg:   "invalid schema document"}
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icebox
+
+import (
+	"github.com/jadengis/icebox/schema"
+	"sync"
+)
+
+// Scope is a reusable fragment of a query, such as a condition shared by
+// many queries on a table. Scopes taking arguments are written as functions
+// returning a Scope, for example
+//
+//	func OwnedBy(user int64) icebox.Scope {
+//		return func(q *icebox.Query) *icebox.Query {
+//			return q.Where(`"owner_id" = ?`, user)
+//		}
+//	}
+//
+//	db.From(new(Post)).Scopes(OwnedBy(7)).All(&posts)
+type Scope func(*Query) *Query
+
+// The scopes defined on the tables of a DB.
+//
+// Named are the scopes applied by name with Query.Scope, by table.
+//
+// Defaults are the scopes applied to every query, by table.
+type scopeRegistry struct {
+	mutex    sync.RWMutex
+	named    map[schema.Table]map[string]Scope
+	defaults map[schema.Table][]Scope
+}
+
+// Construct an empty scope registry.
+func newScopeRegistry() *scopeRegistry {
+	return &scopeRegistry{
+		named:    make(map[schema.Table]map[string]Scope),
+		defaults: make(map[schema.Table][]Scope),
+	}
+}
+
+// DefineScope defines a scope with the given name on the table of the type
+// of the given object, so that queries on the table can apply it with
+// Query.Scope. Defining a scope again replaces it.
+func (db *DB) DefineScope(object interface{}, name string, scope Scope) error {
+	table, err := db.tableFor(object)
+	if err != nil {
+		return err
+	}
+	db.scopes.mutex.Lock()
+	defer db.scopes.mutex.Unlock()
+	if db.scopes.named[table] == nil {
+		db.scopes.named[table] = make(map[string]Scope)
+	}
+	db.scopes.named[table][name] = scope
+	return nil
+}
+
+// DefaultScope adds a scope applied to every query on the table of the type
+// of the given object, including counts and aggregates, unless the query is
+// Unscoped. Default scopes are applied in the order they were added, when
+// the query is run, so they should only add conditions.
+func (db *DB) DefaultScope(object interface{}, scope Scope) error {
+	table, err := db.tableFor(object)
+	if err != nil {
+		return err
+	}
+	db.scopes.mutex.Lock()
+	defer db.scopes.mutex.Unlock()
+	db.scopes.defaults[table] = append(db.scopes.defaults[table], scope)
+	return nil
+}
+
+// Scopes applies the given scopes to the query, in order.
+func (q *Query) Scopes(scopes ...Scope) *Query {
+	for _, scope := range scopes {
+		q = scope(q)
+	}
+	return q
+}
+
+// Scope applies the scope with the given name defined on the table of the
+// query with DB.DefineScope.
+func (q *Query) Scope(name string) *Query {
+	if q.table == nil {
+		return q
+	}
+	q.db.scopes.mutex.RLock()
+	scope, found := q.db.scopes.named[q.table][name]
+	q.db.scopes.mutex.RUnlock()
+	if !found {
+		q.fail(&queryError{msg: "no scope " + name + " on table " + q.table.Name()})
+		return q
+	}
+	return scope(q)
+}
+
+// Unscoped stops the default scopes of the table from being applied to the
+// query.
+func (q *Query) Unscoped() *Query {
+	q.unscoped = true
+	return q
+}
+
+// Get the query with the default scopes of its table applied, leaving the
+// query itself unchanged.
+func (q *Query) withDefaultScopes() *Query {
+	if q.unscoped {
+		return q
+	}
+	q.db.scopes.mutex.RLock()
+	defaults := q.db.scopes.defaults[q.table]
+	q.db.scopes.mutex.RUnlock()
+	if len(defaults) == 0 {
+		return q
+	}
+	scoped := q.clone()
+	scoped.unscoped = true
+	return scoped.Scopes(defaults...)
+}
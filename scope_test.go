@@ -0,0 +1,82 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icebox
+
+import (
+	"testing"
+)
+
+// A scope taking an argument, as scopes are meant to be written.
+func fakeNamed(name string) Scope {
+	return func(q *Query) *Query {
+		return q.Where(`"name" = ?`, name)
+	}
+}
+
+// Test that named scopes, scope functions and default scopes are applied
+// to queries on their table.
+func TestScopes(t *testing.T) {
+	db := openFakeAccounts(t, "postgres")
+	defer db.Close()
+	err := db.DefineScope(new(fakeAccount), "verified", func(q *Query) *Query {
+		return q.Where(`"email" IS NOT NULL`)
+	})
+	if err != nil {
+		t.Fatalf("scope could not be defined: error = %s", err.Error())
+	}
+	db.DefaultScope(new(fakeAccount), func(q *Query) *Query {
+		return q.Where(`"id" > ?`, 0)
+	})
+
+	testCases := []struct {
+		query    *Query
+		expected string
+	}{
+		{
+			db.From(new(fakeAccount)).Scope("verified").Scopes(fakeNamed("a")),
+			`SELECT "id", "email", "name" FROM "fake_accounts" ` +
+				`WHERE ("email" IS NOT NULL) AND ("name" = $1) AND ("id" > $2)`,
+		},
+		{
+			db.From(new(fakeAccount)).Scopes(fakeNamed("a")).Unscoped(),
+			`SELECT "id", "email", "name" FROM "fake_accounts" WHERE ("name" = $1)`,
+		},
+	}
+	for _, tc := range testCases {
+		query, _, err := tc.query.SQL()
+		if err != nil {
+			t.Fatalf("query could not be built: error = %s", err.Error())
+		}
+		if query != tc.expected {
+			t.Errorf("scoped query incorrect: query = %s, expected = %s", query, tc.expected)
+		}
+		if again, _, _ := tc.query.SQL(); again != query {
+			t.Errorf("default scope applied twice: query = %s", again)
+		}
+	}
+
+	fakeQueue()
+	db.From(new(fakeAccount)).Count()
+	expected := `SELECT COUNT(*) FROM "fake_accounts" WHERE ("id" > $1)`
+	if statements := fakeStatements(); len(statements) != 1 || statements[0].query != expected {
+		t.Errorf("default scope not applied to count: statements = %v", statements)
+	}
+	if _, _, err := db.From(new(fakeAccount)).Scope("missing").SQL(); err == nil {
+		t.Errorf("error not raised for an unknown scope")
+	}
+	if err := db.DefaultScope(new(fakeReport), fakeNamed("a")); err == nil {
+		t.Errorf("error not raised for a type outside the schema")
+	}
+}
@@ -14,11 +14,6 @@
 
 package icebox
 
-import (
-//"database/sql"
-//"reflect"
-)
-
 // A Selecter can populate itself via a select query against a given DB.
 type Selecter interface {
 	// Select runs this objects Select query against the given DB.
@@ -27,18 +22,24 @@ type Selecter interface {
 	SelectTx(*Tx) error
 }
 
+// Select runs the Select query of the Model on a replica of the DB, if it
+// has any, unless the DB reads from the primary. The Model has no table of
+// its own, so its query only checks that the database can be read from, and
+// entities embedding the Model override Select to read their row.
 func (m *Model) Select(db *DB) error {
-	var tx, err = db.Begin()
-	if err != nil {
-		return err
-	}
-	err = tx.Commit()
-	if err != nil {
-		return err
-	}
-	return nil
+	return m.selectOn(db.readQueryer())
 }
 
-func (m *Model) SelectTx(db *DB) error {
+// SelectTx runs the Select query of the Model within the given Tx.
+func (m *Model) SelectTx(tx *Tx) error {
+	return m.selectOn(tx.queryer())
+}
 
+// Run the Select query of the Model with the given queryer.
+func (m *Model) selectOn(q queryer) error {
+	rows, err := q.Query("SELECT 1")
+	if err != nil {
+		return err
+	}
+	return rows.Close()
 }
@@ -0,0 +1,253 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icebox
+
+import (
+	"container/list"
+	"database/sql"
+	"sync"
+)
+
+// DefaultStmtCacheSize is the number of prepared statements a DB keeps
+// unless SetStmtCacheSize is called.
+const DefaultStmtCacheSize = 64
+
+// SetStmtCacheSize bounds the number of statements generated by icebox which
+// the DB keeps prepared, closing the least recently used statements beyond
+// the bound. A size of zero disables the cache, so that every statement is
+// prepared and closed by database/sql as it is run.
+//
+// Statements run within a Tx reuse the statements cached by its DB, prepared
+// again on the connection of the Tx with Tx.Stmt. Statements which are not
+// cached yet are run directly within a Tx, since preparing them on the DB
+// could wait for the connection held by the Tx. Each replica of the DB keeps
+// a cache of the same size. Raw and named queries are not cached, so that
+// one-off SQL does not evict the statements generated by icebox.
+func (db *DB) SetStmtCacheSize(size int) {
+	db.stmts.resize(size)
+	db.replicas.resize(size)
+}
+
+// Close closes the prepared statements cached by the DB, and then the DB
+// along with its replicas.
+func (db *DB) Close() error {
+	db.stmts.resize(0)
+	replicaErr := db.replicas.close()
+	if err := db.DB.Close(); err != nil {
+		return err
+	}
+	return replicaErr
+}
+
+// Get the queryer running statements against the DB through its cache of
+// prepared statements.
+func (db *DB) queryer() queryer {
+	return &stmtQueryer{cache: db.stmts, db: db.DB}
+}
+
+// Get the queryer running statements within the transaction, through the
+// cache of prepared statements of its DB.
+func (tx *Tx) queryer() queryer {
+	return &stmtQueryer{cache: tx.db.stmts, tx: tx}
+}
+
+// A least recently used cache of prepared statements keyed by their SQL.
+//
+// Entries is the list of cached statements, the most recently used first.
+//
+// Statements are the elements of the entries by SQL.
+type stmtCache struct {
+	mutex      sync.Mutex
+	size       int
+	entries    *list.List
+	statements map[string]*list.Element
+}
+
+// A statement of a stmtCache. Refs counts the statements being run, so that
+// an evicted statement is only closed once they are done.
+type stmtEntry struct {
+	query   string
+	stmt    *sql.Stmt
+	refs    int
+	evicted bool
+}
+
+// Construct an empty cache of the given size.
+func newStmtCache(size int) *stmtCache {
+	return &stmtCache{
+		size:       size,
+		entries:    list.New(),
+		statements: make(map[string]*list.Element),
+	}
+}
+
+// Get the cached statement for the given query, preparing it on the given
+// DB if it isn't cached. The statement must be released once it has been
+// run. This returns nil if the cache is disabled, or if the statement isn't
+// cached and no DB is given.
+func (c *stmtCache) acquire(db *sql.DB, query string) (*stmtEntry, error) {
+	if entry, disabled := c.lookup(query); entry != nil || disabled || db == nil {
+		return entry, nil
+	}
+
+	// Prepare outside the lock so that a slow prepare doesn't hold up the
+	// statements already cached.
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, found := c.statements[query]; found {
+		stmt.Close()
+		entry := element.Value.(*stmtEntry)
+		entry.refs++
+		return entry, nil
+	}
+	entry := &stmtEntry{query: query, stmt: stmt, refs: 1}
+	c.statements[query] = c.entries.PushFront(entry)
+	c.evict()
+	return entry, nil
+}
+
+// Get the cached statement for the given query, if any, along with whether
+// the cache is disabled.
+func (c *stmtCache) lookup(query string) (*stmtEntry, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.size == 0 {
+		return nil, true
+	}
+	element, found := c.statements[query]
+	if !found {
+		return nil, false
+	}
+	c.entries.MoveToFront(element)
+	entry := element.Value.(*stmtEntry)
+	entry.refs++
+	return entry, false
+}
+
+// Release the given statement acquired from the cache, closing it if it was
+// evicted in the meantime.
+func (c *stmtCache) release(entry *stmtEntry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry.refs--
+	if entry.evicted && entry.refs == 0 {
+		entry.stmt.Close()
+	}
+}
+
+// Change the size of the cache, evicting the statements beyond it.
+func (c *stmtCache) resize(size int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.size = size
+	c.evict()
+}
+
+// Evict the least recently used statements beyond the size of the cache.
+// Statements which are not being run are closed right away.
+func (c *stmtCache) evict() {
+	for c.entries.Len() > c.size {
+		entry := c.entries.Remove(c.entries.Back()).(*stmtEntry)
+		delete(c.statements, entry.query)
+		entry.evicted = true
+		if entry.refs == 0 {
+			entry.stmt.Close()
+		}
+	}
+}
+
+// A queryer running statements through a stmtCache, within a transaction if
+// one is given. Statements are run directly when the cache is disabled, and
+// statements missing from the cache are prepared on the DB if one is given.
+type stmtQueryer struct {
+	cache *stmtCache
+	db    *sql.DB
+	tx    *Tx
+}
+
+// Get the plain queryer statements are run through when they aren't cached.
+func (q *stmtQueryer) direct() queryer {
+	if q.tx != nil {
+		return q.tx
+	}
+	return q.db
+}
+
+// Get the statement for the given cached statement, prepared on the
+// connection of the transaction if there is one.
+func (q *stmtQueryer) stmt(entry *stmtEntry) *sql.Stmt {
+	if q.tx != nil {
+		return q.tx.stmt(entry)
+	}
+	return entry.stmt
+}
+
+// Get the given cached statement prepared on the connection of the
+// transaction, preparing it only the first time it is run within the
+// transaction.
+func (tx *Tx) stmt(entry *stmtEntry) *sql.Stmt {
+	tx.mutex.Lock()
+	defer tx.mutex.Unlock()
+	if stmt, found := tx.stmts[entry.query]; found {
+		return stmt
+	}
+	if tx.stmts == nil {
+		tx.stmts = make(map[string]*sql.Stmt)
+	}
+	stmt := tx.Tx.Stmt(entry.stmt)
+	tx.stmts[entry.query] = stmt
+	return stmt
+}
+
+// Exec runs the given statement through the cache.
+func (q *stmtQueryer) Exec(query string, args ...interface{}) (sql.Result, error) {
+	entry, err := q.cache.acquire(q.db, query)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return q.direct().Exec(query, args...)
+	}
+	defer q.cache.release(entry)
+	return q.stmt(entry).Exec(args...)
+}
+
+// Query runs the given query through the cache.
+func (q *stmtQueryer) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	entry, err := q.cache.acquire(q.db, query)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return q.direct().Query(query, args...)
+	}
+	defer q.cache.release(entry)
+	return q.stmt(entry).Query(args...)
+}
+
+// QueryRow runs the given query through the cache. Errors preparing the
+// query are deferred to Row.Scan, as database/sql does.
+func (q *stmtQueryer) QueryRow(query string, args ...interface{}) *sql.Row {
+	entry, err := q.cache.acquire(q.db, query)
+	if err != nil || entry == nil {
+		return q.direct().QueryRow(query, args...)
+	}
+	defer q.cache.release(entry)
+	return q.stmt(entry).QueryRow(args...)
+}
@@ -0,0 +1,139 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icebox
+
+import (
+	"testing"
+)
+
+// Test that repeated statements are prepared once, and that the cache
+// evicts the least recently used statements beyond its size.
+func TestStmtCache(t *testing.T) {
+	db := openFakeAccounts(t, "sqlite3")
+	defer db.Close()
+	fakeQueue()
+
+	for i := 0; i < 3; i++ {
+		if err := db.Insert(&fakeAccount{Email: "a@x"}); err != nil {
+			t.Fatalf("insert failed: error = %s", err.Error())
+		}
+	}
+	if prepared := fakePrepared(); prepared != 1 {
+		t.Errorf("statement not reused: prepared = %d, expected = 1", prepared)
+	}
+
+	db.SetStmtCacheSize(1)
+	db.Insert(&fakeAccount{Id: 1, Email: "a@x"})
+	db.Insert(&fakeAccount{Email: "a@x"})
+	if prepared := fakePrepared(); prepared != 3 {
+		t.Errorf("evicted statement not prepared again: prepared = %d, expected = 3", prepared)
+	}
+	if len(db.stmts.statements) != 1 || db.stmts.entries.Len() != 1 {
+		t.Errorf("cache not bounded: statements = %d", len(db.stmts.statements))
+	}
+
+	db.SetStmtCacheSize(0)
+	db.Insert(&fakeAccount{Email: "a@x"})
+	db.Insert(&fakeAccount{Email: "a@x"})
+	if prepared := fakePrepared(); prepared != 5 {
+		t.Errorf("disabled cache reused statements: prepared = %d, expected = 5", prepared)
+	}
+}
+
+// Test that raw and named queries are run without going through the cache,
+// so that they cannot evict the generated statements.
+func TestStmtCacheRaw(t *testing.T) {
+	db := openFakeAccounts(t, "sqlite3")
+	defer db.Close()
+	fakeQueue()
+
+	db.SetStmtCacheSize(1)
+	db.Insert(&fakeAccount{Email: "a@x"})
+	entry := db.stmts.entries.Front().Value.(*stmtEntry)
+	var accounts []fakeAccount
+	if err := db.Raw(`SELECT * FROM "fake_accounts" WHERE "id" = ?`, 1).Scan(&accounts); err != nil {
+		t.Fatalf("raw query failed: error = %s", err.Error())
+	}
+	if err := db.Named(`SELECT * FROM "fake_accounts" WHERE "id" = :id`,
+		map[string]interface{}{"id": 1}).Scan(&accounts); err != nil {
+		t.Fatalf("named query failed: error = %s", err.Error())
+	}
+	if db.stmts.entries.Len() != 1 || entry.evicted {
+		t.Errorf("generated statement evicted by raw queries")
+	}
+}
+
+// Test that transactions reuse the statements cached by their DB, and that
+// closing the DB closes the cached statements.
+func TestStmtCacheTx(t *testing.T) {
+	db := openFakeAccounts(t, "sqlite3")
+	fakeQueue()
+
+	db.Insert(&fakeAccount{Email: "a@x"})
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("transaction could not begin: error = %s", err.Error())
+	}
+	for i := 0; i < 3; i++ {
+		if err := tx.Insert(&fakeAccount{Email: "a@x"}); err != nil {
+			t.Fatalf("insert failed: error = %s", err.Error())
+		}
+	}
+	tx.Commit()
+	if statements := fakeStatements(); len(statements) != 4 {
+		t.Errorf("statements not run: statements = %v", statements)
+	}
+	if prepared := fakePrepared(); prepared != 1 {
+		t.Errorf("cached statement not reused in the transaction: prepared = %d", prepared)
+	}
+
+	entry := db.stmts.entries.Front().Value.(*stmtEntry)
+	if err := db.Close(); err != nil {
+		t.Fatalf("db could not be closed: error = %s", err.Error())
+	}
+	if !entry.evicted || db.stmts.entries.Len() != 0 {
+		t.Errorf("cached statements not closed with the DB")
+	}
+}
+
+// Test that a cached statement run repeatedly within a transaction is only
+// prepared once on the connection of the transaction, and is bound to the
+// transaction once.
+func TestStmtCacheTxReuse(t *testing.T) {
+	db := openFakeAccounts(t, "sqlite3")
+	defer db.Close()
+	fakeQueue()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("transaction could not begin: error = %s", err.Error())
+	}
+	defer tx.Rollback()
+	// Prepared on another connection than that of the transaction.
+	if err := db.Insert(&fakeAccount{Email: "a@x"}); err != nil {
+		t.Fatalf("insert failed: error = %s", err.Error())
+	}
+	for i := 0; i < 2; i++ {
+		if err := tx.Insert(&fakeAccount{Email: "a@x"}); err != nil {
+			t.Fatalf("insert failed: error = %s", err.Error())
+		}
+	}
+	if prepared := fakePrepared(); prepared != 2 {
+		t.Errorf("statement prepared again within the transaction: prepared = %d, expected = 2", prepared)
+	}
+	if len(tx.stmts) != 1 {
+		t.Errorf("statement not reused within the transaction: statements = %d, expected = 1", len(tx.stmts))
+	}
+}
@@ -15,60 +15,80 @@
 package tags
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
-	"strings"
 	"unicode"
 )
 
 const (
-	// Sep is the icebox subtag. Subtags of the icebox tag will be separated by
-	// this value.
-	subTagSeparator  string = ","
-	payloadSeparator string = ":"
+	// Subtags of the icebox tag will be separated by this value.
+	subTagSeparator rune = ','
+	// The name of a subtag is separated from its info by this value.
+	payloadSeparator rune = ':'
+	// Escapes the following character, e.g. a separator in the subtag info.
+	escapeCharacter rune = '\\'
 )
 
-// Type of error to return when the tag is invalid.
+// Type of error to return when the tag is invalid. The offset is the position
+// in the tag string of the character at fault.
 type invalidTagError struct {
-	tag string
-	msg string
+	tag    string
+	offset int
+	msg    string
 }
 
 // Error producing logic for invalid tag error.
 func (e *invalidTagError) Error() string {
-	return fmt.Sprintf("the given tag %s is invalid : %s", e.tag, e.msg)
+	return fmt.Sprintf("the given tag %s is invalid at offset %d : %s", e.tag, e.offset, e.msg)
 }
 
 // Parse will parse the given subtags and produce a mapping between existing
 // subtags and there subtag info (if available).
+//
+// Subtags are separated by commas, and a subtag name is separated from its
+// info by a colon. Whitespace around names and info is ignored, but the
+// whitespace inside info is kept, so check:age > 18 has the info age > 18.
+// A comma only separates subtags at the top level of the info, so commas may
+// appear inside quotes, as in default:'hello, world', or inside parentheses,
+// as in foreignKey:users(id, org_id). Quotes and parentheses are kept in the
+// info, and a backslash escapes the following character. Within quotes, the
+// escapes are undone and quotes are doubled instead, as in SQL, so that both
+// of these subtags have the same info:
+//
+//	default:'it\'s'
+//	default:'it''s'
+//
+// Besides the built in subtags, Parse accepts the custom subtags added with
+// Register, and fails if their validator rejects the info.
 func Parse(subTags string) (ParsedTag, error) {
-	// Sanitize input
-	subTags = stripSpaces(subTags)
 	result := make(map[SubTag]string)
 	// Make a map for storing the seen tags
 	seenSubTags := make(map[string]bool)
 
-	// Scan the subtag string for subtag separator delimited chunks.
-	tagScanner := bufio.NewScanner(strings.NewReader(subTags))
-	tagScanner.Split(scanSubTagsSeparators)
-	for tagScanner.Scan() {
-		name, info := parseNameAndInfo(tagScanner.Text())
+	scanner := &tagScanner{input: []rune(subTags)}
+	for !scanner.done() {
+		offset := scanner.skipSpaces()
+		if scanner.done() {
+			// Allow trailing separators and empty tags.
+			break
+		}
+		name, info, err := scanner.scanSubTag()
+		if err != nil {
+			return nil, err
+		}
 
 		// Validate the name
 		if _, found := seenSubTags[name]; found {
 			// Tag is duplicate so error
 			return nil, &invalidTagError{
-				tag: name,
-				msg: "tag is duplicate"}
+				tag:    name,
+				offset: offset,
+				msg:    "tag is duplicate"}
 		}
 
-		subtag, found := subTagMap[name]
-		if !found {
-			// Tag is invalid so error
-			return nil, &invalidTagError{
-				tag: name,
-				msg: "tag is unknown"}
+		subtag, err := resolveSubTag(name, info, offset)
+		if err != nil {
+			return nil, err
 		}
 
 		// Tag is valid so add to return value
@@ -78,57 +98,220 @@ func Parse(subTags string) (ParsedTag, error) {
 	return ParsedTag(result), nil
 }
 
-// Parse the subtag name and info field from the given subtag string.
-func parseNameAndInfo(subtag string) (name, info string) {
-	if i := strings.Index(subtag, payloadSeparator); i >= 0 {
-		name = subtag[0:i]
-		if i == (len(subtag) - 1) {
-			return name, ""
+// Map the given subtag name to its subtag, checking the info of custom
+// subtags with their validator. The offset locates the subtag in the tag.
+func resolveSubTag(name, info string, offset int) (SubTag, error) {
+	if subtag, found := subTagMap[name]; found {
+		return subtag, nil
+	}
+	validate, found := lookupCustom(name)
+	if !found {
+		// Tag is invalid so error
+		return "", &invalidTagError{
+			tag:    name,
+			offset: offset,
+			msg:    "tag is unknown"}
+	}
+	if validate != nil {
+		if err := validate(info); err != nil {
+			return "", &invalidTagError{
+				tag:    name,
+				offset: offset,
+				msg:    "info is invalid : " + err.Error()}
 		}
-		info = subtag[i+1 : len(subtag)]
-		return name, info
 	}
-	return subtag, ""
+	return SubTag(name), nil
+}
+
+// A scanner over the characters of a tag string.
+type tagScanner struct {
+	input []rune
+	pos   int
 }
 
-// SplitFunc for separating on subtag separators.
-func scanSubTagsSeparators(
-	data []byte, atEOF bool) (
-	advance int, token []byte, err error) {
-	return scanSeparators(data, atEOF, subTagSeparator)
+// Returns whether the whole input has been consumed.
+func (s *tagScanner) done() bool {
+	return s.pos >= len(s.input)
 }
 
-// Common method for implementing the parsing split funcs.
-func scanSeparators(
-	data []byte, atEOF bool, separator string) (
-	advance int, token []byte, err error) {
+// Skip over whitespace, and return the offset of the next character.
+func (s *tagScanner) skipSpaces() int {
+	for !s.done() && unicode.IsSpace(s.input[s.pos]) {
+		s.pos++
+	}
+	return s.pos
+}
+
+// Scan a single subtag, consuming the separator following it.
+func (s *tagScanner) scanSubTag() (name, info string, err error) {
+	start := s.pos
+	for !s.done() && isNameRune(s.input[s.pos], s.pos == start) {
+		s.pos++
+	}
+	name = string(s.input[start:s.pos])
+	if name == "" {
+		return "", "", s.errorf("expected a subtag name")
+	}
+
+	s.skipSpaces()
+	if s.done() {
+		return name, "", nil
+	}
+	switch s.input[s.pos] {
+	case subTagSeparator:
+		s.pos++
+		return name, "", nil
+	case payloadSeparator:
+		s.pos++
+		info, err = s.scanInfo()
+		return name, info, err
+	default:
+		return "", "", s.errorf("unexpected character after subtag name")
+	}
+}
 
-	if atEOF && len(data) == 0 {
-		return 0, nil, nil
+// Scan subtag info up to the next top level separator, which is consumed.
+func (s *tagScanner) scanInfo() (string, error) {
+	var buffer bytes.Buffer
+	var parens []int
+	s.skipSpaces()
+	for !s.done() {
+		r := s.input[s.pos]
+		switch {
+		case r == subTagSeparator && len(parens) == 0:
+			s.pos++
+			return trimSpaces(buffer.String()), nil
+		case r == escapeCharacter:
+			if s.pos+1 >= len(s.input) {
+				return "", s.errorf("escape character at end of tag")
+			}
+			buffer.WriteRune(s.input[s.pos+1])
+			s.pos += 2
+			continue
+		case r == '\'' || r == '"':
+			quoted, err := s.scanQuoted(r)
+			if err != nil {
+				return "", err
+			}
+			buffer.WriteString(quoted)
+			continue
+		case r == '(':
+			parens = append(parens, s.pos)
+		case r == ')':
+			if len(parens) == 0 {
+				return "", s.errorf("unbalanced closing parenthesis")
+			}
+			parens = parens[:len(parens)-1]
+		}
+		buffer.WriteRune(r)
+		s.pos++
+	}
+	if len(parens) > 0 {
+		s.pos = parens[len(parens)-1]
+		return "", s.errorf("unclosed parenthesis")
 	}
+	return trimSpaces(buffer.String()), nil
+}
 
-	// If we find a separator, return all the bytes from the start to
-	// the separator.
-	if i := bytes.IndexAny(data, separator); i >= 0 {
-		return i + 1, data[0:i], nil
+// Scan a quoted string, returning it along with its quotes. Within the quotes
+// a backslash stops the following character from ending the string, and is
+// removed, while the quote character itself is doubled as in SQL.
+func (s *tagScanner) scanQuoted(quote rune) (string, error) {
+	start := s.pos
+	var buffer bytes.Buffer
+	buffer.WriteRune(quote)
+	for s.pos++; !s.done(); s.pos++ {
+		r := s.input[s.pos]
+		switch r {
+		case escapeCharacter:
+			if s.pos+1 >= len(s.input) {
+				break
+			}
+			s.pos++
+			r = s.input[s.pos]
+		case quote:
+			s.pos++
+			buffer.WriteRune(quote)
+			return buffer.String(), nil
+		}
+		buffer.WriteRune(r)
+		if r == quote {
+			buffer.WriteRune(quote)
+		}
 	}
+	s.pos = start
+	return "", s.errorf("unterminated quoted string")
+}
+
+// Build an error pointing at the current position of the scanner.
+func (s *tagScanner) errorf(msg string) error {
+	return &invalidTagError{
+		tag:    string(s.input),
+		offset: s.pos,
+		msg:    msg}
+}
 
-	// At EOF, and no more separators, so return everything.
-	if atEOF {
-		return len(data), data, nil
+// Returns whether the given rune may appear in a subtag name.
+func isNameRune(r rune, first bool) bool {
+	if first {
+		return unicode.IsLetter(r)
 	}
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
 
-	// Request more bytes
-	return 0, nil, nil
+// Remove leading and trailing whitespace from the given string.
+func trimSpaces(str string) string {
+	return string(bytes.TrimFunc([]byte(str), unicode.IsSpace))
 }
 
-// StripSpaces will remove all whitespace characters from the input string
-// and return it.
-func stripSpaces(str string) string {
-	return strings.Map(func(r rune) rune {
-		if unicode.IsSpace(r) {
-			return -1
+// ParsePairs parses subtag info made of comma separated key=value pairs, such
+// as the info of the meta subtag, into a map. The pairs may be wrapped in
+// parentheses, and values may be quoted, in which case the quotes are removed.
+func ParsePairs(info string) (map[string]string, error) {
+	info = trimSpaces(info)
+	if len(info) >= 2 && info[0] == '(' && info[len(info)-1] == ')' {
+		info = info[1 : len(info)-1]
+	}
+	pairs := make(map[string]string)
+	scanner := &tagScanner{input: []rune(info)}
+	for !scanner.done() {
+		offset := scanner.skipSpaces()
+		pair, err := scanner.scanInfo()
+		if err != nil {
+			return nil, err
 		}
-		return r
-	}, str)
+		if pair == "" {
+			continue
+		}
+		i := bytes.IndexRune([]byte(pair), '=')
+		if i <= 0 {
+			return nil, &invalidTagError{
+				tag:    info,
+				offset: offset,
+				msg:    "expected a key=value pair"}
+		}
+		pairs[trimSpaces(pair[:i])] = Unquote(trimSpaces(pair[i+1:]))
+	}
+	return pairs, nil
+}
+
+// Unquote removes the quotes from a quoted subtag info string, undoing
+// doubled quotes. Backslash escapes are already undone by Parse. Strings
+// which are not quoted are returned unchanged.
+func Unquote(str string) string {
+	runes := []rune(str)
+	if len(runes) < 2 || (runes[0] != '\'' && runes[0] != '"') || runes[len(runes)-1] != runes[0] {
+		return str
+	}
+	quote := runes[0]
+	var buffer bytes.Buffer
+	for i := 1; i < len(runes)-1; i++ {
+		r := runes[i]
+		if r == quote && i+1 < len(runes)-1 {
+			i++
+			r = runes[i]
+		}
+		buffer.WriteRune(r)
+	}
+	return buffer.String()
 }
@@ -16,6 +16,7 @@ package tags
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -93,3 +94,81 @@ func TestParse(t *testing.T) {
 		)
 	}
 }
+
+// Test the parsing of quoted, parenthesized and escaped subtag info.
+func TestParseGrammar(t *testing.T) {
+	testCases := []struct {
+		tag     string
+		subTags []SubTag
+		infos   []string
+	}{
+		{" column : id , primaryKey ", []SubTag{Column, PrimaryKey}, []string{"id", ""}},
+		{"default:'hello, world',notNull", []SubTag{Default, NotNull}, []string{"'hello, world'", ""}},
+		{`default:'it\'s, here'`, []SubTag{Default}, []string{`'it''s, here'`}},
+		{`default:'C:\\dir'`, []SubTag{Default}, []string{`'C:\dir'`}},
+		{"check:age > 18", []SubTag{Check}, []string{"age > 18"}},
+		{"foreignKey:users(id, org_id),index", []SubTag{ForeignKey, Index}, []string{"users(id, org_id)", ""}},
+		{`default:a\,b`, []SubTag{Default}, []string{"a,b"}},
+		{"check:(x IN ('a', 'b')),column,", []SubTag{Check, Column}, []string{"(x IN ('a', 'b'))", ""}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("test parse %s", tc.tag),
+			func(t *testing.T) {
+				tagMap, err := Parse(tc.tag)
+				if err != nil {
+					t.Fatalf("unexpected error parsing %s: error = %s", tc.tag, err.Error())
+				}
+				if len(tagMap) != len(tc.subTags) {
+					t.Errorf("parsed tag has %d subtags instead of %d", len(tagMap), len(tc.subTags))
+				}
+				for i, subTag := range tc.subTags {
+					if val, ok := tagMap.GetInfo(subTag); !ok {
+						t.Errorf("parsed tag missing key %s", subTag)
+					} else if val != tc.infos[i] {
+						t.Errorf("parsed tag info incorrect: %s instead of %s", val, tc.infos[i])
+					}
+				}
+			},
+		)
+	}
+}
+
+// Test that syntax errors point at the offending character.
+func TestParseSyntaxErrors(t *testing.T) {
+	testCases := []struct {
+		tag    string
+		offset string
+	}{
+		{"default:'unterminated", "offset 8"},
+		{"foreignKey:users(id", "offset 16"},
+		{"check:a)", "offset 7"},
+		{"column:id,,unique", "offset 10"},
+		{"column id", "offset 7"},
+		{"column:id,asdf", "offset 10"},
+	}
+
+	for _, tc := range testCases {
+		_, err := Parse(tc.tag)
+		if err == nil {
+			t.Errorf("error not raised for %s", tc.tag)
+		} else if !strings.Contains(err.Error(), tc.offset) {
+			t.Errorf("raised error doesn't mention %s: error = %s", tc.offset, err.Error())
+		}
+	}
+}
+
+// Test that key=value pairs are split and unquoted.
+func TestParsePairs(t *testing.T) {
+	pairs, err := ParsePairs(`(displayName='E-mail, primary', pii=true)`)
+	if err != nil {
+		t.Fatalf("pairs could not be parsed: error = %s", err.Error())
+	}
+	expected := map[string]string{"displayName": "E-mail, primary", "pii": "true"}
+	if !reflect.DeepEqual(pairs, expected) {
+		t.Errorf("pairs incorrect: pairs = %v, expected = %v", pairs, expected)
+	}
+	if _, err := ParsePairs("(pii)"); err == nil {
+		t.Errorf("error not raised for a key without a value")
+	}
+}
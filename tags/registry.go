@@ -0,0 +1,76 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tags
+
+import (
+	"sync"
+)
+
+// Validator checks the subtag info of a custom subtag. It returns an error
+// describing the problem if the info is invalid.
+type Validator func(info string) error
+
+// The custom subtags registered with the package, mapped to their validators.
+var customSubTags = struct {
+	sync.RWMutex
+	validators map[SubTag]Validator
+}{validators: make(map[SubTag]Validator)}
+
+// Register adds a custom subtag, such as pii or encrypted, which Parse will
+// accept from then on. The validator may be nil, in which case any info is
+// accepted; otherwise Parse fails for info the validator rejects.
+//
+// This returns an error if the name is not a valid subtag name, or if it is
+// a built in subtag or has already been registered.
+func Register(subTag SubTag, validate Validator) error {
+	name := subTag.String()
+	for i, r := range name {
+		if !isNameRune(r, i == 0) {
+			return &invalidTagError{
+				tag:    name,
+				offset: i,
+				msg:    "subtag name may only contain letters, digits and underscores"}
+		}
+	}
+	if name == "" {
+		return &invalidTagError{tag: name, msg: "subtag name is empty"}
+	}
+	if _, found := subTagMap[name]; found {
+		return &invalidTagError{tag: name, msg: "subtag is built in"}
+	}
+
+	customSubTags.Lock()
+	defer customSubTags.Unlock()
+	if _, found := customSubTags.validators[subTag]; found {
+		return &invalidTagError{tag: name, msg: "subtag is already registered"}
+	}
+	customSubTags.validators[subTag] = validate
+	return nil
+}
+
+// IsCustom returns whether the given subtag was added through Register.
+func IsCustom(subTag SubTag) bool {
+	_, found := lookupCustom(subTag.String())
+	return found
+}
+
+// Look up the validator of the custom subtag with the given name, along with
+// whether such a subtag exists.
+func lookupCustom(name string) (Validator, bool) {
+	customSubTags.RLock()
+	defer customSubTags.RUnlock()
+	validate, found := customSubTags.validators[SubTag(name)]
+	return validate, found
+}
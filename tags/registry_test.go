@@ -0,0 +1,69 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tags
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Test that registered subtags are parsed and validated.
+func TestRegister(t *testing.T) {
+	pii := SubTag("testPii")
+	err := Register(pii, func(info string) error {
+		if info != "" && info != "high" && info != "low" {
+			return errors.New("level must be high or low")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("subtag could not be registered: error = %s", err.Error())
+	}
+	if !IsCustom(pii) || IsCustom(Column) {
+		t.Errorf("custom subtags are not reported correctly")
+	}
+
+	tagMap, err := Parse("column,testPii:high")
+	if err != nil {
+		t.Fatalf("unexpected error parsing custom subtag: error = %s", err.Error())
+	}
+	if info, found := tagMap.GetInfo(pii); !found || info != "high" {
+		t.Errorf("parsed custom subtag info incorrect: info = %s", info)
+	}
+
+	_, err = Parse("column,testPii:medium")
+	if err == nil {
+		t.Errorf("error not raised for invalid custom subtag info")
+	} else if !strings.Contains(err.Error(), "high or low") || !strings.Contains(err.Error(), "offset 7") {
+		t.Errorf("raised error doesn't describe the problem: error = %s", err.Error())
+	}
+}
+
+// Test that subtags which clash or have bad names cannot be registered.
+func TestRegisterErrors(t *testing.T) {
+	if err := Register(Column, nil); err == nil {
+		t.Errorf("error not raised for built in subtag")
+	}
+	if err := Register(SubTag("test encrypted"), nil); err == nil {
+		t.Errorf("error not raised for subtag name with a space")
+	}
+	if err := Register(SubTag("testTwice"), nil); err != nil {
+		t.Fatalf("subtag could not be registered: error = %s", err.Error())
+	}
+	if err := Register(SubTag("testTwice"), nil); err == nil {
+		t.Errorf("error not raised for duplicate registration")
+	}
+}
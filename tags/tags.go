@@ -81,6 +81,25 @@ const (
 // and one of its fields.
 //
 // ManyToMany: TODO
+//
+// Comment:    The subtag for describing a column. Subtag info contains the
+// comment, which dialects supporting it render as a COMMENT on the column.
+//
+// Meta:       The subtag for attaching free-form metadata to a column. Subtag
+// info contains comma separated key=value pairs, optionally in parentheses,
+// e.g. meta:(pii=true, displayName='Email address').
+//
+// Embedded:   The subtag for storing a struct field as the flattened columns
+// of its own tagged fields. Subtag info contains the prefix of these columns,
+// which defaults to the column name of the field followed by an underscore.
+//
+// Inline:     A synonym of Embedded.
+//
+// JSON:       The subtag for storing a field, typically of map, slice or
+// struct type, as a JSON document.
+//
+// Tenant:     The subtag for marking the column holding the tenant a row
+// belongs to, by which queries are filtered and writes populated.
 const (
 	Column     SubTag = "column"
 	NotNull    SubTag = "notNull"
@@ -94,6 +113,12 @@ const (
 	OneToMany  SubTag = "oneToMany"
 	ManyToOne  SubTag = "manyToOne"
 	ManyToMany SubTag = "manyToMany"
+	Comment    SubTag = "comment"
+	Meta       SubTag = "meta"
+	Embedded   SubTag = "embedded"
+	Inline     SubTag = "inline"
+	JSON       SubTag = "json"
+	Tenant     SubTag = "tenant"
 )
 
 // Mapping from subtag string name to subtag.
@@ -110,4 +135,10 @@ var subTagMap = map[string]SubTag{
 	OneToMany.String():  OneToMany,
 	ManyToOne.String():  ManyToOne,
 	ManyToMany.String(): ManyToMany,
+	Comment.String():    Comment,
+	Meta.String():       Meta,
+	Embedded.String():   Embedded,
+	Inline.String():     Inline,
+	JSON.String():       JSON,
+	Tenant.String():     Tenant,
 }
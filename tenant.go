@@ -0,0 +1,200 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icebox
+
+import (
+	"context"
+	"github.com/jadengis/icebox/schema"
+	"reflect"
+)
+
+// The tenancy of a DB: the tenant its statements are restricted to, and how
+// tenants are routed to database schemas.
+//
+// Tenant is the tenant of the DB, nil if none was given.
+//
+// All is set for DBs which may reach the rows of every tenant.
+//
+// Schema is the database schema holding the tables of the tenant, empty to
+// use the default schema.
+//
+// Schemas resolves the schema of a tenant, if tenants have schemas of their
+// own.
+type tenancy struct {
+	tenant  interface{}
+	all     bool
+	schema  string
+	schemas func(tenant interface{}) string
+}
+
+// The key of the tenant in a context.
+type tenantKey struct{}
+
+// ContextWithTenant returns a copy of the given context holding the given
+// tenant, for DB.WithContext.
+func ContextWithTenant(ctx context.Context, tenant interface{}) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant held by the given context, if any.
+func TenantFromContext(ctx context.Context) (interface{}, bool) {
+	tenant := ctx.Value(tenantKey{})
+	return tenant, tenant != nil
+}
+
+// SetTenantSchemas routes every tenant to a database schema of its own,
+// named by the given function. The tables of a DB for a tenant are then
+// qualified by the schema of the tenant in the statements built by icebox.
+// This must be called before DBs are derived for tenants.
+func (db *DB) SetTenantSchemas(schemas func(tenant interface{}) string) {
+	db.tenancy.schemas = schemas
+}
+
+// ForTenant returns a DB sharing the connections of this DB, whose
+// statements are restricted to the given tenant. Queries on tables with a
+// tenant column are filtered by the tenant, and the tenant is written to the
+// tenant column of inserted rows. Tables are qualified by the schema of the
+// tenant if SetTenantSchemas was called.
+//
+// Queries and inserts on tables with a tenant column, or on any table when
+// tenants have schemas, return an error on a DB without a tenant, so that a
+// forgotten tenant cannot leak the rows of every tenant. Raw queries are run
+// as written, and are not restricted.
+func (db *DB) ForTenant(tenant interface{}) *DB {
+	tenantDB := *db
+	tenantDB.tenancy.tenant = tenant
+	tenantDB.tenancy.all = false
+	tenantDB.tenancy.schema = ""
+	if db.tenancy.schemas != nil {
+		tenantDB.tenancy.schema = db.tenancy.schemas(tenant)
+	}
+	return &tenantDB
+}
+
+// WithContext returns a DB for the tenant held by the given context, as
+// ForTenant does, or this DB if the context holds no tenant.
+func (db *DB) WithContext(ctx context.Context) *DB {
+	if tenant, ok := TenantFromContext(ctx); ok {
+		return db.ForTenant(tenant)
+	}
+	return db
+}
+
+// AllTenants returns a DB sharing the connections of this DB whose
+// statements may reach the rows of every tenant, such as for maintenance
+// jobs. Tables are not qualified by a tenant schema.
+func (db *DB) AllTenants() *DB {
+	allDB := *db
+	allDB.tenancy.tenant = nil
+	allDB.tenancy.all = true
+	allDB.tenancy.schema = ""
+	return &allDB
+}
+
+// Get the quoted name of the given table, qualified by the schema of the
+// tenant of the DB if it has one.
+func (db *DB) tableName(table schema.Table) string {
+	name := db.dialect.Quote(table.Name())
+	if db.tenancy.schema != "" {
+		name = db.dialect.Quote(db.tenancy.schema) + "." + name
+	}
+	return name
+}
+
+// Check that the DB may run statements on the given table, which it may not
+// without a tenant if the table has a tenant column or tenants have schemas.
+func (db *DB) checkTenant(table schema.Table) error {
+	if db.tenancy.all || db.tenancy.tenant != nil {
+		return nil
+	}
+	if planFor(table).tenant != nil || db.tenancy.schemas != nil {
+		return &queryError{msg: "table " + table.Name() + " can only be used by a DB for a tenant"}
+	}
+	return nil
+}
+
+// Get the query restricted to the rows of the tenant of its DB, leaving the
+// query itself unchanged.
+func (q *Query) withTenant() *Query {
+	if err := q.db.checkTenant(q.table); err != nil {
+		scoped := q.clone()
+		scoped.fail(err)
+		return scoped
+	}
+	tenant := planFor(q.table).tenant
+	if tenant == nil || q.db.tenancy.all {
+		return q
+	}
+	scoped := q.clone()
+	return scoped.Where(q.db.dialect.Quote(tenant.column.Name())+" = ?", q.db.tenancy.tenant)
+}
+
+// Write the tenant of the DB to the tenant column of the given rows of the
+// given table. This returns an error, leaving every row unchanged, if the
+// tenant cannot be stored in the tenant field without losing its value, or if
+// a row already belongs to another tenant.
+func (db *DB) applyTenant(table schema.Table, rows []reflect.Value) error {
+	if err := db.checkTenant(table); err != nil {
+		return err
+	}
+	tenant := planFor(table).tenant
+	if tenant == nil || db.tenancy.all {
+		return nil
+	}
+	value := reflect.ValueOf(db.tenancy.tenant)
+	if !isWidening(value.Type(), tenant.fieldType) {
+		return &columnError{
+			cause:  &destinationError{badType: value.Type(), msg: "tenant cannot be converted to " + tenant.fieldType.String()},
+			column: tenant.column.Name(),
+			msg:    "could not write tenant"}
+	}
+	value = value.Convert(tenant.fieldType)
+	fields := make([]reflect.Value, len(rows))
+	for i, row := range rows {
+		fields[i] = fieldByIndex(row, tenant.index)
+		if !fields[i].IsZero() && !reflect.DeepEqual(fields[i].Interface(), value.Interface()) {
+			return &columnError{
+				cause:  &destinationError{badType: table.Type(), msg: "row belongs to another tenant"},
+				column: tenant.column.Name(),
+				msg:    "could not write tenant"}
+		}
+	}
+	for _, field := range fields {
+		field.Set(value)
+	}
+	return nil
+}
+
+// Returns whether values of the given type can be converted to the other
+// type without changing their value: either they are assignable to it, or
+// both are signed integers, unsigned integers or floats and the other type is
+// at least as large.
+func isWidening(from, to reflect.Type) bool {
+	if from.AssignableTo(to) {
+		return true
+	}
+	family := func(t reflect.Type) reflect.Kind {
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return reflect.Int
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return reflect.Uint
+		case reflect.Float32, reflect.Float64:
+			return reflect.Float64
+		}
+		return reflect.Invalid
+	}
+	return family(from) != reflect.Invalid && family(from) == family(to) && from.Bits() <= to.Bits()
+}
@@ -0,0 +1,246 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icebox
+
+import (
+	"context"
+	"database/sql/driver"
+	"github.com/jadengis/icebox/schema"
+	"reflect"
+	"testing"
+)
+
+// A table of rows belonging to tenants.
+type fakeInvoice struct {
+	Id    int64  `icebox:"column,primaryKey"`
+	OrgId int64  `icebox:"column,tenant"`
+	Title string `icebox:"column,unique"`
+}
+
+// Open a fake database holding invoices.
+func openFakeInvoices(t testing.TB, driver string) *DB {
+	s, err := schema.NewSchema("test_schema", new(fakeInvoice), new(fakeAccount))
+	if err != nil {
+		t.Fatalf("schema could not be generated: error = %s", err.Error())
+	}
+	return openFake(t, driver, s)
+}
+
+// Test that queries on a table with a tenant column are filtered by the
+// tenant of the DB, and refused without one.
+func TestTenantQuery(t *testing.T) {
+	db := openFakeInvoices(t, "postgres")
+	defer db.Close()
+
+	query, args, err := db.ForTenant(7).From(new(fakeInvoice)).
+		Where(`"title" = ?`, "a").Unscoped().SQL()
+	if err != nil {
+		t.Fatalf("query could not be built: error = %s", err.Error())
+	}
+	expected := `SELECT "id", "org_id", "title" FROM "fake_invoices" ` +
+		`WHERE ("title" = $1) AND ("org_id" = $2)`
+	if query != expected || !reflect.DeepEqual(args, []interface{}{"a", 7}) {
+		t.Errorf("tenant query incorrect: query = %s, args = %v, expected = %s",
+			query, args, expected)
+	}
+
+	ctx := ContextWithTenant(context.Background(), 7)
+	if again, _, _ := db.WithContext(ctx).From(new(fakeInvoice)).Where(`"title" = ?`, "a").SQL(); again != expected {
+		t.Errorf("context tenant query incorrect: query = %s, expected = %s", again, expected)
+	}
+	if _, _, err := db.From(new(fakeInvoice)).SQL(); err == nil {
+		t.Errorf("error not raised for a query without a tenant")
+	}
+	if _, err := db.WithContext(context.Background()).From(new(fakeInvoice)).Count(); err == nil {
+		t.Errorf("error not raised for a count without a tenant")
+	}
+	query, _, err = db.AllTenants().From(new(fakeInvoice)).SQL()
+	if err != nil || query != `SELECT "id", "org_id", "title" FROM "fake_invoices"` {
+		t.Errorf("query across tenants incorrect: query = %s, error = %v", query, err)
+	}
+	query, _, err = db.From(new(fakeAccount)).SQL()
+	if err != nil || query != `SELECT "id", "email", "name" FROM "fake_accounts"` {
+		t.Errorf("query without a tenant column incorrect: query = %s, error = %v", query, err)
+	}
+}
+
+// Test that inserts write the tenant of the DB into the tenant column, and
+// refuse rows of other tenants.
+func TestTenantInsert(t *testing.T) {
+	db := openFakeInvoices(t, "sqlite3")
+	defer db.Close()
+	fakeQueue()
+
+	invoice := &fakeInvoice{Title: "a"}
+	if err := db.ForTenant(7).Insert(invoice); err != nil {
+		t.Fatalf("insert failed: error = %s", err.Error())
+	}
+	if invoice.OrgId != 7 {
+		t.Errorf("tenant not written: tenant = %d", invoice.OrgId)
+	}
+	expected := fakeStatement{
+		query: `INSERT INTO "fake_invoices" ("org_id", "title") VALUES (?, ?)`,
+		args:  []driver.Value{int64(7), "a"},
+	}
+	if statements := fakeStatements(); len(statements) != 1 || !reflect.DeepEqual(statements[0], expected) {
+		t.Errorf("tenant insert incorrect: statements = %v, expected = %v", statements, expected)
+	}
+
+	if err := db.ForTenant(8).Insert(&fakeInvoice{OrgId: 7, Title: "b"}); err == nil {
+		t.Errorf("error not raised for a row of another tenant")
+	}
+	if err := db.ForTenant("x").Insert(&fakeInvoice{Title: "b"}); err == nil {
+		t.Errorf("error not raised for a tenant of the wrong type")
+	}
+	if err := db.Insert(&fakeInvoice{Title: "b"}); err == nil {
+		t.Errorf("error not raised for an insert without a tenant")
+	}
+	if err := db.AllTenants().Insert(&fakeInvoice{OrgId: 9, Title: "b"}); err != nil {
+		t.Errorf("insert across tenants failed: error = %s", err.Error())
+	}
+
+	invoices := []fakeInvoice{{Title: "c"}, {OrgId: 8, Title: "d"}}
+	if err := db.ForTenant(7).InsertMany(invoices); err == nil {
+		t.Errorf("error not raised for rows of mixed tenants")
+	}
+	if invoices[0].OrgId != 0 {
+		t.Errorf("tenant written to a row of a refused insert: tenant = %d", invoices[0].OrgId)
+	}
+}
+
+// A table of rows belonging to tenants named by strings.
+type fakeNote struct {
+	Id   int64  `icebox:"column,primaryKey"`
+	Team string `icebox:"column,tenant"`
+}
+
+// Test that tenants are only written to tenant fields which hold them
+// without changing their value.
+func TestTenantTypes(t *testing.T) {
+	s, err := schema.NewSchema("test_schema", new(fakeInvoice), new(fakeNote))
+	if err != nil {
+		t.Fatalf("schema could not be generated: error = %s", err.Error())
+	}
+	db := openFake(t, "sqlite3", s)
+	defer db.Close()
+
+	testCases := []struct {
+		tenant interface{}
+		row    interface{}
+		valid  bool
+	}{
+		{int32(7), &fakeInvoice{Title: "a"}, true},
+		{7, &fakeInvoice{Title: "b"}, true},
+		{uint(7), &fakeInvoice{Title: "c"}, false},
+		{7.5, &fakeInvoice{Title: "d"}, false},
+		{"acme", &fakeNote{}, true},
+		{65, &fakeNote{}, false},
+	}
+	for _, tc := range testCases {
+		fakeQueue()
+		err := db.ForTenant(tc.tenant).Insert(tc.row)
+		if (err == nil) != tc.valid {
+			t.Errorf("tenant written incorrectly: tenant = %#v, row = %+v, error = %v",
+				tc.tenant, tc.row, err)
+		}
+		if !tc.valid && len(fakeStatements()) != 0 {
+			t.Errorf("row inserted for a refused tenant: tenant = %#v", tc.tenant)
+		}
+	}
+}
+
+// Test that the upserts of two tenants conflicting on the same row only
+// update it for the tenant it belongs to, and that dialects which cannot
+// guard the update refuse to upsert rows of a tenant.
+func TestTenantUpsert(t *testing.T) {
+	db := openFakeInvoices(t, "sqlite3")
+	defer db.Close()
+	fakeQueue()
+
+	for _, tenant := range []int64{7, 8} {
+		if err := db.ForTenant(tenant).Upsert(&fakeInvoice{Id: 1, Title: "a"}); err != nil {
+			t.Fatalf("upsert failed: error = %s", err.Error())
+		}
+	}
+	upsert := `INSERT INTO "fake_invoices" ("id", "org_id", "title") VALUES (?, ?, ?) ` +
+		`ON CONFLICT ("id") DO UPDATE SET "title" = EXCLUDED."title" ` +
+		`WHERE "fake_invoices"."org_id" = EXCLUDED."org_id"`
+	expected := []fakeStatement{
+		{query: upsert, args: []driver.Value{int64(1), int64(7), "a"}},
+		{query: upsert, args: []driver.Value{int64(1), int64(8), "a"}},
+	}
+	if statements := fakeStatements(); !reflect.DeepEqual(statements, expected) {
+		t.Errorf("tenant upserts incorrect: statements = %v, expected = %v", statements, expected)
+	}
+
+	postgres := openFakeInvoices(t, "postgres")
+	defer postgres.Close()
+	fakeQueue()
+	invoice := &fakeInvoice{Title: "a"}
+	if err := postgres.ForTenant(8).Upsert(invoice); err != nil {
+		t.Errorf("upsert of a row of another tenant failed: error = %s", err.Error())
+	}
+	if invoice.Id != 0 {
+		t.Errorf("key written back for an untouched row: id = %d", invoice.Id)
+	}
+
+	mysql := openFakeInvoices(t, "mysql")
+	defer mysql.Close()
+	if err := mysql.ForTenant(7).Upsert(&fakeInvoice{Id: 1, Title: "a"}); err == nil {
+		t.Errorf("error not raised for a mysql upsert of a tenant")
+	}
+}
+
+// Test that tables are qualified by the schema of the tenant when tenants
+// have schemas of their own.
+func TestTenantSchemas(t *testing.T) {
+	db := openFakeAccounts(t, "postgres")
+	defer db.Close()
+	db.SetTenantSchemas(func(tenant interface{}) string {
+		return "tenant_" + tenant.(string)
+	})
+
+	query, _, err := db.ForTenant("acme").From(new(fakeAccount)).SQL()
+	expected := `SELECT "id", "email", "name" FROM "tenant_acme"."fake_accounts"`
+	if err != nil || query != expected {
+		t.Errorf("tenant schema query incorrect: query = %s, expected = %s, error = %v",
+			query, expected, err)
+	}
+	if _, _, err := db.From(new(fakeAccount)).SQL(); err == nil {
+		t.Errorf("error not raised for a query without a tenant")
+	}
+
+	ids := fakeResultSet{columns: []string{"id"}, rows: [][]driver.Value{{int64(1)}}}
+	fakeQueue(ids, ids)
+	for _, tenant := range []string{"acme", "umbrella"} {
+		if err := db.ForTenant(tenant).Insert(&fakeAccount{Email: "a@x"}); err != nil {
+			t.Fatalf("insert failed: error = %s", err.Error())
+		}
+	}
+	statements := fakeStatements()
+	expectedInserts := []string{
+		`INSERT INTO "tenant_acme"."fake_accounts" ("email", "name") VALUES ($1, $2) RETURNING "id"`,
+		`INSERT INTO "tenant_umbrella"."fake_accounts" ("email", "name") VALUES ($1, $2) RETURNING "id"`,
+	}
+	if len(statements) != len(expectedInserts) {
+		t.Fatalf("tenant schema inserts incorrect: statements = %v", statements)
+	}
+	for i, statement := range statements {
+		if statement.query != expectedInserts[i] {
+			t.Errorf("tenant schema insert incorrect: query = %s, expected = %s",
+				statement.query, expectedInserts[i])
+		}
+	}
+}
@@ -1,10 +1,21 @@
 package types
 
+import (
+	"strconv"
+)
+
 // SQLType is an abstract representation of a SQL column type. A given dialect
 // implementation will need to map these types to the concrete types.
+//
+// Values returns the values permitted in a column of this type, for enum
+// types, and is empty otherwise.
+//
+// Elem returns the type of the elements of an Array type, and nil otherwise.
 type SQLType interface {
 	Type() IceboxType
 	Size() string
+	Values() []string
+	Elem() SQLType
 }
 
 // DefaultSQLType is the default implementation of SQLType. A given dialect
@@ -15,9 +26,15 @@ type SQLType interface {
 //
 // Args allows one to supply named argument information to the SQL type.
 // For example, one may supply the size, or number of decimals as a type.
+//
+// EnumValues are the values permitted in a column of an enum type.
+//
+// ElemType is the type of the elements of an array type.
 type defaultSQLType struct {
 	IceboxType
-	Args map[ArgType]string
+	Args       map[ArgType]string
+	EnumValues []string
+	ElemType   SQLType
 }
 
 func (t *defaultSQLType) Type() IceboxType {
@@ -28,6 +45,14 @@ func (t *defaultSQLType) Size() string {
 	return t.Args[Size]
 }
 
+func (t *defaultSQLType) Values() []string {
+	return t.EnumValues
+}
+
+func (t *defaultSQLType) Elem() SQLType {
+	return t.ElemType
+}
+
 // NewSQLType constructs a new SQLType object with default args.
 func NewSQLType(iceboxType IceboxType) SQLType {
 	return &defaultSQLType{
@@ -47,6 +72,27 @@ func NewSQLTypeWithSize(iceboxType IceboxType, size string) SQLType {
 	}
 }
 
+// NewSQLTypeWithValues constructs a new SQLType object restricted to the
+// given values. This is Enum for string enums, and an integer type for
+// integer enums.
+func NewSQLTypeWithValues(iceboxType IceboxType, values []string) SQLType {
+	return &defaultSQLType{
+		IceboxType: iceboxType,
+		Args:       make(map[ArgType]string),
+		EnumValues: values,
+	}
+}
+
+// NewArraySQLType constructs a new Array SQLType object holding elements of
+// the given type.
+func NewArraySQLType(elem SQLType) SQLType {
+	return &defaultSQLType{
+		IceboxType: Array,
+		Args:       make(map[ArgType]string),
+		ElemType:   elem,
+	}
+}
+
 // IceboxType is the abstract data specification of a SQLType.
 type IceboxType int
 
@@ -83,8 +129,70 @@ const (
 	TimeStamp
 	Time
 	Year
+
+	// Document types
+	JSON
+
+	// Enumerated types
+	Enum
+
+	// Collection types
+	Array
 )
 
+// String converts the given IceboxType into its string representation.
+func (t IceboxType) String() string {
+	if int(t) >= 0 && int(t) < len(iceboxTypeNames) {
+		return iceboxTypeNames[t]
+	}
+	return "IceboxType" + strconv.Itoa(int(t))
+}
+
+// ParseIceboxType maps the string representation of an IceboxType back to
+// the IceboxType, along with a bool indicating whether the name is known.
+func ParseIceboxType(name string) (IceboxType, bool) {
+	for t, typeName := range iceboxTypeNames {
+		if typeName == name {
+			return IceboxType(t), true
+		}
+	}
+	return IceboxType(-1), false
+}
+
+// Mapping between IceboxTypes and string representations.
+var iceboxTypeNames = []string{
+	Char:       "char",
+	VarChar:    "varChar",
+	Text:       "text",
+	MediumText: "mediumText",
+	LongText:   "longText",
+	Blob:       "blob",
+	MediumBlob: "mediumBlob",
+	LongBlob:   "longBlob",
+	Bit:        "bit",
+	TinyInt:    "tinyInt",
+	TinyUint:   "tinyUint",
+	SmallInt:   "smallInt",
+	SmallUint:  "smallUint",
+	MediumInt:  "mediumInt",
+	MediumUint: "mediumUint",
+	Int:        "int",
+	Uint:       "uint",
+	BigInt:     "bigInt",
+	BigUint:    "bigUint",
+	Float:      "float",
+	Double:     "double",
+	Decimal:    "decimal",
+	Date:       "date",
+	DateTime:   "dateTime",
+	TimeStamp:  "timeStamp",
+	Time:       "time",
+	Year:       "year",
+	JSON:       "json",
+	Enum:       "enum",
+	Array:      "array",
+}
+
 // ArgType is an enumeration of the argument types that can be specified
 // when building SQLType.
 //
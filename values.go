@@ -0,0 +1,151 @@
+// Copyright 2017 John Dengis
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icebox
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/jadengis/icebox/dialect"
+	"github.com/jadengis/icebox/schema"
+	"github.com/jadengis/icebox/types"
+	"reflect"
+	"strconv"
+)
+
+// Get the field of the given struct value at the given index without
+// allocating, along with whether it could be reached. A field of an embedded
+// struct behind a nil pointer cannot be reached.
+func fieldValue(value reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				return reflect.Value{}, false
+			}
+			value = value.Elem()
+		}
+		value = value.Field(x)
+	}
+	return value, true
+}
+
+// Get the argument written to the given column for the given field. Fields
+// which cannot be reached are written as NULL, and JSON columns are written
+// as their JSON encoding. Array columns are written as array literals in
+// dialects with native arrays, and as JSON otherwise. Values of enum columns
+// are checked against the values of the enum.
+func columnValue(d dialect.Dialect, column schema.Column, field reflect.Value, ok bool) (interface{}, error) {
+	if !ok {
+		return nil, nil
+	}
+	if !field.CanInterface() {
+		return nil, &columnError{
+			cause:  &destinationError{badType: field.Type(), msg: "field cannot be read"},
+			column: column.Name(),
+			msg:    "could not write column"}
+	}
+	if values := column.Type().Values(); len(values) > 0 {
+		if err := checkEnumValue(field, values); err != nil {
+			return nil, &columnError{cause: err, column: column.Name(), msg: "could not write column"}
+		}
+	}
+	switch column.Type().Type() {
+	case types.JSON:
+	case types.Array:
+		if _, ok := d.(dialect.Arrayer); ok {
+			return Array(field.Interface()).Value()
+		}
+	default:
+		return field.Interface(), nil
+	}
+	switch field.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Ptr, reflect.Interface:
+		if field.IsNil() {
+			return nil, nil
+		}
+	}
+	data, err := json.Marshal(field.Interface())
+	if err != nil {
+		return nil, &columnError{cause: err, column: column.Name(), msg: "could not encode JSON"}
+	}
+	return string(data), nil
+}
+
+// Check that the given enum field holds one of the given values. Nil
+// pointers are written as NULL, and are not checked.
+func checkEnumValue(field reflect.Value, values []string) error {
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return nil
+		}
+		field = field.Elem()
+	}
+	var value string
+	switch field.Kind() {
+	case reflect.String:
+		value = field.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value = strconv.FormatInt(field.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value = strconv.FormatUint(field.Uint(), 10)
+	}
+	for _, permitted := range values {
+		if value == permitted {
+			return nil
+		}
+	}
+	return &enumError{value: value, values: values}
+}
+
+// Get the destination a column is scanned into for the given field. JSON and
+// array columns are decoded into the field.
+func columnTarget(column schema.Column, field reflect.Value) interface{} {
+	switch column.Type().Type() {
+	case types.JSON:
+		return &jsonTarget{field: field}
+	case types.Array:
+		return Array(field.Addr().Interface())
+	default:
+		return field.Addr().Interface()
+	}
+}
+
+// A sql.Scanner decoding a JSON column into a field.
+type jsonTarget struct {
+	field reflect.Value
+}
+
+// Decode the given JSON document into the field. A NULL resets the field to
+// its zero value.
+func (t *jsonTarget) Scan(src interface{}) error {
+	var data []byte
+	switch src := src.(type) {
+	case nil:
+		t.field.Set(reflect.Zero(t.field.Type()))
+		return nil
+	case []byte:
+		data = src
+	case string:
+		data = []byte(src)
+	default:
+		return errors.New("unsupported JSON column value of type " + reflect.TypeOf(src).String())
+	}
+	// Decode into a fresh value so that no stale map entries survive.
+	decoded := reflect.New(t.field.Type())
+	if err := json.Unmarshal(data, decoded.Interface()); err != nil {
+		return err
+	}
+	t.field.Set(decoded.Elem())
+	return nil
+}